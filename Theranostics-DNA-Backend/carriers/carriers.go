@@ -0,0 +1,128 @@
+// carriers/carriers.go
+package carriers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracking states a carrier can report.
+const (
+	StateInTransit = "in_transit"
+	StateDelivered = "delivered"
+)
+
+// ErrUnknownTrackingNumber is returned by trackers that have no shipment with the number.
+var ErrUnknownTrackingNumber = errors.New("tracking number not found at the carrier")
+
+// Carrier links customers and staff to a carrier's tracking page.
+type Carrier interface {
+	// Code identifies the carrier in the API and on stored shipments, e.g. "ups".
+	Code() string
+	// Name is shown to customers, e.g. "UPS".
+	Name() string
+	// TrackingURL returns the public tracking page of a shipment, or "" when there is none.
+	TrackingURL(trackingNumber string) string
+}
+
+// Tracker is implemented by carriers whose API can be polled for the status of a shipment.
+// Shipments with carriers that don't implement it are only updated by staff.
+type Tracker interface {
+	Track(ctx context.Context, trackingNumber string) (Status, error)
+}
+
+// Status is a carrier's latest word on a shipment. State is StateInTransit or StateDelivered, or
+// empty while the carrier has nothing to report yet. Description is the carrier's own wording.
+type Status struct {
+	State       string
+	Description string
+	DeliveredAt *time.Time
+}
+
+// URLCarrier is a carrier without a tracking API, linking to its public tracking page.
+type URLCarrier struct {
+	code string
+	name string
+	// urlPrefix is followed by the escaped tracking number
+	urlPrefix string
+}
+
+// NewURLCarrier returns a carrier whose tracking page is urlPrefix followed by the tracking
+// number. An empty urlPrefix means the carrier has no tracking page.
+func NewURLCarrier(code, name, urlPrefix string) URLCarrier {
+	return URLCarrier{code: code, name: name, urlPrefix: urlPrefix}
+}
+
+// Code returns the carrier's code.
+func (c URLCarrier) Code() string { return c.code }
+
+// Name returns the carrier's display name.
+func (c URLCarrier) Name() string { return c.name }
+
+// TrackingURL returns the tracking page of a shipment.
+func (c URLCarrier) TrackingURL(trackingNumber string) string {
+	if c.urlPrefix == "" || trackingNumber == "" {
+		return ""
+	}
+	return c.urlPrefix + url.QueryEscape(trackingNumber)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Carrier{}
+)
+
+func init() {
+	Register(NewURLCarrier("ups", "UPS", "https://www.ups.com/track?tracknum="))
+	Register(NewURLCarrier("fedex", "FedEx", "https://www.fedex.com/fedextrack/?trknbr="))
+	Register(NewURLCarrier("usps", "USPS", "https://tools.usps.com/go/TrackConfirmAction?tLabels="))
+	Register(NewURLCarrier("dhl", "DHL", "https://www.dhl.com/en/express/tracking.html?AWB="))
+	Register(NewURLCarrier("other", "Courier", ""))
+}
+
+// Register makes a carrier available, replacing any registered with the same code. Carriers
+// with a tracking API are added this way, typically from an init function.
+func Register(carrier Carrier) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[strings.ToLower(carrier.Code())] = carrier
+}
+
+// Get returns the carrier with the given code.
+func Get(code string) (Carrier, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	carrier, ok := registry[strings.ToLower(code)]
+	return carrier, ok
+}
+
+// Codes returns the codes of all registered carriers, sorted.
+func Codes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	codes := make([]string, 0, len(registry))
+	for code := range registry {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Trackable returns the codes of registered carriers that can be polled, sorted.
+func Trackable() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var codes []string
+	for code, carrier := range registry {
+		if _, ok := carrier.(Tracker); ok {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
@@ -3,7 +3,11 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"theransticslabs/m/metrics"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -12,78 +16,74 @@ import (
 
 var DB *gorm.DB
 
+// InitDB connects to the application database. The schema is managed separately by the
+// migrate command.
 func InitDB() {
-	LoadEnv()
 	cfg := AppConfig
 
-	// First, connect to the default 'postgres' database to check/create target database
-	defaultDsn := fmt.Sprintf("host=%s user=%s password=%s dbname=postgres port=%s sslmode=disable TimeZone=UTC",
-		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBPort)
-
-	defaultDB, err := gorm.Open(postgres.Open(defaultDsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent), // Suppress default logging
+	var err error
+	DB, err = gorm.Open(postgres.Open(dsn(cfg.DBName)), &gorm.Config{
+		Logger: NewGormLogger(),
 	})
 	if err != nil {
-		log.Fatalf("Failed to connect to default database: %v", err)
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 
-	// Check if the target database exists
-	var exists bool
-	query := "SELECT 1 FROM pg_database WHERE datname = ?"
-	err = defaultDB.Raw(query, cfg.DBName).Scan(&exists).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		log.Fatalf("Failed to check if database exists: %v", err)
+	// Export connection pool statistics
+	sqlDB, err := DB.DB()
+	if err != nil {
+		slog.Error("Failed to get database handle", "error", err)
+		os.Exit(1)
 	}
-
-	if !exists {
-		// Create the database
-		createDBQuery := fmt.Sprintf("CREATE DATABASE %s", cfg.DBName)
-		if err := defaultDB.Exec(createDBQuery).Error; err != nil {
-			log.Fatalf("Failed to create database %s: %v", cfg.DBName, err)
-		}
-		log.Printf("Database %s created successfully", cfg.DBName)
-	} else {
-		log.Printf("Database %s already exists", cfg.DBName)
+	if err := metrics.RegisterDBStats(sqlDB, cfg.DBName); err != nil {
+		slog.Error("Failed to register database metrics", "error", err)
+		os.Exit(1)
 	}
 
-	// Now, connect to the target database
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
+	slog.Info("Database connection established", "environment", cfg.Environment)
+}
+
+// EnsureDatabase creates the application database when it does not exist yet, connecting
+// through the default 'postgres' database to check.
+func EnsureDatabase() error {
+	cfg := AppConfig
 
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	defaultDB, err := gorm.Open(postgres.Open(dsn("postgres")), &gorm.Config{
+		Logger: NewGormLogger().LogMode(logger.Silent), // Suppress default logging
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to default database: %w", err)
 	}
-
-	log.Printf("%s environment: %s", "Database connection established", cfg.Environment)
-}
-
-// CreateEnumTypes creates the necessary enum types in the database
-func CreateEnumTypes() error {
-	enumTypes := map[string]string{
-		"dna_payment_status": "CREATE TYPE payment_status AS ENUM ('Pending', 'Completed', 'Failed')",
-		"dna_order_status":   "CREATE TYPE order_status AS ENUM ('Pending', 'Shipped', 'Delivered', 'Cancelled')",
+	if sqlDB, err := defaultDB.DB(); err == nil {
+		defer sqlDB.Close()
 	}
 
-	for typeName, query := range enumTypes {
-		var exists bool
-		checkQuery := fmt.Sprintf("SELECT 1 FROM pg_type WHERE typname = '%s'", typeName)
-
-		// Check if the enum type already exists
-		if err := DB.Raw(checkQuery).Scan(&exists).Error; err != nil {
-			return fmt.Errorf("failed to check if enum type %s exists: %w", typeName, err)
-		}
-
-		if exists {
-			log.Printf("Enum type %s already exists", typeName)
-			continue
-		}
+	var count int64
+	if err := defaultDB.Raw("SELECT COUNT(*) FROM pg_database WHERE datname = ?", cfg.DBName).Scan(&count).Error; err != nil {
+		return fmt.Errorf("failed to check if database exists: %w", err)
+	}
+	if count > 0 {
+		slog.Debug("Database already exists", "database", cfg.DBName)
+		return nil
+	}
 
-		// Create the enum type if it does not exist
-		if err := DB.Exec(query).Error; err != nil {
-			return fmt.Errorf("failed to create enum type %s: %w", typeName, err)
-		}
-		log.Printf("Enum type %s created successfully", typeName)
+	// CREATE DATABASE can't take bind parameters, so the name is quoted as an identifier
+	if err := defaultDB.Exec("CREATE DATABASE " + quoteIdentifier(cfg.DBName)).Error; err != nil {
+		return fmt.Errorf("failed to create database %s: %w", cfg.DBName, err)
 	}
+	slog.Info("Database created", "database", cfg.DBName)
 	return nil
 }
+
+// dsn builds the connection string for the named database on the configured server.
+func dsn(dbName string) string {
+	cfg := AppConfig
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
+		cfg.DBHost, cfg.DBUser, cfg.DBPassword, dbName, cfg.DBPort, cfg.DBSSLMode)
+}
+
+// quoteIdentifier quotes a Postgres identifier, doubling any embedded quotes.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
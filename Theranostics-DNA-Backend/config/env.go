@@ -1,160 +1,272 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// AppConfigInterface holds the application configuration. Each field is named by its key tag,
+// and its value is resolved from, in increasing precedence:
+//
+//   - the default tag
+//   - the YAML or TOML file named by CONFIG_FILE, using the keys as written
+//   - the unprefixed environment variable, e.g. DB_HOST
+//   - the variable with the environment's prefix, e.g. PROD_DB_HOST
+//
+// Any variable can be given as a _FILE variant naming a file that holds the value, e.g.
+// PROD_DB_PASSWORD_FILE=/run/secrets/db_password. Variables are also read from .env when it
+// exists. Empty values count as unset.
 type AppConfigInterface struct {
-	Environment string
+	// Environment is one of development, production, testing or localhost.
+	Environment string `key:"environment" default:"localhost"`
 
-	// Server
-	ServerPort string
+	// Server. On shutdown, readiness checks fail for ShutdownDrainSeconds before the server stops
+	// accepting connections, so load balancers can take it out of rotation first.
+	ServerPort           int `key:"server_port" default:"8080"`
+	ShutdownDrainSeconds int `key:"shutdown_drain_seconds" default:"5"`
 
 	// Database
-	DBHost             string
-	DBPort             string
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	JWTSecret          string
-	AllowedOrigins     string
-	SmtpFromEmail      string
-	SmtpEmail          string
-	SmtpPassword       string
-	SmtpServer         string
-	AppUrl             string
-	ApiUrl             string
-	EncryptionKey1     string
-	EncryptionKey2     string
-	PaypalClientID     string
-	PaypalClientSecret string
-	PaypalAPIUrl       string
-	PaypalWebhookID    string
+	DBHost     string `key:"db_host" default:"localhost"`
+	DBPort     int    `key:"db_port" default:"5432"`
+	DBUser     string `key:"db_user"`
+	DBPassword string `key:"db_password"`
+	DBName     string `key:"db_name"`
+	DBSSLMode  string `key:"db_sslmode" default:"disable"`
+
+	JWTSecret      string `key:"jwt_secret_key"`
+	AllowedOrigins string `key:"allowed_origins"`
+	AppUrl         string `key:"app_url"`
+	ApiUrl         string `key:"api_url"`
+	// TrustedProxies lists the IPs or CIDR ranges of the reverse proxies in front of the server,
+	// separated by commas. X-Forwarded-For is only honoured on requests coming from them.
+	TrustedProxies string `key:"trusted_proxies"`
+	// AdminEmail, when set, is notified of every new order.
+	AdminEmail string `key:"admin_email"`
+	// KitLowStockThreshold is the kit quantity at or below which kit.stock_low webhooks are sent.
+	KitLowStockThreshold int `key:"kit_low_stock_threshold" default:"10"`
+
+	// Email. MailTransport is smtp, file (append to the mbox at MailFile) or memory.
+	MailTransport string `key:"mail_transport" default:"smtp"`
+	MailFile      string `key:"mail_file" default:"tmp/mail.mbox"`
+	SmtpFromEmail string `key:"smtp_from_email"`
+	SmtpEmail     string `key:"smtp_email"` // Login user; authentication is skipped when empty
+	SmtpPassword  string `key:"smtp_password"`
+	SmtpServer    string `key:"smtp_server"`
+	SmtpPort      int    `key:"smtp_port" default:"587"`
+	SmtpTLSMode   string `key:"smtp_tls_mode" default:"starttls"` // starttls, tls or none
+
+	// Lab address printed on return labels, and where the label PDFs attached to emails are kept.
+	// The directory holds customer addresses, so it must not be served publicly.
+	LabName          string `key:"lab_name"`
+	LabStreetAddress string `key:"lab_street_address"`
+	LabTownCity      string `key:"lab_town_city"`
+	LabRegion        string `key:"lab_region"`
+	LabPostcode      string `key:"lab_postcode"`
+	LabCountry       string `key:"lab_country"`
+	ReturnLabelDir   string `key:"return_label_dir" default:"storage/return_labels"`
+
+	// Seller details printed on invoices. Invoices are numbered <InvoiceSeries>-<year>-<sequence>,
+	// and InvoiceTaxRate is the tax percentage included in prices.
+	SellerName          string  `key:"seller_name"`
+	SellerStreetAddress string  `key:"seller_street_address"`
+	SellerTownCity      string  `key:"seller_town_city"`
+	SellerRegion        string  `key:"seller_region"`
+	SellerPostcode      string  `key:"seller_postcode"`
+	SellerCountry       string  `key:"seller_country"`
+	SellerTaxID         string  `key:"seller_tax_id"`
+	InvoiceSeries       string  `key:"invoice_series" default:"INV"`
+	InvoiceTaxRate      float64 `key:"invoice_tax_rate" default:"0"`
+
+	// SMSProvider is none or log; empty logs messages outside production and disables SMS in it.
+	SMSProvider string `key:"sms_provider"`
+
+	// Encryption
+	EncryptionKey1 string `key:"encryption_key1"`
+	EncryptionKey2 string `key:"encryption_key2"`
+	BlindIndexKey  string `key:"blind_index_key"`
+	// EncryptionKeyring lists historical key pairs as "id:key1:key2" entries separated by
+	// commas; EncryptionActiveKeyID selects the pair used for new ciphertexts.
+	EncryptionKeyring     string `key:"encryption_keyring"`
+	EncryptionActiveKeyID string `key:"encryption_active_key_id"`
+
+	// PayPal
+	PaypalClientID     string `key:"paypal_client_id"`
+	PaypalClientSecret string `key:"paypal_client_secret"`
+	PaypalAPIUrl       string `key:"paypal_api_url"`
+	PaypalWebhookID    string `key:"paypal_webhook_id"`
+
+	// LogLevel is one of debug, info, warn or error; empty picks a default for the environment.
+	LogLevel string `key:"log_level"`
+	// MetricsToken, when set, must be sent as a bearer token to read /metrics. It is required in
+	// production.
+	MetricsToken string `key:"metrics_token"`
 }
 
 var AppConfig AppConfigInterface
 
-func LoadEnv() {
-	err := godotenv.Load()
+// envPrefixes maps each environment to the prefix of its variables.
+var envPrefixes = map[string]string{
+	"development": "DEV_",
+	"production":  "PROD_",
+	"testing":     "TEST_",
+	"localhost":   "LOCAL_",
+}
+
+// IsProduction reports whether the app is running with the production configuration.
+func IsProduction() bool {
+	return AppConfig.Environment == "production"
+}
+
+// LoadEnv resolves and validates the configuration into AppConfig. It leaves AppConfig untouched
+// and returns every problem found when the configuration is invalid.
+func LoadEnv() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	AppConfig = cfg
+	return nil
+}
+
+func loadConfig() (AppConfigInterface, error) {
+	var cfg AppConfigInterface
+
+	// .env is a convenience for local runs; containers pass real environment variables
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return cfg, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	t := reflect.TypeOf(cfg)
+	values := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		values[t.Field(i).Tag.Get("key")] = t.Field(i).Tag.Get("default")
+	}
+
+	path, err := lookupEnv("CONFIG_FILE")
+	if err != nil {
+		return cfg, err
+	}
+	if path != "" {
+		if err := readConfigFile(path, values); err != nil {
+			return cfg, err
+		}
+	}
+
+	// The environment selects the prefix, so it is only read unprefixed
+	environment, err := lookupEnv("ENVIRONMENT")
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		return cfg, err
+	}
+	if environment != "" {
+		values["environment"] = environment
+	}
+	prefix := envPrefixes[values["environment"]]
+
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("key")
+		if key != "environment" {
+			for _, name := range []string{strings.ToUpper(key), prefix + strings.ToUpper(key)} {
+				value, err := lookupEnv(name)
+				if err != nil {
+					return cfg, err
+				}
+				if value != "" {
+					values[key] = value
+				}
+			}
+		}
+
+		value := values[key]
+		if value == "" {
+			continue
+		}
+		switch field := v.Field(i); field.Kind() {
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return cfg, fmt.Errorf("%s must be a whole number, got %q", key, value)
+			}
+			field.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("%s must be a number, got %q", key, value)
+			}
+			field.SetFloat(f)
+		default:
+			field.SetString(value)
+		}
 	}
 
-	AppConfig.Environment = os.Getenv("ENVIRONMENT")
-
-	switch AppConfig.Environment {
-	case "development":
-		AppConfig.DBHost = os.Getenv("DEV_DB_HOST")
-		AppConfig.DBPort = os.Getenv("DEV_DB_PORT")
-		AppConfig.DBUser = os.Getenv("DEV_DB_USER")
-		AppConfig.DBPassword = os.Getenv("DEV_DB_PASSWORD")
-		AppConfig.DBName = os.Getenv("DEV_DB_NAME")
-		AppConfig.ServerPort = os.Getenv("DEV_SERVER_PORT")
-		AppConfig.JWTSecret = os.Getenv("DEV_JWT_SECRET_KEY")
-		AppConfig.AllowedOrigins = os.Getenv("DEV_ALLOWED_ORIGINS")
-		AppConfig.SmtpFromEmail = os.Getenv("DEV_SMTP_FROM_EMAIL")
-		AppConfig.SmtpEmail = os.Getenv("DEV_SMTP_EMAIL")
-		AppConfig.SmtpPassword = os.Getenv("DEV_SMTP_PASSWORD")
-		AppConfig.SmtpServer = os.Getenv("DEV_SMTP_SERVER")
-		AppConfig.AppUrl = os.Getenv("DEV_APP_URL")
-		AppConfig.ApiUrl = os.Getenv("DEV_API_URL")
-		AppConfig.EncryptionKey1 = os.Getenv("DEV_ENCRYPTION_KEY1")
-		AppConfig.EncryptionKey2 = os.Getenv("DEV_ENCRYPTION_KEY2")
-		AppConfig.PaypalClientID = os.Getenv("DEV_PAYPAL_CLIENT_ID")
-		AppConfig.PaypalClientSecret = os.Getenv("DEV_PAYPAL_CLIENT_SECRET")
-		AppConfig.PaypalAPIUrl = os.Getenv("DEV_PAYPAL_API_URL")
-		AppConfig.PaypalWebhookID = os.Getenv("DEV_PAYPAL_WEBHOOK_ID")
-
-	case "production":
-		AppConfig.DBHost = os.Getenv("PROD_DB_HOST")
-		AppConfig.DBPort = os.Getenv("PROD_DB_PORT")
-		AppConfig.DBUser = os.Getenv("PROD_DB_USER")
-		AppConfig.DBPassword = os.Getenv("PROD_DB_PASSWORD")
-		AppConfig.DBName = os.Getenv("PROD_DB_NAME")
-		AppConfig.ServerPort = os.Getenv("PROD_SERVER_PORT")
-		AppConfig.JWTSecret = os.Getenv("PROD_JWT_SECRET_KEY")
-		AppConfig.AllowedOrigins = os.Getenv("PROD_ALLOWED_ORIGINS")
-		AppConfig.SmtpFromEmail = os.Getenv("PROD_SMTP_FROM_EMAIL")
-		AppConfig.SmtpEmail = os.Getenv("PROD_SMTP_EMAIL")
-		AppConfig.SmtpPassword = os.Getenv("PROD_SMTP_PASSWORD")
-		AppConfig.SmtpServer = os.Getenv("PROD_SMTP_SERVER")
-		AppConfig.AppUrl = os.Getenv("PROD_APP_URL")
-		AppConfig.ApiUrl = os.Getenv("PROD_API_URL")
-		AppConfig.EncryptionKey1 = os.Getenv("PROD_ENCRYPTION_KEY1")
-		AppConfig.EncryptionKey2 = os.Getenv("PROD_ENCRYPTION_KEY2")
-		AppConfig.PaypalClientID = os.Getenv("PROD_PAYPAL_CLIENT_ID")
-		AppConfig.PaypalClientSecret = os.Getenv("PROD_PAYPAL_CLIENT_SECRET")
-		AppConfig.PaypalAPIUrl = os.Getenv("PROD_PAYPAL_API_URL")
-		AppConfig.PaypalWebhookID = os.Getenv("PROD_PAYPAL_WEBHOOK_ID")
-
-	case "testing":
-		AppConfig.DBHost = os.Getenv("TEST_DB_HOST")
-		AppConfig.DBPort = os.Getenv("TEST_DB_PORT")
-		AppConfig.DBUser = os.Getenv("TEST_DB_USER")
-		AppConfig.DBPassword = os.Getenv("TEST_DB_PASSWORD")
-		AppConfig.DBName = os.Getenv("TEST_DB_NAME")
-		AppConfig.ServerPort = os.Getenv("TEST_SERVER_PORT")
-		AppConfig.JWTSecret = os.Getenv("TEST_JWT_SECRET_KEY")
-		AppConfig.AllowedOrigins = os.Getenv("TEST_ALLOWED_ORIGINS")
-		AppConfig.SmtpFromEmail = os.Getenv("TEST_SMTP_FROM_EMAIL")
-		AppConfig.SmtpEmail = os.Getenv("TEST_SMTP_EMAIL")
-		AppConfig.SmtpPassword = os.Getenv("TEST_SMTP_PASSWORD")
-		AppConfig.SmtpServer = os.Getenv("TEST_SMTP_SERVER")
-		AppConfig.AppUrl = os.Getenv("TEST_APP_URL")
-		AppConfig.ApiUrl = os.Getenv("TEST_API_URL")
-		AppConfig.EncryptionKey1 = os.Getenv("TEST_ENCRYPTION_KEY1")
-		AppConfig.EncryptionKey2 = os.Getenv("TEST_ENCRYPTION_KEY2")
-		AppConfig.PaypalClientID = os.Getenv("TEST_PAYPAL_CLIENT_ID")
-		AppConfig.PaypalClientSecret = os.Getenv("TEST_PAYPAL_CLIENT_SECRET")
-		AppConfig.PaypalAPIUrl = os.Getenv("TEST_PAYPAL_API_URL")
-		AppConfig.PaypalWebhookID = os.Getenv("TEST_PAYPAL_WEBHOOK_ID")
-
-	case "localhost":
-		AppConfig.DBHost = os.Getenv("LOCAL_DB_HOST")
-		AppConfig.DBPort = os.Getenv("LOCAL_DB_PORT")
-		AppConfig.DBUser = os.Getenv("LOCAL_DB_USER")
-		AppConfig.DBPassword = os.Getenv("LOCAL_DB_PASSWORD")
-		AppConfig.DBName = os.Getenv("LOCAL_DB_NAME")
-		AppConfig.ServerPort = os.Getenv("LOCAL_SERVER_PORT")
-		AppConfig.JWTSecret = os.Getenv("LOCAL_JWT_SECRET_KEY")
-		AppConfig.AllowedOrigins = os.Getenv("LOCAL_ALLOWED_ORIGINS")
-		AppConfig.SmtpFromEmail = os.Getenv("LOCAL_SMTP_FROM_EMAIL")
-		AppConfig.SmtpEmail = os.Getenv("LOCAL_SMTP_EMAIL")
-		AppConfig.SmtpPassword = os.Getenv("LOCAL_SMTP_PASSWORD")
-		AppConfig.SmtpServer = os.Getenv("LOCAL_SMTP_SERVER")
-		AppConfig.AppUrl = os.Getenv("LOCAL_APP_URL")
-		AppConfig.ApiUrl = os.Getenv("LOCAL_API_URL")
-		AppConfig.EncryptionKey1 = os.Getenv("LOCAL_ENCRYPTION_KEY1")
-		AppConfig.EncryptionKey2 = os.Getenv("LOCAL_ENCRYPTION_KEY2")
-		AppConfig.PaypalClientID = os.Getenv("LOCAL_PAYPAL_CLIENT_ID")
-		AppConfig.PaypalClientSecret = os.Getenv("LOCAL_PAYPAL_CLIENT_SECRET")
-		AppConfig.PaypalAPIUrl = os.Getenv("LOCAL_PAYPAL_API_URL")
-		AppConfig.PaypalWebhookID = os.Getenv("LOCAL_PAYPAL_WEBHOOK_ID")
+	return cfg, nil
+}
+
+// lookupEnv returns the value of the named variable, or the contents of the file named by its
+// _FILE variant when that is set. A trailing newline in the file is dropped.
+func lookupEnv(name string) (string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return os.Getenv(name), nil
+}
+
+// readConfigFile overlays values with the keys of a flat YAML or TOML file. A list is joined with
+// commas, so allowed_origins can be written as one.
+func readConfigFile(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
 
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
 	default:
-		AppConfig.DBHost = os.Getenv("LOCAL_DB_HOST")
-		AppConfig.DBPort = os.Getenv("LOCAL_DB_PORT")
-		AppConfig.DBUser = os.Getenv("LOCAL_DB_USER")
-		AppConfig.DBPassword = os.Getenv("LOCAL_DB_PASSWORD")
-		AppConfig.DBName = os.Getenv("LOCAL_DB_NAME")
-		AppConfig.ServerPort = os.Getenv("LOCAL_SERVER_PORT")
-		AppConfig.JWTSecret = os.Getenv("LOCAL_JWT_SECRET_KEY")
-		AppConfig.AllowedOrigins = os.Getenv("LOCAL_ALLOWED_ORIGINS")
-		AppConfig.SmtpFromEmail = os.Getenv("LOCAL_SMTP_FROM_EMAIL")
-		AppConfig.SmtpEmail = os.Getenv("LOCAL_SMTP_EMAIL")
-		AppConfig.SmtpPassword = os.Getenv("LOCAL_SMTP_PASSWORD")
-		AppConfig.SmtpServer = os.Getenv("LOCAL_SMTP_SERVER")
-		AppConfig.AppUrl = os.Getenv("LOCAL_APP_URL")
-		AppConfig.ApiUrl = os.Getenv("LOCAL_API_URL")
-		AppConfig.EncryptionKey1 = os.Getenv("LOCAL_ENCRYPTION_KEY1")
-		AppConfig.EncryptionKey2 = os.Getenv("LOCAL_ENCRYPTION_KEY2")
-		AppConfig.PaypalClientID = os.Getenv("LOCAL_PAYPAL_CLIENT_ID")
-		AppConfig.PaypalClientSecret = os.Getenv("LOCAL_PAYPAL_CLIENT_SECRET")
-		AppConfig.PaypalAPIUrl = os.Getenv("LOCAL_PAYPAL_API_URL")
-		AppConfig.PaypalWebhookID = os.Getenv("LOCAL_PAYPAL_WEBHOOK_ID")
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
 
+	for key, value := range raw {
+		// Catch misspelt keys rather than silently ignoring them
+		if _, ok := values[key]; !ok {
+			return fmt.Errorf("unknown key %q in config file %s", key, path)
+		}
+		switch value := value.(type) {
+		case nil:
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case map[string]interface{}:
+			return fmt.Errorf("key %q in config file %s must not be nested", key, path)
+		default:
+			values[key] = fmt.Sprint(value)
+		}
 	}
+	return nil
 }
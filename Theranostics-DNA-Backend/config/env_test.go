@@ -0,0 +1,203 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to name in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clearEnv unsets the variables that could set the keys a test checks, so the environment the
+// tests run in can't leak into them.
+func clearEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, name := range []string{"CONFIG_FILE", "ENVIRONMENT"} {
+		t.Setenv(name, "")
+		t.Setenv(name+"_FILE", "")
+	}
+	for _, key := range keys {
+		names := []string{strings.ToUpper(key)}
+		for _, prefix := range envPrefixes {
+			names = append(names, prefix+strings.ToUpper(key))
+		}
+		for _, name := range names {
+			t.Setenv(name, "")
+			t.Setenv(name+"_FILE", "")
+		}
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "yaml",
+			file:    "config.yaml",
+			content: "db_host: db.internal\nserver_port: 9090\ninvoice_tax_rate: 7.5\nallowed_origins:\n  - https://a.example\n  - https://b.example\n",
+			want: map[string]string{
+				"db_host":          "db.internal",
+				"server_port":      "9090",
+				"invoice_tax_rate": "7.5",
+				"allowed_origins":  "https://a.example,https://b.example",
+				"db_name":          "default",
+			},
+		},
+		{
+			name:    "toml",
+			file:    "config.toml",
+			content: "db_host = \"db.internal\"\nserver_port = 9090\nallowed_origins = [\"https://a.example\"]\n",
+			want: map[string]string{
+				"db_host":         "db.internal",
+				"server_port":     "9090",
+				"allowed_origins": "https://a.example",
+				"db_name":         "default",
+			},
+		},
+		{
+			name:    "null keeps the default",
+			file:    "config.yml",
+			content: "db_name: null\n",
+			want:    map[string]string{"db_name": "default"},
+		},
+		{name: "unknown key", file: "config.yaml", content: "db_hots: db.internal\n", wantErr: `unknown key "db_hots"`},
+		{name: "nested key", file: "config.yaml", content: "db_host:\n  name: db\n", wantErr: "must not be nested"},
+		{name: "unsupported extension", file: "config.json", content: "{}", wantErr: "must end in"},
+		{name: "malformed", file: "config.toml", content: "db_host = \n", wantErr: "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]string{"db_host": "", "server_port": "8080", "invoice_tax_rate": "0", "allowed_origins": "", "db_name": "default"}
+			err := readConfigFile(writeFile(t, tt.file, tt.content), values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if values[key] != want {
+					t.Errorf("%s = %q, want %q", key, values[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadConfigFileMissing(t *testing.T) {
+	if err := readConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), map[string]string{}); err == nil {
+		t.Error("readConfigFile succeeded on a missing file")
+	}
+}
+
+func TestLoadConfigLayering(t *testing.T) {
+	secret := writeFile(t, "db_password", "from-secret-file\n")
+	configFile := writeFile(t, "config.yaml", "environment: production\ndb_host: file-host\ndb_port: 6543\ndb_user: file-user\ninvoice_series: FIL\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want AppConfigInterface
+	}{
+		{
+			name: "defaults",
+			want: AppConfigInterface{Environment: "localhost", DBHost: "localhost", DBPort: 5432, InvoiceSeries: "INV", InvoiceTaxRate: 0},
+		},
+		{
+			name: "config file over defaults",
+			env:  map[string]string{"CONFIG_FILE": configFile},
+			want: AppConfigInterface{Environment: "production", DBHost: "file-host", DBPort: 6543, DBUser: "file-user", InvoiceSeries: "FIL"},
+		},
+		{
+			name: "environment over the config file",
+			env:  map[string]string{"CONFIG_FILE": configFile, "DB_HOST": "env-host", "INVOICE_TAX_RATE": "20"},
+			want: AppConfigInterface{Environment: "production", DBHost: "env-host", DBPort: 6543, DBUser: "file-user", InvoiceSeries: "FIL", InvoiceTaxRate: 20},
+		},
+		{
+			name: "prefixed variable over the unprefixed one",
+			env:  map[string]string{"CONFIG_FILE": configFile, "DB_HOST": "env-host", "PROD_DB_HOST": "prod-host", "DEV_DB_HOST": "dev-host"},
+			want: AppConfigInterface{Environment: "production", DBHost: "prod-host", DBPort: 6543, DBUser: "file-user", InvoiceSeries: "FIL"},
+		},
+		{
+			name: "ENVIRONMENT over the config file picks the prefix",
+			env:  map[string]string{"CONFIG_FILE": configFile, "ENVIRONMENT": "development", "PROD_DB_HOST": "prod-host", "DEV_DB_HOST": "dev-host"},
+			want: AppConfigInterface{Environment: "development", DBHost: "dev-host", DBPort: 6543, DBUser: "file-user", InvoiceSeries: "FIL"},
+		},
+		{
+			name: "secret file",
+			env:  map[string]string{"DB_PASSWORD_FILE": secret, "DB_PASSWORD": "ignored"},
+			want: AppConfigInterface{Environment: "localhost", DBHost: "localhost", DBPort: 5432, DBPassword: "from-secret-file", InvoiceSeries: "INV"},
+		},
+	}
+	keys := []string{"db_host", "db_port", "db_user", "db_password", "invoice_series", "invoice_tax_rate"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t, keys...)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := AppConfigInterface{
+				Environment:    cfg.Environment,
+				DBHost:         cfg.DBHost,
+				DBPort:         cfg.DBPort,
+				DBUser:         cfg.DBUser,
+				DBPassword:     cfg.DBPassword,
+				InvoiceSeries:  cfg.InvoiceSeries,
+				InvoiceTaxRate: cfg.InvoiceTaxRate,
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadConfig =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"DB_PORT", "54x32", "db_port must be a whole number"},
+		{"INVOICE_TAX_RATE", "twenty", "invoice_tax_rate must be a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t, "db_port", "invoice_tax_rate")
+			t.Setenv(tt.name, tt.value)
+			if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissingSecretFile(t *testing.T) {
+	clearEnv(t, "db_password")
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE") {
+		t.Errorf("error = %v, want one naming DB_PASSWORD_FILE", err)
+	}
+}
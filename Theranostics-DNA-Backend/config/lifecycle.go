@@ -0,0 +1,17 @@
+// config/lifecycle.go
+package config
+
+import "sync/atomic"
+
+var draining atomic.Bool
+
+// SetDraining marks the server as shutting down so readiness checks fail and load balancers
+// stop routing new requests to it.
+func SetDraining() {
+	draining.Store(true)
+}
+
+// Draining reports whether the server is shutting down.
+func Draining() bool {
+	return draining.Load()
+}
@@ -0,0 +1,160 @@
+// config/logger.go
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration above which queries are logged as warnings.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// redactedLogKeys are attribute keys whose values never reach the logs because they hold
+// personal data or credentials.
+var redactedLogKeys = map[string]bool{
+	"email":            true,
+	"customer_email":   true,
+	"first_name":       true,
+	"last_name":        true,
+	"phone_number":     true,
+	"street_address":   true,
+	"town_city":        true,
+	"postcode":         true,
+	"password":         true,
+	"token":            true,
+	"authorization":    true,
+	"recipients":       true,
+	"set_password_url": true,
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// InitLogger installs a JSON slog logger as the default. Records logged with a request context
+// carry its request ID, and values under redactedLogKeys are masked. The standard log package is
+// routed through the same handler.
+func InitLogger() {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       logLevel(),
+		ReplaceAttr: redactLogAttr,
+	})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// logLevel reads the configured level, defaulting to debug outside production and testing.
+func logLevel() slog.Level {
+	var level slog.Level
+	if AppConfig.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(AppConfig.LogLevel)); err == nil {
+			return level
+		}
+	}
+	switch AppConfig.Environment {
+	case "production", "testing":
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+func redactLogAttr(_ []string, a slog.Attr) slog.Attr {
+	if redactedLogKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	return a
+}
+
+// contextHandler adds the request ID from the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// gormLogger sends GORM's logs to slog. Query text is logged at debug level with placeholders
+// instead of bound values, so customer data in query parameters is never written out.
+type gormLogger struct {
+	level gormlogger.LogLevel
+}
+
+// NewGormLogger returns a GORM logger backed by the default slog logger.
+func NewGormLogger() gormlogger.Interface {
+	return gormLogger{level: gormlogger.Info}
+}
+
+func (l gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return gormLogger{level: level}
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "query failed", "error", err, "sql", sql, "rows", rows, "duration", elapsed)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration", elapsed)
+	case l.level >= gormlogger.Info && slog.Default().Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		slog.DebugContext(ctx, "query", "sql", sql, "rows", rows, "duration", elapsed)
+	}
+}
+
+// ParamsFilter drops bound values from logged SQL.
+func (l gormLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
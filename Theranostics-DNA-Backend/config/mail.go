@@ -0,0 +1,75 @@
+// config/mail.go
+package config
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"theransticslabs/m/mailer"
+	"theransticslabs/m/metrics"
+)
+
+// Mail transports selected by MailTransport.
+const (
+	MailTransportSMTP   = "smtp"
+	MailTransportFile   = "file"
+	MailTransportMemory = "memory"
+)
+
+// memoryMailLimit caps how many messages the memory transport keeps.
+const memoryMailLimit = 100
+
+// Mailer delivers outgoing email. It is set by InitMailer.
+var Mailer mailer.Mailer
+
+// InitMailer builds the transport selected by the configuration.
+func InitMailer() {
+	switch AppConfig.MailTransport {
+	case MailTransportFile:
+		Mailer = &mailer.FileMailer{Path: AppConfig.MailFile}
+	case MailTransportMemory:
+		Mailer = &mailer.MemoryMailer{Limit: memoryMailLimit}
+	default:
+		Mailer = &mailer.SMTPMailer{
+			Host:     AppConfig.SmtpServer,
+			Port:     AppConfig.SmtpPort,
+			Username: AppConfig.SmtpEmail,
+			Password: AppConfig.SmtpPassword,
+			TLSMode:  AppConfig.SmtpTLSMode,
+		}
+	}
+}
+
+// SendEmail sends msg through Mailer, using the configured sender when msg has none.
+func SendEmail(ctx context.Context, msg *mailer.Message) error {
+	if msg.From == "" {
+		msg.From = AppConfig.SmtpFromEmail
+	}
+
+	slog.InfoContext(ctx, "sending email", "subject", msg.Subject, "recipient_count", len(msg.To), "attachment_count", len(msg.Attachments))
+	err := Mailer.Send(ctx, msg)
+	metrics.ObserveEmail(err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send email", "subject", msg.Subject, "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "email sent", "subject", msg.Subject)
+	return nil
+}
+
+// CheckSMTP reports whether a TCP connection to the SMTP server can be opened. Other transports
+// have nothing to check.
+func CheckSMTP(ctx context.Context) error {
+	if AppConfig.MailTransport != MailTransportSMTP {
+		return nil
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(AppConfig.SmtpServer, strconv.Itoa(AppConfig.SmtpPort)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
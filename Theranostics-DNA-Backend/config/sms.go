@@ -0,0 +1,63 @@
+// config/sms.go
+package config
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"theransticslabs/m/metrics"
+	"theransticslabs/m/sms"
+)
+
+// SMS providers selected by SMSProvider.
+const (
+	SMSProviderNone = "none"
+	SMSProviderLog  = "log"
+)
+
+// ErrSMSDisabled is returned when sending SMS while no provider is configured.
+var ErrSMSDisabled = errors.New("sms is disabled")
+
+// SMS delivers text messages. It is nil when SMS is disabled and is set by InitSMS.
+var SMS sms.Provider
+
+// InitSMS builds the provider selected by the configuration. An empty setting logs messages
+// outside production and disables SMS in production.
+func InitSMS() {
+	SMS = nil
+	switch smsProvider() {
+	case SMSProviderLog:
+		SMS = sms.LogProvider{}
+	}
+}
+
+// SMSEnabled reports whether text messages can be sent.
+func SMSEnabled() bool {
+	return SMS != nil
+}
+
+// SendSMS sends a text message through the configured provider.
+func SendSMS(ctx context.Context, to, body string) error {
+	if SMS == nil {
+		return ErrSMSDisabled
+	}
+
+	err := SMS.Send(ctx, to, body)
+	metrics.ObserveSMS(err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send sms", "error", err)
+		return err
+	}
+	return nil
+}
+
+func smsProvider() string {
+	if AppConfig.SMSProvider != "" {
+		return AppConfig.SMSProvider
+	}
+	if IsProduction() {
+		return SMSProviderNone
+	}
+	return SMSProviderLog
+}
@@ -0,0 +1,321 @@
+// config/validate.go
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"theransticslabs/m/mailer"
+)
+
+// LegacyKeyID identifies the EncryptionKey1/EncryptionKey2 pair. Ciphertexts written before key
+// IDs were introduced carry no prefix and are decrypted with this pair.
+const LegacyKeyID = "0"
+
+// KeyIDPattern matches encryption key IDs.
+var KeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,16}$`)
+
+// invoiceSeriesPattern matches invoice series, the prefix of invoice numbers.
+var invoiceSeriesPattern = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// setting pairs a config key with its value for error messages.
+type setting struct {
+	key   string
+	value string
+}
+
+// EncryptionKeyPair holds the two base64-encoded keys used for double encryption.
+type EncryptionKeyPair struct {
+	Key1 string
+	Key2 string
+}
+
+// EncryptionKeys returns every configured key pair indexed by key ID, including the legacy pair.
+func (c AppConfigInterface) EncryptionKeys() (map[string]EncryptionKeyPair, error) {
+	keyring := map[string]EncryptionKeyPair{
+		LegacyKeyID: {Key1: c.EncryptionKey1, Key2: c.EncryptionKey2},
+	}
+
+	for _, entry := range strings.Split(c.EncryptionKeyring, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || !KeyIDPattern.MatchString(parts[0]) {
+			return nil, errors.New("keyring entries must be formatted as id:key1:key2")
+		}
+		if _, exists := keyring[parts[0]]; exists {
+			return nil, fmt.Errorf("duplicate encryption key id %q", parts[0])
+		}
+		keyring[parts[0]] = EncryptionKeyPair{Key1: parts[1], Key2: parts[2]}
+	}
+
+	return keyring, nil
+}
+
+// TrustedProxyNetworks parses TrustedProxies, turning single IPs into one-address ranges.
+func (c AppConfigInterface) TrustedProxyNetworks() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(c.TrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Validate checks the configuration and returns every problem found, one per line, so a bad
+// deployment fails at startup instead of on the first request that needs a missing setting.
+func (c AppConfigInterface) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	required := func(key, value string) bool {
+		if value == "" {
+			fail("%s is required", key)
+			return false
+		}
+		return true
+	}
+	production := c.Environment == "production"
+
+	if _, ok := envPrefixes[c.Environment]; !ok {
+		fail("environment must be development, production, testing or localhost, got %q", c.Environment)
+	}
+
+	for _, port := range []struct {
+		key   string
+		value int
+	}{{"server_port", c.ServerPort}, {"db_port", c.DBPort}, {"smtp_port", c.SmtpPort}} {
+		if port.value < 1 || port.value > 65535 {
+			fail("%s must be between 1 and 65535, got %d", port.key, port.value)
+		}
+	}
+
+	if c.ShutdownDrainSeconds < 0 || c.ShutdownDrainSeconds > 60 {
+		fail("shutdown_drain_seconds must be between 0 and 60, got %d", c.ShutdownDrainSeconds)
+	}
+
+	// Database
+	required("db_host", c.DBHost)
+	required("db_user", c.DBUser)
+	required("db_name", c.DBName)
+	if production {
+		required("db_password", c.DBPassword)
+	}
+	if !sslModes[c.DBSSLMode] {
+		fail("db_sslmode %q is not a valid Postgres sslmode", c.DBSSLMode)
+	}
+
+	// Tokens are signed with HMAC-SHA256, so production secrets need at least its 32-byte block
+	if required("jwt_secret_key", c.JWTSecret) && production && len(c.JWTSecret) < 32 {
+		fail("jwt_secret_key must be at least 32 bytes in production")
+	}
+
+	// URLs
+	for _, field := range []setting{{"app_url", c.AppUrl}, {"api_url", c.ApiUrl}} {
+		if required(field.key, field.value) && !isHTTPURL(field.value) {
+			fail("%s must be an absolute http or https URL, got %q", field.key, field.value)
+		}
+	}
+	for _, origin := range strings.Split(c.AllowedOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" || origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || !isHTTPURL(origin) || strings.Trim(u.Path, "/") != "" {
+			fail("allowed_origins entry %q must be a scheme and host such as https://example.com", origin)
+		}
+	}
+
+	if _, err := c.TrustedProxyNetworks(); err != nil {
+		fail("trusted_proxies: %v", err)
+	}
+
+	// Email
+	switch c.MailTransport {
+	case MailTransportSMTP:
+	case MailTransportFile:
+		required("mail_file", c.MailFile)
+	case MailTransportMemory:
+	default:
+		fail("mail_transport must be smtp, file or memory, got %q", c.MailTransport)
+	}
+	switch c.SmtpTLSMode {
+	case mailer.TLSModeStartTLS, mailer.TLSModeImplicit, mailer.TLSModeNone:
+	default:
+		fail("smtp_tls_mode must be starttls, tls or none, got %q", c.SmtpTLSMode)
+	}
+	if production {
+		if c.MailTransport != MailTransportSMTP {
+			fail("mail_transport must be smtp in production")
+		}
+		if c.SmtpTLSMode == mailer.TLSModeNone {
+			fail("smtp_tls_mode must not be none in production")
+		}
+		required("smtp_server", c.SmtpServer)
+		required("smtp_email", c.SmtpEmail)
+		required("smtp_password", c.SmtpPassword)
+		required("smtp_from_email", c.SmtpFromEmail)
+	}
+	for _, field := range []setting{{"smtp_from_email", c.SmtpFromEmail}, {"admin_email", c.AdminEmail}} {
+		if field.value == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(field.value); err != nil {
+			fail("%s must be an email address, got %q", field.key, field.value)
+		}
+	}
+
+	// Return labels
+	required("return_label_dir", c.ReturnLabelDir)
+	if production {
+		required("lab_name", c.LabName)
+		required("lab_street_address", c.LabStreetAddress)
+		required("lab_town_city", c.LabTownCity)
+		required("lab_postcode", c.LabPostcode)
+		required("lab_country", c.LabCountry)
+	}
+	if dir := filepath.Clean(c.ReturnLabelDir); dir == "public" || strings.HasPrefix(dir, "public"+string(filepath.Separator)) {
+		fail("return_label_dir must not be inside the public directory")
+	}
+
+	// Invoices
+	if production {
+		required("seller_name", c.SellerName)
+		required("seller_street_address", c.SellerStreetAddress)
+		required("seller_town_city", c.SellerTownCity)
+		required("seller_country", c.SellerCountry)
+	}
+	if !invoiceSeriesPattern.MatchString(c.InvoiceSeries) {
+		fail("invoice_series must be 1 to 10 capital letters or digits, got %q", c.InvoiceSeries)
+	}
+	if c.InvoiceTaxRate < 0 || c.InvoiceTaxRate >= 100 {
+		fail("invoice_tax_rate must be a percentage of at least 0 and below 100, got %g", c.InvoiceTaxRate)
+	}
+
+	if c.KitLowStockThreshold < 0 {
+		fail("kit_low_stock_threshold must not be negative, got %d", c.KitLowStockThreshold)
+	}
+
+	// SMS
+	switch c.SMSProvider {
+	case "", SMSProviderNone, SMSProviderLog:
+	default:
+		fail("sms_provider must be none or log, got %q", c.SMSProvider)
+	}
+	if production && c.SMSProvider == SMSProviderLog {
+		fail("sms_provider must not be log in production")
+	}
+
+	// Encryption
+	if keyring, err := c.EncryptionKeys(); err != nil {
+		fail("encryption_keyring: %v", err)
+	} else {
+		ids := make([]string, 0, len(keyring))
+		for id := range keyring {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			pair := keyring[id]
+			name1, name2 := fmt.Sprintf("encryption key %q key1", id), fmt.Sprintf("encryption key %q key2", id)
+			if id == LegacyKeyID {
+				name1, name2 = "encryption_key1", "encryption_key2"
+			}
+			if required(name1, pair.Key1) {
+				checkKey(fail, name1, pair.Key1, 32, 32)
+			}
+			if required(name2, pair.Key2) {
+				checkKey(fail, name2, pair.Key2, 32, 32)
+			}
+		}
+		if id := strings.TrimSpace(c.EncryptionActiveKeyID); id != "" {
+			if _, ok := keyring[id]; !ok {
+				fail("encryption_active_key_id %q is not in encryption_keyring", id)
+			}
+		}
+	}
+	if required("blind_index_key", c.BlindIndexKey) {
+		checkKey(fail, "blind_index_key", c.BlindIndexKey, 32, 0)
+	}
+
+	// PayPal
+	if production {
+		required("paypal_client_id", c.PaypalClientID)
+		required("paypal_client_secret", c.PaypalClientSecret)
+		required("paypal_api_url", c.PaypalAPIUrl)
+	}
+	if c.PaypalAPIUrl != "" && !isHTTPURL(c.PaypalAPIUrl) {
+		fail("paypal_api_url must be an absolute http or https URL, got %q", c.PaypalAPIUrl)
+	}
+
+	// Metrics reveal traffic and error rates, so production doesn't serve them to anyone
+	if production {
+		required("metrics_token", c.MetricsToken)
+	}
+
+	if c.LogLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			fail("log_level must be debug, info, warn or error, got %q", c.LogLevel)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkKey reports a key that is not base64 or whose decoded length is outside [min, max]. A max
+// of 0 means no upper bound.
+func checkKey(fail func(string, ...interface{}), name, value string, min, max int) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	switch {
+	case err != nil:
+		fail("%s must be base64-encoded", name)
+	case len(key) < min || (max > 0 && len(key) > max):
+		if min == max {
+			fail("%s must decode to %d bytes, got %d", name, min, len(key))
+		} else {
+			fail("%s must decode to at least %d bytes, got %d", name, min, len(key))
+		}
+	}
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL with a host.
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
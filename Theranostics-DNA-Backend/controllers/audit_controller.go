@@ -0,0 +1,283 @@
+// controllers/audit_controller.go
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+)
+
+// AuditLogsResponse represents the paginated audit log list.
+type AuditLogsResponse struct {
+	Page         int               `json:"page"`
+	PerPage      int               `json:"per_page"`
+	Sort         string            `json:"sort"`
+	TotalRecords int64             `json:"total_records"`
+	TotalPages   int               `json:"total_pages"`
+	Records      []models.AuditLog `json:"records"`
+}
+
+// auditFilterFields are the query parameters shared by the list and export endpoints.
+var auditFilterFields = []string{"actor_id", "action", "entity_type", "entity_id", "from", "to"}
+
+// GetAuditLogsHandler lists audit entries, newest first, filtered by actor, action, entity and date.
+func GetAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !utils.AllowFields(query, append([]string{"page", "per_page", "sort"}, auditFilterFields...)) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidQueryParameters, nil)
+		return
+	}
+
+	// Default and validation for 'page'
+	page := 1
+	if val := query.Get("page"); val != "" {
+		if p, err := strconv.Atoi(val); err == nil && p > 0 {
+			page = p
+		} else {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPageParameter, nil)
+			return
+		}
+	}
+
+	// Default and validation for 'per_page'
+	perPage := 25
+	if val := query.Get("per_page"); val != "" {
+		if pp, err := strconv.Atoi(val); err == nil && pp > 0 {
+			perPage = pp
+		} else {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPerPageParameter, nil)
+			return
+		}
+	}
+
+	// Default and validation for 'sort'
+	sort := "desc"
+	if val := strings.ToLower(query.Get("sort")); val == "asc" || val == "desc" {
+		sort = val
+	} else if val != "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidSortParameter, nil)
+		return
+	}
+
+	db, err := auditLogQuery(r)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCountRecords, nil)
+		return
+	}
+
+	records := []models.AuditLog{}
+	if err := db.Order("id " + sort).Limit(perPage).Offset((page - 1) * perPage).Find(&records).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	response := AuditLogsResponse{
+		Page:         page,
+		PerPage:      perPage,
+		Sort:         sort,
+		TotalRecords: totalRecords,
+		TotalPages:   int((totalRecords + int64(perPage) - 1) / int64(perPage)),
+		Records:      records,
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgAuditLogsFetchedSuccessfully, response)
+}
+
+// ExportAuditLogsHandler streams every audit entry matching the filters as a CSV file.
+func ExportAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !utils.AllowFields(query, auditFilterFields) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidQueryParameters, nil)
+		return
+	}
+
+	db, err := auditLogQuery(r)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	fileName := fmt.Sprintf("audit_log_%s.csv", time.Now().UTC().Format("20060102150405"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "created_at", "actor_id", "actor_email", "action", "entity_type", "entity_id", "ip_address", "request_id", "changes"})
+
+	// Headers are sent with the first batch, so a later failure can only truncate the file
+	var batch []models.AuditLog
+	result := db.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			actorID := ""
+			if entry.ActorID != nil {
+				actorID = strconv.FormatUint(uint64(*entry.ActorID), 10)
+			}
+			changes, err := json.Marshal(entry.Changes)
+			if err != nil {
+				return err
+			}
+			cw.Write([]string{
+				strconv.FormatUint(uint64(entry.ID), 10),
+				entry.CreatedAt.UTC().Format(time.RFC3339),
+				actorID,
+				csvSafe(entry.ActorEmail),
+				entry.Action,
+				entry.EntityType,
+				entry.EntityID,
+				csvSafe(entry.IPAddress),
+				csvSafe(entry.RequestID),
+				csvSafe(string(changes)),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if result.Error != nil {
+		// The CSV has already started, so a JSON error would only be appended to it
+		slog.ErrorContext(r.Context(), "Audit log export stopped early", "error", result.Error)
+		return
+	}
+	cw.Flush()
+}
+
+// auditLogQuery applies the shared audit log filters from the request's query string.
+func auditLogQuery(r *http.Request) (*gorm.DB, error) {
+	query := r.URL.Query()
+	db := config.DB.WithContext(r.Context()).Model(&models.AuditLog{})
+
+	if val := query.Get("actor_id"); val != "" {
+		actorID, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return nil, errors.New(utils.MsgInvalidActorID)
+		}
+		db = db.Where("actor_id = ?", actorID)
+	}
+	if val := strings.TrimSpace(query.Get("action")); val != "" {
+		db = db.Where("action = ?", val)
+	}
+	if val := strings.TrimSpace(query.Get("entity_type")); val != "" {
+		db = db.Where("entity_type = ?", val)
+	}
+	if val := strings.TrimSpace(query.Get("entity_id")); val != "" {
+		if _, err := strconv.ParseUint(val, 10, 64); err != nil {
+			return nil, errors.New(utils.MsgInvalidEntityID)
+		}
+		db = db.Where("entity_id = ?", val)
+	}
+	if val := query.Get("from"); val != "" {
+		from, _, err := parseAuditDate(val)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("created_at >= ?", from)
+	}
+	if val := query.Get("to"); val != "" {
+		to, dateOnly, err := parseAuditDate(val)
+		if err != nil {
+			return nil, err
+		}
+		// A bare date includes the whole day
+		if dateOnly {
+			db = db.Where("created_at < ?", to.AddDate(0, 0, 1))
+		} else {
+			db = db.Where("created_at <= ?", to)
+		}
+	}
+
+	return db, nil
+}
+
+// parseAuditDate accepts either a date or an RFC 3339 timestamp and reports which it was.
+func parseAuditDate(val string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", val); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, false, errors.New(utils.MsgInvalidDateFilter)
+	}
+	return t.UTC(), false, nil
+}
+
+// csvSafe stops spreadsheet applications from evaluating user-controlled values as formulas.
+func csvSafe(val string) string {
+	if val != "" && strings.ContainsRune("=+-@\t\r", rune(val[0])) {
+		return "'" + val
+	}
+	return val
+}
+
+// recordAudit writes an audit entry attributed to the signed-in user.
+func recordAudit(tx *gorm.DB, r *http.Request, entry utils.AuditEntry) error {
+	actor, _ := middlewares.GetUserFromContext(r.Context())
+	return utils.RecordAudit(tx, r, actor, entry)
+}
+
+// userAuditState is the audited snapshot of a staff user.
+func userAuditState(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"first_name":    user.FirstName,
+		"last_name":     user.LastName,
+		"email":         user.Email,
+		"role_id":       user.RoleID,
+		"active_status": user.ActiveStatus,
+		"is_deleted":    user.IsDeleted,
+	}
+}
+
+// kitAuditState is the audited snapshot of a kit.
+func kitAuditState(kit *models.Kit) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                    kit.Type,
+		"quantity":                kit.Quantity,
+		"supplier_name":           kit.ExtraInfo.SupplierName,
+		"supplier_contact_number": kit.ExtraInfo.SupplierContactNumber,
+		"supplier_address":        kit.ExtraInfo.SupplierAddress,
+		"status":                  kit.Status,
+		"is_deleted":              kit.IsDeleted,
+	}
+}
+
+// roleAuditState is the audited snapshot of a role. Pass nil permissions to leave them out.
+func roleAuditState(role *models.Role, permissions []string) map[string]interface{} {
+	state := map[string]interface{}{
+		"name":                role.Name,
+		"status":              role.Status,
+		"two_factor_required": role.TwoFactorRequired,
+	}
+	if permissions != nil {
+		state["permissions"] = permissions
+	}
+	return state
+}
+
+// webhookAuditState is the audited snapshot of a webhook subscription. The secret is recorded as
+// a short fingerprint, so rotations show up without the secret being stored.
+func webhookAuditState(subscription *models.WebhookSubscription) map[string]interface{} {
+	return map[string]interface{}{
+		"description":        subscription.Description,
+		"url":                subscription.URL,
+		"events":             utils.SplitWebhookEvents(subscription.Events),
+		"secret_fingerprint": utils.HashToken(subscription.Secret)[:12],
+		"active":             subscription.Active,
+		"is_deleted":         subscription.IsDeleted,
+	}
+}
@@ -2,10 +2,15 @@
 package controllers
 
 import (
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"theransticslabs/m/config"
 	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
 	"theransticslabs/m/utils"
 
 	"strings"
@@ -16,13 +21,17 @@ import (
 
 // LoginRequest represents the expected payload for login.
 type LoginRequest struct {
-	Email    string `json:"email" form:"email"`
-	Password string `json:"password" form:"password"`
+	Email      string `json:"email" form:"email"`
+	Password   string `json:"password" form:"password"`
+	DeviceName string `json:"device_name" form:"device_name"`
 }
 
-// LoginResponse represents the response after a successful login.
+// LoginResponse represents the response after a successful login or token refresh.
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+	SessionID    uint   `json:"session_id"`
 }
 
 // LoginHandler handles user login requests.
@@ -30,7 +39,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 
 	// Define allowed fields for this request
-	allowedFields := []string{"email", "password"}
+	allowedFields := []string{"email", "password", "device_name"}
 
 	// Use the common request parser for both JSON and form data, and validate allowed fields
 	err := utils.ParseRequestBody(r, &req, allowedFields)
@@ -58,10 +67,16 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts while the account or client IP is delayed or locked out
+	if !checkLoginThrottle(w, r, req.Email) {
+		return
+	}
+
 	// Find the user by email using the common function
-	user, err := utils.FindUserByEmail(config.DB, req.Email)
+	user, err := utils.FindUserByEmail(config.DB.WithContext(r.Context()), req.Email)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
+			recordLoginFailure(r, req.Email, nil)
 			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidCredentials, nil)
 			return
 		}
@@ -72,6 +87,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Compare the provided password with the hashed password in the database
 	err = bcrypt.CompareHashAndPassword([]byte(user.HashPassword), []byte(req.Password))
 	if err != nil {
+		recordLoginFailure(r, req.Email, &user.ID)
 		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidCredentials, nil)
 		return
 	}
@@ -82,63 +98,137 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(*user)
-	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgTokenCreationFailed, nil)
+	// Users with 2FA enabled get a short-lived challenge instead of a session
+	if user.TwoFactorEnabled {
+		challenge, err := utils.GenerateTwoFactorChallenge(*user, strings.TrimSpace(req.DeviceName))
+		if err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgTokenCreationFailed, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusOK, true, utils.MsgTwoFactorChallengeIssued, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(utils.TwoFactorChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	// The password is correct, so the account's failed attempts no longer count
+	if err := utils.ResetThrottle(config.DB.WithContext(r.Context()), utils.LoginAccountPolicy, req.Email); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
 	}
 
-	// Save the token to the user's Token field
-	user.Token = token
-	err = config.DB.Save(&user).Error
+	// Start a new session for this device and issue its token pair
+	tokens, err := utils.CreateSession(config.DB.WithContext(r.Context()), *user, r, strings.TrimSpace(req.DeviceName))
 	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgTokenSaveFailed, nil)
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgTokenCreationFailed, nil)
 		return
 	}
 
 	// Prepare the response
-	response := LoginResponse{
-		Token: token,
-	}
+	response := newLoginResponse(tokens)
 
 	// Send the response
 	utils.JSONResponse(w, http.StatusOK, true, utils.MsgLoginSuccess, response)
 }
 
-// LogoutHandler removes the JWT token from the user's record in the database.
+// newLoginResponse builds the token response shared by login and refresh.
+func newLoginResponse(tokens *utils.SessionTokens) LoginResponse {
+	return LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+		SessionID:    tokens.Session.ID,
+	}
+}
+
+// LogoutHandler revokes the session the request was authenticated with.
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	// 1. Verify the token (this is done by the AuthMiddleware)
 
-	// 2. Get the user from the context (set by AuthMiddleware)
-	user, ok := middlewares.GetUserFromContext(r.Context())
+	// 2. Get the session from the context (set by AuthMiddleware)
+	session, ok := middlewares.GetSessionFromContext(r.Context())
 	if !ok {
 		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
 		return
 	}
 
-	// Begin a transaction
-	tx := config.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Clear the token in the database
-	user.Token = "" // Set the token field to an empty string
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
+	// Revoke only this device's session; other devices stay signed in
+	if err := utils.RevokeSession(config.DB.WithContext(r.Context()), session, utils.SessionRevokedLogout); err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+	// Send a success response
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgLogoutSuccess, nil)
+}
+
+// checkLoginThrottle rejects the request with 429 when the account or client IP may not try again yet.
+func checkLoginThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	return checkThrottles(w, utils.MsgTooManyLoginAttempts,
+		throttleCheck{utils.LoginAccountPolicy, email},
+		throttleCheck{utils.LoginIPPolicy, utils.ClientIP(r)},
+	)
+}
+
+// recordLoginFailure counts a failed login against the account and the client IP, and records
+// any lockout it causes. userID is nil when the email does not belong to a user.
+func recordLoginFailure(r *http.Request, email string, userID *uint) {
+	recordThrottleFailure(r, utils.LoginAccountPolicy, email, userID)
+	recordThrottleFailure(r, utils.LoginIPPolicy, utils.ClientIP(r), nil)
+}
+
+// throttleCheck pairs a throttle policy with the subject it is applied to.
+type throttleCheck struct {
+	policy  utils.ThrottlePolicy
+	subject string
+}
+
+// checkThrottles responds with 429 and a Retry-After header when any of the checks is blocked.
+func checkThrottles(w http.ResponseWriter, delayedMessage string, checks ...throttleCheck) bool {
+	for _, check := range checks {
+		status, err := utils.CheckThrottle(config.DB, check.policy, check.subject)
+		if err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return false
+		}
+		if !status.Blocked {
+			continue
+		}
+
+		message := delayedMessage
+		if status.Locked {
+			message = utils.MsgTemporarilyLocked
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter.Seconds()))))
+		utils.JSONResponse(w, http.StatusTooManyRequests, false, message, nil)
+		return false
+	}
+	return true
+}
+
+// recordThrottleFailure counts a failed attempt and stores a lock event if it triggered a lockout.
+// Failures here must not change the response, so they are only logged.
+func recordThrottleFailure(r *http.Request, policy utils.ThrottlePolicy, subject string, userID *uint) {
+	status, newlyLocked, err := utils.RecordFailedAttempt(config.DB.WithContext(r.Context()), policy, subject)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to record throttled attempt", "scope", policy.Scope, "error", err)
+		return
+	}
+	if !newlyLocked {
 		return
 	}
 
-	// Send a success response
-	utils.JSONResponse(w, http.StatusOK, true, utils.MsgLogoutSuccess, nil)
+	lockedUntil := time.Now().UTC().Add(status.RetryAfter)
+	event := models.AccountLockEvent{
+		UserID:      userID,
+		Scope:       policy.Scope,
+		Event:       utils.LockEventLocked,
+		IPAddress:   utils.ClientIP(r),
+		LockedUntil: &lockedUntil,
+	}
+	if err := utils.RecordLockEvent(config.DB.WithContext(r.Context()), event); err != nil {
+		slog.ErrorContext(r.Context(), "failed to record lock event", "scope", policy.Scope, "error", err)
+	}
 }
@@ -0,0 +1,72 @@
+// controllers/captured_email_controller.go
+package controllers
+
+import (
+	"net/http"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/mailer"
+	"theransticslabs/m/utils"
+)
+
+// CapturedEmail is a message kept by the memory mail transport.
+type CapturedEmail struct {
+	From        string                    `json:"from"`
+	To          []string                  `json:"to"`
+	Subject     string                    `json:"subject"`
+	Text        string                    `json:"text,omitempty"`
+	HTML        string                    `json:"html,omitempty"`
+	Attachments []CapturedEmailAttachment `json:"attachments"`
+}
+
+// CapturedEmailAttachment describes an attachment of a captured email without its content.
+type CapturedEmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int    `json:"size"`
+	Inline      bool   `json:"inline"`
+}
+
+// GetCapturedEmailsHandler lists the emails the memory mail transport has kept, oldest first, so
+// what would have been sent can be checked while developing or testing against a running server.
+func GetCapturedEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	memory, ok := config.Mailer.(*mailer.MemoryMailer)
+	if !ok {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgMailCaptureDisabled, nil)
+		return
+	}
+
+	messages := memory.Messages()
+	captured := make([]CapturedEmail, len(messages))
+	for i, msg := range messages {
+		attachments := make([]CapturedEmailAttachment, len(msg.Attachments))
+		for j, attachment := range msg.Attachments {
+			attachments[j] = CapturedEmailAttachment{
+				Filename:    attachment.Filename,
+				ContentType: attachment.ContentType,
+				Size:        len(attachment.Data),
+				Inline:      attachment.Inline,
+			}
+		}
+		captured[i] = CapturedEmail{
+			From:        msg.From,
+			To:          msg.To,
+			Subject:     msg.Subject,
+			Text:        msg.Text,
+			HTML:        msg.HTML,
+			Attachments: attachments,
+		}
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgCapturedEmailsFetched, captured)
+}
+
+// ClearCapturedEmailsHandler discards the emails the memory mail transport has kept.
+func ClearCapturedEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	memory, ok := config.Mailer.(*mailer.MemoryMailer)
+	if !ok {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgMailCaptureDisabled, nil)
+		return
+	}
+	memory.Reset()
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgCapturedEmailsCleared, nil)
+}
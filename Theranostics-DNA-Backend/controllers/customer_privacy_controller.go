@@ -0,0 +1,289 @@
+// controllers/customer_privacy_controller.go
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// CustomerDataExport is the manifest written at the root of a customer's data export archive.
+type CustomerDataExport struct {
+	ExportedAt   time.Time            `json:"exported_at"`
+	Customer     models.Customer      `json:"customer"`
+	Orders       []models.Order       `json:"orders"`
+	Payments     []models.Payment     `json:"payments"`
+	Invoices     []models.Invoice     `json:"invoices"`
+	Shipments    []models.Shipment    `json:"shipments"`
+	ReturnLabels []models.ReturnLabel `json:"return_labels"`
+	Files        []string             `json:"files"`
+}
+
+// ExportCustomerDataHandler bundles a customer's profile, orders, payments, invoices, shipments
+// and return labels (JSON plus the stored invoice PDFs) into a ZIP archive and streams it to the
+// caller.
+func ExportCustomerDataHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidCustomerID, nil)
+		return
+	}
+
+	// 1. Load the customer, including soft-deleted records
+	var customer models.Customer
+	if err := config.DB.WithContext(r.Context()).Unscoped().First(&customer, customerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgCustomerNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// 2. Collect every record linked to the customer
+	export, err := collectCustomerData(config.DB.WithContext(r.Context()), &customer)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToExportCustomerData, nil)
+		return
+	}
+
+	// 3. Build the archive in memory so a failure never leaves a half-written response
+	archive, err := buildCustomerDataArchive(export)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToExportCustomerData, nil)
+		return
+	}
+
+	// 4. Record who took a copy of the customer's data before handing it over
+	if err := recordAudit(config.DB.WithContext(r.Context()), r, utils.AuditEntry{
+		Action:     utils.AuditCustomerExport,
+		EntityType: utils.AuditEntityCustomer,
+		EntityID:   customer.ID,
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToExportCustomerData, nil)
+		return
+	}
+
+	fileName := fmt.Sprintf("customer_%d_export_%s.zip", customer.ID, export.ExportedAt.Format("20060102150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, archive)
+}
+
+// EraseCustomerDataHandler anonymises a customer's personal data while keeping the orders,
+// payments and invoices that must be retained for accounting. Erasure overwrites the customer
+// record and deletes the return label files still waiting to be emailed. Shipments and return
+// labels are kept unchanged: they hold only carriers, tracking numbers and kit serials, and label
+// PDFs are rendered from the anonymised record from then on.
+func EraseCustomerDataHandler(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidCustomerID, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	if tx.Error != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToStartTransaction, nil)
+		return
+	}
+	defer tx.Rollback()
+
+	var customer models.Customer
+	if err := tx.Unscoped().First(&customer, customerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgCustomerNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	if customer.ErasedAt != nil {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgCustomerAlreadyErased, nil)
+		return
+	}
+
+	if err := anonymiseCustomer(tx, &customer); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToEraseCustomerData, nil)
+		return
+	}
+
+	// Only the fact of the erasure is recorded; the erased values must not survive in the audit log
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditCustomerErase,
+		EntityType: utils.AuditEntityCustomer,
+		EntityID:   customer.ID,
+		After:      map[string]interface{}{"erased_at": customer.ErasedAt},
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToEraseCustomerData, nil)
+		return
+	}
+
+	var orderIDs []uint
+	if err := tx.Model(&models.Order{}).Where("customer_id = ?", customer.ID).Pluck("id", &orderIDs).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToEraseCustomerData, nil)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToEraseCustomerData, nil)
+		return
+	}
+
+	// Return labels still waiting to be emailed carry the erased address
+	for _, orderID := range orderIDs {
+		if err := utils.RemoveReturnLabelFiles(orderID); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete an erased customer's return labels", "customer_id", customer.ID, "order_id", orderID, "error", err)
+		}
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgCustomerErasedSuccessfully, nil)
+}
+
+// collectCustomerData loads the orders, payments, invoices, shipments and return labels that
+// belong to a customer.
+func collectCustomerData(db *gorm.DB, customer *models.Customer) (*CustomerDataExport, error) {
+	export := &CustomerDataExport{
+		ExportedAt: time.Now().UTC(),
+		Customer:   *customer,
+	}
+
+	if err := db.Unscoped().Where("customer_id = ?", customer.ID).Order("id").Find(&export.Orders).Error; err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]uint, len(export.Orders))
+	for i, order := range export.Orders {
+		orderIDs[i] = order.ID
+	}
+	if len(orderIDs) == 0 {
+		return export, nil
+	}
+
+	if err := db.Unscoped().Where("order_id IN ?", orderIDs).Order("id").Find(&export.Payments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("order_id IN ?", orderIDs).Order("id").Find(&export.Shipments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("order_id IN ?", orderIDs).Order("id").Find(&export.ReturnLabels).Error; err != nil {
+		return nil, err
+	}
+
+	paymentIDs := make([]uint, len(export.Payments))
+	for i, payment := range export.Payments {
+		paymentIDs[i] = payment.ID
+	}
+	if len(paymentIDs) == 0 {
+		return export, nil
+	}
+
+	if err := db.Unscoped().Where("payment_id IN ?", paymentIDs).Order("id").Find(&export.Invoices).Error; err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// buildCustomerDataArchive writes the export as one JSON file per record type plus the
+// invoice PDFs that are still present on disk.
+func buildCustomerDataArchive(export *CustomerDataExport) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	// Copy the invoice PDFs first so the manifest can list what was actually included
+	for _, invoice := range export.Invoices {
+		pdfPath := filepath.Join("public", invoice.InvoiceLink)
+		data, err := os.ReadFile(pdfPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		name := filepath.ToSlash(filepath.Join("invoices", filepath.Base(invoice.InvoiceLink)))
+		if err := writeZipFile(zw, name, data); err != nil {
+			return nil, err
+		}
+		export.Files = append(export.Files, name)
+	}
+
+	jsonFiles := map[string]interface{}{
+		"customer.json":      export.Customer,
+		"orders.json":        export.Orders,
+		"payments.json":      export.Payments,
+		"invoices.json":      export.Invoices,
+		"shipments.json":     export.Shipments,
+		"return_labels.json": export.ReturnLabels,
+		"manifest.json":      export,
+	}
+	for _, name := range []string{"customer.json", "orders.json", "payments.json", "invoices.json", "shipments.json", "return_labels.json", "manifest.json"} {
+		data, err := json.MarshalIndent(jsonFiles[name], "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(zw, name, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// anonymiseCustomer overwrites every PII column on the customer. The country is kept
+// because it is needed for tax reporting on the retained invoices. The struct is saved
+// (rather than a column map) so the replacement values are encrypted like any other write.
+func anonymiseCustomer(tx *gorm.DB, customer *models.Customer) error {
+	now := time.Now().UTC()
+
+	customer.FirstName = "Erased"
+	customer.LastName = ""
+	customer.Email = fmt.Sprintf("erased-customer-%d@erased.invalid", customer.ID)
+	customer.PhoneNumber = "0000000000"
+	customer.StreetAddress = "Erased"
+	customer.TownCity = "Erased"
+	customer.Region = ""
+	customer.Postcode = ""
+	customer.IsDeleted = true
+	customer.ErasedAt = &now
+	customer.UpdatedAt = now
+
+	emailHash, err := utils.BlindIndex(customer.Email)
+	if err != nil {
+		return err
+	}
+	customer.EmailHash = emailHash
+
+	return tx.Unscoped().Save(customer).Error
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"theransticslabs/m/models"
+)
+
+func TestBuildCustomerDataArchive(t *testing.T) {
+	export := &CustomerDataExport{
+		Customer:     models.Customer{ID: 7, FirstName: "Jane"},
+		Orders:       []models.Order{{ID: 3, CustomerID: 7}},
+		Shipments:    []models.Shipment{{ID: 1, OrderID: 3, Carrier: "ups", TrackingNumber: "1Z999"}},
+		ReturnLabels: []models.ReturnLabel{{ID: 2, OrderID: 3, Unit: 1, KitSerial: "TK23456789AB"}},
+	}
+	archive, err := buildCustomerDataArchive(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"customer.json", `"first_name": "Jane"`},
+		{"orders.json", `"customer_id": 7`},
+		{"payments.json", "null"},
+		{"invoices.json", "null"},
+		{"shipments.json", `"tracking_number": "1Z999"`},
+		{"return_labels.json", `"kit_serial": "TK23456789AB"`},
+		{"manifest.json", `"return_labels"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := files[tt.name]
+			if !ok {
+				t.Fatalf("%s is missing from the archive", tt.name)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			var buf bytes.Buffer
+			if _, err := buf.ReadFrom(rc); err != nil {
+				t.Fatal(err)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Errorf("%s is not valid JSON", tt.name)
+			}
+			if !bytes.Contains(buf.Bytes(), []byte(tt.want)) {
+				t.Errorf("%s does not contain %s:\n%s", tt.name, tt.want, buf.String())
+			}
+		})
+	}
+}
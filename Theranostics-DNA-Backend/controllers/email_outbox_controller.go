@@ -0,0 +1,142 @@
+// controllers/email_outbox_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailOutboxResponse represents the paginated email outbox list.
+type EmailOutboxResponse struct {
+	Page         int                  `json:"page"`
+	PerPage      int                  `json:"per_page"`
+	TotalRecords int64                `json:"total_records"`
+	TotalPages   int                  `json:"total_pages"`
+	Records      []models.EmailOutbox `json:"records"`
+}
+
+// GetEmailOutboxHandler lists queued, sent and dead-lettered emails, newest first, optionally
+// filtered by status. Bodies are never returned since they can hold single-use links.
+func GetEmailOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !utils.AllowFields(query, []string{"page", "per_page", "status"}) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidQueryParameters, nil)
+		return
+	}
+
+	// Default and validation for 'page'
+	page := 1
+	if val := query.Get("page"); val != "" {
+		if p, err := strconv.Atoi(val); err == nil && p > 0 {
+			page = p
+		} else {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPageParameter, nil)
+			return
+		}
+	}
+
+	// Default and validation for 'per_page'
+	perPage := 25
+	if val := query.Get("per_page"); val != "" {
+		if pp, err := strconv.Atoi(val); err == nil && pp > 0 {
+			perPage = pp
+		} else {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPerPageParameter, nil)
+			return
+		}
+	}
+
+	db := config.DB.WithContext(r.Context()).Model(&models.EmailOutbox{})
+	switch status := query.Get("status"); status {
+	case "":
+	case utils.OutboxStatusPending, utils.OutboxStatusSent, utils.OutboxStatusDead:
+		db = db.Where("status = ?", status)
+	default:
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidEmailStatus, nil)
+		return
+	}
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCountRecords, nil)
+		return
+	}
+
+	records := []models.EmailOutbox{}
+	if err := db.Omit("body").Order("id desc").Limit(perPage).Offset((page - 1) * perPage).Find(&records).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	response := EmailOutboxResponse{
+		Page:         page,
+		PerPage:      perPage,
+		TotalRecords: totalRecords,
+		TotalPages:   int((totalRecords + int64(perPage) - 1) / int64(perPage)),
+		Records:      records,
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgEmailOutboxFetchedSuccessfully, response)
+}
+
+// ResendEmailHandler puts a dead-lettered email back in the queue with a fresh set of attempts.
+func ResendEmailHandler(w http.ResponseWriter, r *http.Request) {
+	emailID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidEmailID, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	// Lock the row so the worker can't pick it up half-way through the reset
+	var email models.EmailOutbox
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&email, emailID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgEmailNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if email.Status != utils.OutboxStatusDead {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgEmailNotDeadLettered, nil)
+		return
+	}
+
+	email.Status = utils.OutboxStatusPending
+	email.Attempts = 0
+	email.NextAttemptAt = time.Now()
+	if err := tx.Model(&email).Select("Status", "Attempts", "NextAttemptAt").Updates(&email).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToResendEmail, nil)
+		return
+	}
+
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditEmailResend,
+		EntityType: utils.AuditEntityEmail,
+		EntityID:   email.ID,
+		Before:     map[string]interface{}{"status": utils.OutboxStatusDead},
+		After:      map[string]interface{}{"status": utils.OutboxStatusPending},
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToResendEmail, nil)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToResendEmail, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgEmailQueuedForResend, nil)
+}
@@ -0,0 +1,57 @@
+// controllers/email_template_controller.go
+package controllers
+
+import (
+	"net/http"
+
+	"theransticslabs/m/emails"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// EmailTemplatesResponse lists the email templates and the locales each is available in.
+type EmailTemplatesResponse struct {
+	Templates []string `json:"templates"`
+	Locales   []string `json:"locales"`
+}
+
+// GetEmailTemplatesHandler lists the email templates that can be previewed.
+func GetEmailTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgEmailTemplatesFetchedSuccessfully, EmailTemplatesResponse{
+		Templates: emails.Names(),
+		Locales:   emails.Locales,
+	})
+}
+
+// PreviewEmailTemplateHandler renders a template with sample data as an HTML page. The locale
+// query parameter picks the translation and defaults to English.
+func PreviewEmailTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !utils.AllowFields(query, []string{"locale"}) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidQueryParameters, nil)
+		return
+	}
+
+	locale := query.Get("locale")
+	if locale == "" {
+		locale = emails.DefaultLocale
+	}
+	if !emails.IsSupportedLocale(locale) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgUnsupportedLanguage, nil)
+		return
+	}
+
+	email, err := emails.RenderPreview(mux.Vars(r)["name"], locale)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgEmailTemplateNotFound, nil)
+		return
+	}
+
+	// The preview is opened straight in the browser; only its inline images and styles may load
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(email.HTML))
+}
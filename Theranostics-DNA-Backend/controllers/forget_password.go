@@ -44,8 +44,26 @@ func ForgetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Limit how many reset emails can be triggered per account and per client IP
+	ip := utils.ClientIP(r)
+	if !checkThrottles(w, utils.MsgTooManyPasswordResetRequests,
+		throttleCheck{utils.PasswordResetAccountPolicy, req.Email},
+		throttleCheck{utils.PasswordResetIPPolicy, ip},
+	) {
+		return
+	}
+
 	// Find the user by email using the common function
-	user, err := utils.FindUserByEmail(config.DB, req.Email)
+	user, err := utils.FindUserByEmail(config.DB.WithContext(r.Context()), req.Email)
+
+	// Every request counts, whether or not the email belongs to a user
+	var userID *uint
+	if err == nil {
+		userID = &user.ID
+	}
+	recordThrottleFailure(r, utils.PasswordResetAccountPolicy, req.Email, userID)
+	recordThrottleFailure(r, utils.PasswordResetIPPolicy, ip, nil)
+
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgEmailNotExist, nil)
@@ -55,42 +73,28 @@ func ForgetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate a secure random password
-	newPassword := utils.GenerateSecurePassword()
-
-	// Start a transaction
-	tx := config.DB.Begin()
+	// Issue a single-use reset token; the current password keeps working until it is redeemed
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
 
-	// Defer a rollback in case of failure
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Send the email
-	emailBody := emails.ResetPasswordEmail(user.FirstName, user.LastName, req.Email, newPassword, config.AppConfig.AppUrl)
-	if err := config.SendEmail([]string{req.Email}, "Password Reset", emailBody); err != nil {
-		tx.Rollback()
-		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedSentEmail, nil)
+	token, _, err := utils.IssuePasswordToken(tx, user.ID, utils.PasswordTokenPurposeReset, nil)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
-
 	}
 
-	// Hash and update the new password in the database
-	hashedPassword, err := utils.HashPassword(newPassword) // Implement HashPassword function
+	// Queue the reset link
+	email, err := emails.Render(emails.TemplateResetPassword, user.Language, emails.ResetPasswordData{
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		ResetURL:       utils.SetPasswordURL(token),
+		ExpiresInHours: utils.PasswordTokenExpiryHours(utils.PasswordTokenPurposeReset),
+	})
 	if err != nil {
-		tx.Rollback()
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
-
 	}
-
-	// Update user's hashed password and remove the token
-	user.HashPassword = hashedPassword
-	user.Token = ""
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
+	if err := utils.QueueEmail(tx, []string{req.Email}, email.Subject, email.HTML); err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
 	}
@@ -0,0 +1,76 @@
+// controllers/health_controller.go
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/migrations"
+	"theransticslabs/m/utils"
+)
+
+// readinessTimeout bounds each dependency check so a hung dependency can't stall the probe.
+const readinessTimeout = 3 * time.Second
+
+// Readiness check results.
+const (
+	checkOK          = "ok"
+	checkUnavailable = "unavailable"
+	checkPending     = "pending"
+	checkDraining    = "draining"
+)
+
+// HealthzHandler is the liveness probe: it succeeds whenever the process can serve requests.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgServiceAlive, nil)
+}
+
+// ReadyzHandler is the readiness probe. It fails while the server is shutting down, when the
+// database can't be pinged and while migrations are pending. SMTP is only checked when the probe
+// asks for it with ?smtp=true, since mail outages shouldn't take the API out of rotation.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{}
+	ready := true
+
+	if config.Draining() {
+		checks["server"] = checkDraining
+		ready = false
+	} else {
+		checks["server"] = checkOK
+	}
+
+	checks["database"] = checkOK
+	if sqlDB, err := config.DB.DB(); err != nil || sqlDB.PingContext(ctx) != nil {
+		checks["database"] = checkUnavailable
+		ready = false
+	}
+
+	if pending, err := migrations.Pending(ctx, config.DB); err != nil {
+		checks["migrations"] = checkUnavailable
+		ready = false
+	} else if pending > 0 {
+		checks["migrations"] = checkPending
+		ready = false
+	} else {
+		checks["migrations"] = checkOK
+	}
+
+	if r.URL.Query().Get("smtp") == "true" {
+		checks["smtp"] = checkOK
+		if err := config.CheckSMTP(ctx); err != nil {
+			checks["smtp"] = checkUnavailable
+			ready = false
+		}
+	}
+
+	if !ready {
+		utils.JSONResponse(w, http.StatusServiceUnavailable, false, utils.MsgServiceNotReady, checks)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgServiceReady, checks)
+}
@@ -122,7 +122,18 @@ func CreateKitHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 6. Store kit in the database
-	if err := config.DB.Create(&newKit).Error; err != nil {
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newKit).Error; err != nil {
+			return err
+		}
+		return utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditKitCreate,
+			EntityType: utils.AuditEntityKit,
+			EntityID:   newKit.ID,
+			After:      kitAuditState(&newKit),
+		})
+	})
+	if err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
 	}
@@ -267,8 +278,7 @@ func GetKitsListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize GORM query with Debug for detailed logging
-	db := config.DB.Debug().
+	db := config.DB.WithContext(r.Context()).
 		Model(&models.Kit{}).
 		Joins("JOIN users ON kits.created_by = users.id").
 		Where("kits.is_deleted = ?", false)
@@ -340,7 +350,7 @@ func GetKitsListHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var users []models.User
-	if err := config.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+	if err := config.DB.WithContext(r.Context()).Where("id IN ?", userIDs).Find(&users).Error; err != nil {
 
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
 		return
@@ -492,7 +502,7 @@ func UpdateKitHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch existing kit
 	var kit models.Kit
-	if err := config.DB.Where("id = ? AND is_deleted = ?", kitID, false).First(&kit).Error; err != nil {
+	if err := config.DB.WithContext(r.Context()).Where("id = ? AND is_deleted = ?", kitID, false).First(&kit).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgKitNotFound, nil)
 			return
@@ -502,9 +512,11 @@ func UpdateKitHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start a transaction
-	tx := config.DB.Begin()
+	tx := config.DB.WithContext(r.Context()).Begin()
 
 	// Update fields if provided
+	before := kitAuditState(&kit)
+	previousQuantity := kit.Quantity
 	if req.Type != nil {
 		kit.Type = *req.Type
 	}
@@ -536,6 +548,33 @@ func UpdateKitHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditKitUpdate,
+		EntityType: utils.AuditEntityKit,
+		EntityID:   kit.ID,
+		Before:     before,
+		After:      kitAuditState(&kit),
+	}); err != nil {
+		tx.Rollback()
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// Alert partners once, when the stock first falls to the threshold, not on every later change
+	threshold := config.AppConfig.KitLowStockThreshold
+	if previousQuantity > threshold && kit.Quantity <= threshold {
+		if err := utils.PublishWebhookEvent(tx, utils.WebhookKitStockLow, utils.WebhookKitStockData{
+			KitID:     kit.ID,
+			Type:      kit.Type,
+			Quantity:  kit.Quantity,
+			Threshold: threshold,
+		}); err != nil {
+			tx.Rollback()
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+	}
+
 	// Commit the transaction
 	tx.Commit()
 
@@ -554,7 +593,7 @@ func DeleteKitHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start a transaction
-	tx := config.DB.Begin()
+	tx := config.DB.WithContext(r.Context()).Begin()
 
 	// Check if kit exists and is not already deleted
 	var kit models.Kit
@@ -574,12 +613,25 @@ func DeleteKitHandler(w http.ResponseWriter, r *http.Request) {
 		"status":     false, // Also set status to inactive
 	}
 
+	before := kitAuditState(&kit)
 	if err := tx.Model(&kit).Updates(updates).Error; err != nil {
 		tx.Rollback()
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
 	}
 
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditKitDelete,
+		EntityType: utils.AuditEntityKit,
+		EntityID:   kit.ID,
+		Before:     before,
+		After:      updates,
+	}); err != nil {
+		tx.Rollback()
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
 	// Commit the transaction
 	tx.Commit()
 
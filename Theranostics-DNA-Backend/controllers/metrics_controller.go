@@ -0,0 +1,26 @@
+// controllers/metrics_controller.go
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/metrics"
+	"theransticslabs/m/utils"
+)
+
+var metricsHandler = metrics.Handler()
+
+// MetricsHandler serves Prometheus metrics. When a metrics token is configured the scraper must
+// send it as a bearer token.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if token := config.AppConfig.MetricsToken; token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUnauthorizedUser, nil)
+			return
+		}
+	}
+	metricsHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,152 @@
+// controllers/notification_preference_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
+	"theransticslabs/m/notifications"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// UpdateNotificationPreferencesRequest lists the choices to change; others are left as they are.
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []notifications.Preference `json:"preferences"`
+}
+
+// GetUserNotificationPreferencesHandler returns the signed-in user's notification preferences.
+func GetUserNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotAuthenticated, nil)
+		return
+	}
+
+	respondNotificationPreferences(w, r, notifications.RecipientUser, user.ID, utils.MsgNotificationPreferencesFetched)
+}
+
+// UpdateUserNotificationPreferencesHandler changes the signed-in user's notification preferences.
+func UpdateUserNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotAuthenticated, nil)
+		return
+	}
+
+	updateNotificationPreferences(w, r, notifications.RecipientUser, user.ID, utils.AuditEntityUser)
+}
+
+// GetCustomerNotificationPreferencesHandler returns a customer's notification preferences.
+func GetCustomerNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := getNotifiableCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	respondNotificationPreferences(w, r, notifications.RecipientCustomer, customer.ID, utils.MsgNotificationPreferencesFetched)
+}
+
+// UpdateCustomerNotificationPreferencesHandler changes a customer's notification preferences on
+// their behalf, e.g. when they ask support to stop text messages.
+func UpdateCustomerNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	customer, ok := getNotifiableCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	updateNotificationPreferences(w, r, notifications.RecipientCustomer, customer.ID, utils.AuditEntityCustomer)
+}
+
+// getNotifiableCustomer loads the customer named in the route, refusing erased customers.
+func getNotifiableCustomer(w http.ResponseWriter, r *http.Request) (*models.Customer, bool) {
+	customerID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidCustomerID, nil)
+		return nil, false
+	}
+
+	var customer models.Customer
+	if err := config.DB.WithContext(r.Context()).First(&customer, customerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgCustomerNotFound, nil)
+			return nil, false
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return nil, false
+	}
+	if customer.ErasedAt != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgCustomerNotFound, nil)
+		return nil, false
+	}
+	return &customer, true
+}
+
+func respondNotificationPreferences(w http.ResponseWriter, r *http.Request, recipientType string, recipientID uint, message string) {
+	preferences, err := notifications.Preferences(config.DB.WithContext(r.Context()), recipientType, recipientID)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, true, message, preferences)
+}
+
+func updateNotificationPreferences(w http.ResponseWriter, r *http.Request, recipientType string, recipientID uint, entityType string) {
+	var req UpdateNotificationPreferencesRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"preferences"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	if len(req.Preferences) == 0 {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgNoNotificationPreferences, nil)
+		return
+	}
+	for _, preference := range req.Preferences {
+		if err := preference.Validate(recipientType); err != nil {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidNotificationPreference, nil)
+			return
+		}
+	}
+
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		before, err := notifications.Preferences(tx, recipientType, recipientID)
+		if err != nil {
+			return err
+		}
+		if err := notifications.SetPreferences(tx, recipientType, recipientID, req.Preferences); err != nil {
+			return err
+		}
+		after, err := notifications.Preferences(tx, recipientType, recipientID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, r, utils.AuditEntry{
+			Action:     utils.AuditNotificationPrefs,
+			EntityType: entityType,
+			EntityID:   recipientID,
+			Before:     notificationPreferencesAuditState(before),
+			After:      notificationPreferencesAuditState(after),
+		})
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToUpdateNotificationPreferences, nil)
+		return
+	}
+
+	respondNotificationPreferences(w, r, recipientType, recipientID, utils.MsgNotificationPreferencesUpdated)
+}
+
+// notificationPreferencesAuditState keys each choice by "<event>.<channel>" for the audit diff.
+func notificationPreferencesAuditState(preferences []notifications.Preference) map[string]interface{} {
+	state := make(map[string]interface{}, len(preferences))
+	for _, preference := range preferences {
+		state[preference.Event+"."+preference.Channel] = preference.Enabled
+	}
+	return state
+}
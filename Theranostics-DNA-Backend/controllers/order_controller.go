@@ -3,38 +3,43 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"theransticslabs/m/config"
 	"theransticslabs/m/emails"
+	"theransticslabs/m/metrics"
 	"theransticslabs/m/models"
+	"theransticslabs/m/notifications"
 	"theransticslabs/m/utils"
 
-	"github.com/jung-kurt/gofpdf"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRequest struct {
-	FirstName          string `json:"first_name" form:"first_name" validate:"required,max=50,min=3"`
-	LastName           string `json:"last_name" form:"last_name" validate:"omitempty,max=50,min=3"`
-	Email              string `json:"email" form:"email" validate:"required,email,max=100"`
-	PhoneNumber        string `json:"phone_number" form:"phone_number" validate:"required,max=15,min=10"`
-	Country            string `json:"country" form:"country" validate:"required,max=50,min=3"`
-	StreetAddress      string `json:"street_address" form:"street_address" validate:"required,max=255,min=5"`
-	TownCity           string `json:"town_city" form:"town_city" validate:"required,max=100,min=5"`
-	Region             string `json:"region" form:"region" validate:"omitempty,max=100,min=3"`
-	Postcode           string `json:"postcode" form:"postcode" validate:"omitempty,max=20,min=3"`
-	ProductName        string `json:"product_name" form:"product_name" validate:"required,max=100,min=3"`
-	ProductDescription string `json:"product_description" form:"product_description" validate:"omitempty"`
-	ProductImage       string `json:"product_image" form:"product_image" validate:"omitempty,base64"`
-	ProductPrice       string `json:"product_price" form:"product_price" validate:"required,numeric,gt=0"`
-	Quantity           string `json:"quantity" form:"quantity" validate:"required,numeric,min=1"`
+	FirstName     string `json:"first_name" form:"first_name" validate:"required,max=50,min=3"`
+	LastName      string `json:"last_name" form:"last_name" validate:"omitempty,max=50,min=3"`
+	Email         string `json:"email" form:"email" validate:"required,email,max=100"`
+	PhoneNumber   string `json:"phone_number" form:"phone_number" validate:"required,max=15,min=10"`
+	Country       string `json:"country" form:"country" validate:"required,max=50,min=3"`
+	StreetAddress string `json:"street_address" form:"street_address" validate:"required,max=255,min=5"`
+	TownCity      string `json:"town_city" form:"town_city" validate:"required,max=100,min=5"`
+	Region        string `json:"region" form:"region" validate:"omitempty,max=100,min=3"`
+	Postcode      string `json:"postcode" form:"postcode" validate:"omitempty,max=20,min=3"`
+	OfferToken    string `json:"offer_token" form:"offer_token" validate:"required"`
+	Quantity      string `json:"quantity" form:"quantity" validate:"required,numeric,min=1"`
+	Language      string `json:"language" form:"language"` // Locale of the customer's emails; taken from Accept-Language when empty
+	// SMSNotifications opts a new customer in to or out of text messages about their orders.
+	// Anyone can place an order with any email address, so it is ignored for existing customers,
+	// who change their choice through staff instead.
+	SMSNotifications *bool `json:"sms_notifications"`
 }
 
 type PaymentResponse struct {
@@ -48,56 +53,114 @@ func OrderCreateHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req OrderRequest
 	allowedFields := []string{"first_name", "last_name", "email", "phone_number", "country",
-		"street_address", "town_city", "region", "postcode", "product_name",
-		"product_description", "product_image", "product_price", "quantity"}
+		"street_address", "town_city", "region", "postcode", "offer_token", "quantity", "language", "sms_notifications"}
 
 	if err := utils.ParseRequestBody(r, &req, allowedFields); err != nil {
 		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
 		return
 	}
-	log.Println(req)
 
 	if err := validateOrderRequest(&req); err != nil {
 		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
 		return
 	}
+	if req.Language == "" {
+		req.Language = emails.MatchLocale(r.Header.Get("Accept-Language"))
+	}
 
-	tx := config.DB.Begin()
+	tx := config.DB.WithContext(r.Context()).Begin()
 	if tx.Error != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToStartTransaction, nil)
 		return
 	}
 	defer tx.Rollback()
 
+	// 1. Resolve the signed product offer; the price always comes from the offer
+	offer, err := resolveProductOffer(tx, req.OfferToken)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	allowed, err := offerAllowsEmail(offer, req.Email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to match the product offer email", "error", err)
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if !allowed {
+		utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgProductOfferEmailMismatch, nil)
+		return
+	}
+
 	// 2. Process customer
-	customer, err := processCustomer(tx, &req)
+	customer, created, err := processCustomer(tx, &req)
 	if err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, fmt.Sprintf(utils.MsgFailedToProcessCustomer, err.Error()), nil)
 		return
 	}
 
+	if created && req.SMSNotifications != nil {
+		preferences := notifications.ChannelPreferences(notifications.RecipientCustomer, notifications.ChannelSMS, *req.SMSNotifications)
+		if err := notifications.SetPreferences(tx, notifications.RecipientCustomer, customer.ID, preferences); err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, fmt.Sprintf(utils.MsgFailedToProcessCustomer, err.Error()), nil)
+			return
+		}
+	}
+
 	// 3. Create order
-	order, err := processOrderDetails(tx, customer, &req)
+	order, err := processOrderDetails(tx, customer, offer, &req)
 	if err != nil {
-		tx.Rollback()
 		utils.JSONResponse(w, http.StatusInternalServerError, false, err.Error(), nil)
 		return
 	}
-	// 4. Initialize PayPal payment
-	paymentURL, paymentID, err := initializePayPalPayment(tx, order, customer)
-	if err != nil {
+	// 4. Record the pending payment. PayPal is only called once it is committed, so no row stays
+	// locked while waiting on it.
+	payment := &models.Payment{
+		OrderID:       order.ID,
+		PaymentStatus: utils.PaymentStatusPending,
+		Amount:        order.TotalPrice,
+	}
+	if err := tx.Create(payment).Error; err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, fmt.Sprintf(utils.MsgFailedToInitializePayment, err.Error()), nil)
 		return
 	}
 
+	if err := utils.PublishWebhookEvent(tx, utils.WebhookOrderCreated, utils.WebhookOrderData{
+		OrderID:       order.ID,
+		CustomerID:    order.CustomerID,
+		PaymentID:     payment.ID,
+		ProductName:   order.ProductName,
+		Quantity:      order.Quantity,
+		TotalPrice:    order.TotalPrice,
+		PaymentStatus: order.PaymentStatus,
+		OrderStatus:   order.OrderStatus,
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCommitTransaction, nil)
 		return
 	}
+	metrics.OrdersTotal.WithLabelValues(metrics.OrderCreated).Inc()
+
+	// 5. Initialize PayPal payment; an order the buyer can't pay for is cancelled straight away
+	paymentURL, err := initializePayPalPayment(config.DB.WithContext(r.Context()), payment, order, customer)
+	if err != nil {
+		if err := config.DB.WithContext(context.WithoutCancel(r.Context())).Transaction(func(tx *gorm.DB) error {
+			return FailPayment(tx, payment)
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to cancel an order whose payment couldn't be initialized", "payment_id", payment.ID, "error", err)
+		}
+		metrics.OrdersTotal.WithLabelValues(metrics.OrderFailed).Inc()
+		utils.JSONResponse(w, http.StatusInternalServerError, false, fmt.Sprintf(utils.MsgFailedToInitializePayment, err.Error()), nil)
+		return
+	}
 
 	utils.JSONResponse(w, http.StatusOK, true, utils.MsgOrderCreatedSuccessfully, PaymentResponse{
 		OrderID:    order.ID,
-		PaymentID:  paymentID,
+		PaymentID:  payment.ID,
 		PaymentURL: paymentURL,
 	})
 
@@ -134,24 +197,9 @@ func validateOrderRequest(req *OrderRequest) error {
 		return fmt.Errorf(utils.MsgInvalidPostcode)
 	}
 
-	// Product validations
-	if !utils.IsValidProductName(req.ProductName) {
-		return fmt.Errorf(utils.MsgInvalidProductName)
-	}
-
-	// Product description validation (optional)
-	if req.ProductDescription != "" && len(req.ProductDescription) > 1000 {
-		return fmt.Errorf(utils.MsgProductDescriptionTooLong)
-	}
-
-	// Product image validation (optional, base64)
-	if req.ProductImage != "" && !(utils.IsValidBase64Image(req.ProductImage) || utils.IsValidImageURL(req.ProductImage)) {
-		return fmt.Errorf(utils.MsgInvalidProductImage)
-	}
-
-	// Product price validation
-	if !utils.IsValidPrice(req.ProductPrice) {
-		return fmt.Errorf(utils.MsgInvalidProductPrice)
+	// Product offer validation
+	if strings.TrimSpace(req.OfferToken) == "" {
+		return fmt.Errorf(utils.MsgMissingProductOfferToken)
 	}
 
 	// Quantity validation
@@ -159,41 +207,58 @@ func validateOrderRequest(req *OrderRequest) error {
 		return fmt.Errorf(utils.MsgInvalidQuantity)
 	}
 
+	req.Language = strings.TrimSpace(req.Language)
+	if req.Language != "" && !emails.IsSupportedLocale(req.Language) {
+		return fmt.Errorf(utils.MsgUnsupportedLanguage)
+	}
+
 	return nil
 }
 
-func processCustomer(tx *gorm.DB, req *OrderRequest) (*models.Customer, error) {
+// processCustomer finds the customer placing the order by email, creating them when they are new,
+// and reports whether they were created.
+func processCustomer(tx *gorm.DB, req *OrderRequest) (*models.Customer, bool, error) {
+	// Email is encrypted at rest, so look the customer up by its blind index
+	emailHash, err := utils.BlindIndex(req.Email)
+	if err != nil {
+		return nil, false, err
+	}
+
 	var customer models.Customer
-	result := tx.Where("email = ?", req.Email).First(&customer)
+	result := tx.Where("email_hash = ?", emailHash).First(&customer)
 
 	if result.Error == gorm.ErrRecordNotFound {
 		customer = models.Customer{
 			FirstName:     req.FirstName,
 			LastName:      req.LastName,
 			Email:         req.Email,
+			EmailHash:     emailHash,
 			PhoneNumber:   req.PhoneNumber,
 			Country:       req.Country,
 			StreetAddress: req.StreetAddress,
 			TownCity:      req.TownCity,
 			Region:        req.Region,
 			Postcode:      req.Postcode,
+			Language:      req.Language,
 		}
 		if err := tx.Create(&customer).Error; err != nil {
-			return nil, err
+			return nil, false, err
 		}
+		return &customer, true, nil
 	} else if result.Error != nil {
-		return nil, result.Error
+		return nil, false, result.Error
+	} else if customer.Language != req.Language {
+		// Emails follow the language of the customer's latest order
+		customer.Language = req.Language
+		if err := tx.Model(&customer).Update("language", customer.Language).Error; err != nil {
+			return nil, false, err
+		}
 	}
 
-	return &customer, nil
+	return &customer, false, nil
 }
 
-func processOrderDetails(tx *gorm.DB, customer *models.Customer, req *OrderRequest) (*models.Order, error) {
-	price, err := strconv.ParseFloat(req.ProductPrice, 64)
-	if err != nil {
-		return nil, fmt.Errorf(utils.MsgInvalidPriceFormat)
-	}
-
+func processOrderDetails(tx *gorm.DB, customer *models.Customer, offer *models.ProductOffer, req *OrderRequest) (*models.Order, error) {
 	quantity, err := strconv.Atoi(req.Quantity)
 	if err != nil {
 		return nil, fmt.Errorf(utils.MsgInvalidQuantityFormat)
@@ -201,16 +266,18 @@ func processOrderDetails(tx *gorm.DB, customer *models.Customer, req *OrderReque
 
 	order := models.Order{
 		CustomerID:         customer.ID,
-		ProductName:        req.ProductName,
-		ProductDescription: req.ProductDescription,
-		ProductImage:       req.ProductImage,
-		ProductPrice:       price,
+		ProductOfferID:     &offer.ID,
+		ProductName:        offer.ProductName,
+		ProductDescription: offer.ProductDescription,
+		ProductImage:       offer.ProductImage,
+		ProductPrice:       offer.ProductPrice,
 		Quantity:           quantity,
-		TotalPrice:         price * float64(quantity),
-		PaymentStatus:      "Pending",
-		OrderStatus:        "Pending",
+		TotalPrice:         offer.ProductPrice * float64(quantity),
+		PaymentStatus:      utils.PaymentStatusPending,
+		OrderStatus:        utils.OrderStatusPending,
 	}
 
+	// The order only counts against the offer's usage limit once it is paid
 	if err := tx.Create(&order).Error; err != nil {
 		return nil, err
 	}
@@ -218,34 +285,24 @@ func processOrderDetails(tx *gorm.DB, customer *models.Customer, req *OrderReque
 	return &order, nil
 }
 
-func initializePayPalPayment(tx *gorm.DB, order *models.Order, customer *models.Customer) (string, uint, error) {
-	// Create payment record
-	payment := &models.Payment{
-		OrderID:       order.ID,
-		PaymentStatus: "Pending",
-		Amount:        order.TotalPrice,
-	}
-	if err := tx.Create(payment).Error; err != nil {
-		return "", 0, err
-	}
-
-	// Initialize PayPal payment
+// initializePayPalPayment creates the PayPal order for a committed payment, stores its ID on the
+// payment and returns the URL where the buyer approves it.
+func initializePayPalPayment(db *gorm.DB, payment *models.Payment, order *models.Order, customer *models.Customer) (string, error) {
 	accessToken, err := utils.GetPayPalAccessToken()
 	if err != nil {
-		return "", 0, err
+		return "", err
 	}
 
 	paypalOrder, err := utils.CreatePayPalOrder(payment.ID, order.TotalPrice, accessToken, order, customer)
 	if err != nil {
-		log.Println(err)
-
-		return "", 0, err
+		slog.ErrorContext(db.Statement.Context, "failed to create PayPal order", "payment_id", payment.ID, "error", err)
+		return "", err
 	}
 
 	// Update payment with PayPal transaction ID
 	payment.TransactionID = paypalOrder.ID
-	if err := tx.Save(payment).Error; err != nil {
-		return "", 0, err
+	if err := db.Model(payment).Update("transaction_id", payment.TransactionID).Error; err != nil {
+		return "", err
 	}
 
 	// Get PayPal approval URL
@@ -257,48 +314,106 @@ func initializePayPalPayment(tx *gorm.DB, order *models.Order, customer *models.
 		}
 	}
 
-	return approvalURL, payment.ID, nil
+	return approvalURL, nil
 }
 
 func HandlePaymentSuccess(w http.ResponseWriter, r *http.Request) {
-	paymentID := r.URL.Query().Get("payment_id")
+	paymentID, err := strconv.ParseUint(r.URL.Query().Get("payment_id"), 10, 32)
 	paypalOrderID := r.URL.Query().Get("token")
 
-	if paymentID == "" || paypalOrderID == "" {
+	if err != nil || paypalOrderID == "" {
 		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingPaymentInformation, nil)
 		return
 	}
 
-	tx := config.DB.Begin()
+	// Keep the request ID but not the cancellation: once PayPal has captured the payment the
+	// database updates must finish even if the buyer closes the page
+	db := config.DB.WithContext(context.WithoutCancel(r.Context()))
+
+	// 1. Take a use of the order's offer before the money is. The reservation is committed
+	// before PayPal is called, so neither the payment nor the offer stays locked meanwhile.
+	tx := db.Begin()
 	if tx.Error != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToStartTransactionAgain, nil)
 		return
 	}
 	defer tx.Rollback()
 
-	// Verify and capture PayPal payment
-	if err := captureAndVerifyPayment(paypalOrderID); err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, fmt.Sprintf(utils.MsgPaymentVerificationFailed, err.Error()), nil)
+	// The PayPal order must be the one created for this payment, so a capture can't be replayed
+	// against another order
+	payment, err := LockPayment(tx, uint(paymentID))
+	if err != nil || payment.TransactionID != paypalOrderID {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgPaymentNotFound, nil)
 		return
 	}
 
-	// Update payment and order status
-	if err := updatePaymentAndOrderStatus(tx, paymentID); err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, err.Error(), nil)
+	// A repeated redirect, or a payment already settled by reconciliation, has nothing left to do
+	if payment.PaymentStatus == utils.PaymentStatusCompleted {
+		utils.JSONResponse(w, http.StatusOK, true, utils.MsgPaymentCompletedSuccessfully, nil)
 		return
 	}
 
-	// Generate invoice and send emails
-	if err := handleSuccessfulPayment(tx, paymentID); err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, err.Error(), nil)
+	// Unpaid orders don't hold a use of their offer, so it may have run out since this one was
+	// placed
+	reserved, err := ReserveOfferUse(tx, payment)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCompletePaymentProcess, nil)
+		return
+	}
+	if !reserved {
+		if err := FailPayment(tx, payment); err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, err.Error(), nil)
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCompletePaymentProcess, nil)
+			return
+		}
+		metrics.OrdersTotal.WithLabelValues(metrics.OrderFailed).Inc()
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgProductOfferExhausted, nil)
 		return
 	}
-
 	if err := tx.Commit().Error; err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCompletePaymentProcess, nil)
 		return
 	}
 
+	// 2. Verify and capture PayPal payment, giving the use back if it isn't taken
+	if err := captureAndVerifyPayment(paypalOrderID); err != nil {
+		if err := ReleaseOfferUse(db, payment.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to release a product offer use", "payment_id", payment.ID, "error", err)
+		}
+		metrics.OrdersTotal.WithLabelValues(metrics.OrderFailed).Inc()
+		utils.JSONResponse(w, http.StatusInternalServerError, false, fmt.Sprintf(utils.MsgPaymentVerificationFailed, err.Error()), nil)
+		return
+	}
+
+	// 3. Update payment and order status, generate invoice and send emails
+	var invoice *models.Invoice
+	err = db.Transaction(func(tx *gorm.DB) error {
+		locked, err := LockPayment(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		// Reconciliation may have completed it while PayPal was capturing it
+		if locked.PaymentStatus == utils.PaymentStatusCompleted {
+			return nil
+		}
+		invoice, err = CompletePayment(tx, locked)
+		return err
+	})
+	if err != nil {
+		if invoice != nil {
+			utils.RemoveInvoicePDF(invoice)
+		}
+		slog.ErrorContext(r.Context(), "Failed to complete a captured payment", "payment_id", payment.ID, "error", err)
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCompletePaymentProcess, nil)
+		return
+	}
+	if invoice != nil {
+		metrics.OrdersTotal.WithLabelValues(metrics.OrderPaid).Inc()
+	}
+
 	utils.JSONResponse(w, http.StatusOK, true, utils.MsgPaymentCompletedSuccessfully, nil)
 }
 
@@ -310,14 +425,96 @@ func captureAndVerifyPayment(paypalOrderID string) error {
 	return utils.CapturePayPalPayment(paypalOrderID, accessToken)
 }
 
-func updatePaymentAndOrderStatus(tx *gorm.DB, paymentID string) error {
+// ReserveOfferUse counts the payment's order against its offer's usage limit before the payment
+// is captured, and reports false when the offer has no use left. The order keeps the use until
+// ReleaseOfferUse gives it back or the payment fails. Orders placed without an offer, or that
+// already hold a use, have nothing to reserve.
+func ReserveOfferUse(tx *gorm.DB, payment *models.Payment) (bool, error) {
+	var order models.Order
+	if err := tx.First(&order, payment.OrderID).Error; err != nil {
+		return false, err
+	}
+	if order.ProductOfferID == nil || order.OfferUseCounted {
+		return true, nil
+	}
+
+	// The offer is locked so concurrent payments can't both take its last use
+	var offer models.ProductOffer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, *order.ProductOfferID).Error; err != nil {
+		return false, err
+	}
+	if offer.MaxUses > 0 && offer.UsedCount >= offer.MaxUses {
+		return false, nil
+	}
+	return true, countOfferUse(tx, &order)
+}
+
+// ReleaseOfferUse gives back the offer use reserved for a payment whose capture failed, unless
+// the payment was settled meanwhile.
+func ReleaseOfferUse(db *gorm.DB, paymentID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		payment, err := LockPayment(tx, paymentID)
+		if err != nil {
+			return err
+		}
+		if payment.PaymentStatus != utils.PaymentStatusPending {
+			return nil
+		}
+		var order models.Order
+		if err := tx.First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+		return releaseOfferUse(tx, &order)
+	})
+}
+
+// countOfferUse takes one of the offer's uses for the order.
+func countOfferUse(tx *gorm.DB, order *models.Order) error {
+	if err := tx.Model(&models.ProductOffer{}).Where("id = ?", *order.ProductOfferID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return err
+	}
+	order.OfferUseCounted = true
+	return tx.Model(order).UpdateColumn("offer_use_counted", true).Error
+}
+
+// releaseOfferUse gives back the offer use the order holds, if any.
+func releaseOfferUse(tx *gorm.DB, order *models.Order) error {
+	if order.ProductOfferID == nil || !order.OfferUseCounted {
+		return nil
+	}
+	if err := tx.Model(&models.ProductOffer{}).Where("id = ?", *order.ProductOfferID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+		return err
+	}
+	order.OfferUseCounted = false
+	return tx.Model(order).UpdateColumn("offer_use_counted", false).Error
+}
+
+// LockPayment loads a payment for update, so concurrent attempts to settle it are serialised.
+func LockPayment(tx *gorm.DB, paymentID uint) (*models.Payment, error) {
 	var payment models.Payment
-	if err := tx.First(&payment, paymentID).Error; err != nil {
-		return fmt.Errorf(utils.MsgPaymentNotFound)
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+		return nil, err
 	}
+	return &payment, nil
+}
+
+// CompletePayment marks a captured payment and its order as paid, stores the invoice and queues
+// the confirmation emails. The payment must have been locked with LockPayment. The invoice PDF is
+// already written, so delete it with utils.RemoveInvoicePDF if tx then fails to commit.
+func CompletePayment(tx *gorm.DB, payment *models.Payment) (*models.Invoice, error) {
+	if err := updatePaymentAndOrderStatus(tx, payment); err != nil {
+		return nil, err
+	}
+	return handleSuccessfulPayment(tx, payment)
+}
 
-	payment.PaymentStatus = "Completed"
-	if err := tx.Save(&payment).Error; err != nil {
+// FailPayment marks a payment that was never captured as failed, cancels its order and gives
+// back the offer use the order held.
+func FailPayment(tx *gorm.DB, payment *models.Payment) error {
+	payment.PaymentStatus = utils.PaymentStatusFailed
+	if err := tx.Save(payment).Error; err != nil {
 		return fmt.Errorf(utils.MsgFailedToUpdatePayment)
 	}
 
@@ -326,109 +523,145 @@ func updatePaymentAndOrderStatus(tx *gorm.DB, paymentID string) error {
 		return fmt.Errorf(utils.MsgOrderNotFound)
 	}
 
-	order.PaymentStatus = "Completed"
-	order.OrderStatus = "Processing"
-	return tx.Save(&order).Error
-}
-
-func handleSuccessfulPayment(tx *gorm.DB, paymentID string) error {
-	var (
-		payment  models.Payment
-		order    models.Order
-		customer models.Customer
-	)
+	if err := releaseOfferUse(tx, &order); err != nil {
+		return err
+	}
 
-	// Get all necessary data
-	if err := tx.First(&payment, paymentID).Error; err != nil {
+	previousStatus := order.OrderStatus
+	order.PaymentStatus = utils.PaymentStatusFailed
+	order.OrderStatus = utils.OrderStatusCancelled
+	if err := tx.Model(&order).Select("PaymentStatus", "OrderStatus").Updates(&order).Error; err != nil {
 		return err
 	}
+	return publishPaymentWebhooks(tx, utils.WebhookPaymentFailed, payment, &order, previousStatus)
+}
+
+func updatePaymentAndOrderStatus(tx *gorm.DB, payment *models.Payment) error {
+	payment.PaymentStatus = utils.PaymentStatusCompleted
+	if err := tx.Save(payment).Error; err != nil {
+		return fmt.Errorf(utils.MsgFailedToUpdatePayment)
+	}
+
+	var order models.Order
 	if err := tx.First(&order, payment.OrderID).Error; err != nil {
-		return err
+		return fmt.Errorf(utils.MsgOrderNotFound)
 	}
-	if err := tx.First(&customer, order.CustomerID).Error; err != nil {
+
+	previousStatus := order.OrderStatus
+	order.PaymentStatus = utils.PaymentStatusCompleted
+	order.OrderStatus = utils.OrderStatusProcessing
+	if err := tx.Save(&order).Error; err != nil {
 		return err
 	}
+	return publishPaymentWebhooks(tx, utils.WebhookPaymentCompleted, payment, &order, previousStatus)
+}
 
-	// Generate invoice
-	invoicePath, err := generateInvoice(&payment, &customer)
-	if err != nil {
+// publishPaymentWebhooks tells partners that a payment was settled and, when it moved the order
+// on, that the order's status changed.
+func publishPaymentWebhooks(tx *gorm.DB, event string, payment *models.Payment, order *models.Order, previousStatus string) error {
+	if err := utils.PublishWebhookEvent(tx, event, utils.WebhookPaymentData{
+		PaymentID:     payment.ID,
+		OrderID:       order.ID,
+		Amount:        payment.Amount,
+		PaymentStatus: payment.PaymentStatus,
+	}); err != nil {
 		return err
 	}
+	if previousStatus == order.OrderStatus {
+		return nil
+	}
+	return utils.PublishWebhookEvent(tx, utils.WebhookOrderStatusChanged, utils.WebhookOrderStatusData{
+		OrderID:        order.ID,
+		PreviousStatus: previousStatus,
+		OrderStatus:    order.OrderStatus,
+	})
+}
 
-	// Save invoice
-	invoice := models.Invoice{
-		PaymentID:   payment.ID,
-		InvoiceLink: invoicePath,
-		Price:       payment.Amount,
-		InvoiceID:   strconv.FormatUint(uint64(payment.ID), 10),
+func handleSuccessfulPayment(tx *gorm.DB, payment *models.Payment) (*models.Invoice, error) {
+	var (
+		order    models.Order
+		customer models.Customer
+	)
+
+	// Get all necessary data
+	if err := tx.First(&order, payment.OrderID).Error; err != nil {
+		return nil, err
 	}
-	if err := tx.Create(&invoice).Error; err != nil {
-		return err
+	if err := tx.First(&customer, order.CustomerID).Error; err != nil {
+		return nil, err
 	}
 
-	// Send emails
-	return sendConfirmationEmails(&customer, &order, &invoice)
-}
-
-func generateInvoice(payment *models.Payment, customer *models.Customer) (string, error) {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	// Add invoice content
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(40, 10, "Invoice")
-	pdf.Ln(20)
-
-	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(40, 10, fmt.Sprintf("Invoice ID: %d", payment.ID))
-	pdf.Ln(10)
-	pdf.Cell(40, 10, fmt.Sprintf("Customer: %s %s", customer.FirstName, customer.LastName))
-	pdf.Ln(10)
-	pdf.Cell(40, 10, fmt.Sprintf("Amount: $%.2f", payment.Amount))
-	pdf.Ln(10)
-	pdf.Cell(40, 10, fmt.Sprintf("Date: %s", time.Now().Format("2006-01-02")))
-
-	// Save PDF
-	invoicePath := filepath.Join("public/invoices", fmt.Sprintf("invoice_%d.pdf", payment.ID))
-	if err := os.MkdirAll(filepath.Dir(invoicePath), 0755); err != nil {
-		return "", err
+	// Count the paid order against its offer's usage limit unless it already holds a use. It
+	// doesn't when reconciliation finds a capture that was never recorded, whose reservation was
+	// given back; the money has been taken, so it is counted even past the limit.
+	if order.ProductOfferID != nil && !order.OfferUseCounted {
+		var offer models.ProductOffer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&offer, *order.ProductOfferID).Error; err != nil {
+			return nil, err
+		}
+		if offer.MaxUses > 0 && offer.UsedCount >= offer.MaxUses {
+			slog.WarnContext(tx.Statement.Context, "Paid order exceeds its product offer's usage limit", "order_id", order.ID, "product_offer_id", offer.ID)
+		}
+		if err := countOfferUse(tx, &order); err != nil {
+			return nil, err
+		}
 	}
 
-	// Generate the PDF and save to file
-	if err := pdf.OutputFileAndClose(invoicePath); err != nil {
-		return "", err
+	// Issue the invoice
+	invoice, err := utils.IssueInvoice(tx, payment, &order, &customer, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	return filepath.Join("invoices", fmt.Sprintf("invoice_%d.pdf", payment.ID)), nil
+	// Queue notifications
+	if err := queueConfirmationNotifications(tx, &customer, &order, invoice); err != nil {
+		utils.RemoveInvoicePDF(invoice)
+		return nil, err
+	}
+	return invoice, nil
 }
 
-func sendConfirmationEmails(customer *models.Customer, order *models.Order, invoice *models.Invoice) error {
-	// Send customer email
-	customerEmail := emails.CustomerOrderConfirmationEmail(
-		customer.FirstName,
-		customer.LastName,
-		order.ProductName,
-		order.Quantity,
-		order.TotalPrice,
-		invoice.InvoiceLink,
-		config.AppConfig.AppUrl,
-	)
-	if err := config.SendEmail([]string{customer.Email}, "Order Confirmation", customerEmail); err != nil {
+// queueConfirmationNotifications notifies the customer through their chosen channels and emails
+// the admin, if one is configured.
+func queueConfirmationNotifications(tx *gorm.DB, customer *models.Customer, order *models.Order, invoice *models.Invoice) error {
+	invoiceURL := strings.TrimRight(config.AppConfig.ApiUrl, "/") + "/" + strings.TrimLeft(invoice.InvoiceLink, "/")
+
+	// Notify the customer
+	if err := notifications.Dispatch(tx, notifications.Notification{
+		Event:     notifications.EventOrderConfirmed,
+		Recipient: notifications.Customer(customer),
+		Data: emails.OrderConfirmationData{
+			FirstName:   customer.FirstName,
+			LastName:    customer.LastName,
+			ProductName: order.ProductName,
+			Quantity:    order.Quantity,
+			TotalPrice:  order.TotalPrice,
+			InvoiceURL:  invoiceURL,
+		},
+		Attachments: []models.OutboxAttachment{{
+			Filename:    fmt.Sprintf("invoice_%s.pdf", invoice.InvoiceID),
+			Path:        filepath.Join("public", invoice.InvoiceLink),
+			ContentType: "application/pdf",
+		}},
+	}); err != nil {
 		return err
 	}
 
-	// Send admin notification if configured
-	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
-		adminEmail := emails.NewOrderNotificationEmail(
-			customer.FirstName,
-			customer.LastName,
-			customer.Email,
-			order.ProductName,
-			order.Quantity,
-			order.TotalPrice,
-			invoice.InvoiceLink,
-		)
-		if err := config.SendEmail([]string{adminEmail}, "New Order Received", adminEmail); err != nil {
+	// Queue admin notification if configured
+	if adminAddress := config.AppConfig.AdminEmail; adminAddress != "" {
+		adminEmail, err := emails.Render(emails.TemplateOrderNotification, emails.DefaultLocale, emails.OrderNotificationData{
+			FirstName:   customer.FirstName,
+			LastName:    customer.LastName,
+			Email:       customer.Email,
+			ProductName: order.ProductName,
+			Quantity:    order.Quantity,
+			TotalPrice:  order.TotalPrice,
+			InvoiceURL:  invoiceURL,
+		})
+		if err != nil {
+			return err
+		}
+		if err := utils.QueueEmail(tx, []string{adminAddress}, adminEmail.Subject, adminEmail.HTML); err != nil {
 			return err
 		}
 	}
@@ -1,57 +1,176 @@
 package controllers
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
 	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultProductOfferTTLHours = 72
+	maxProductOfferTTLHours     = 24 * 30
 )
 
+// ProductOfferRequest is the payload staff send to mint a signed product offer.
+type ProductOfferRequest struct {
+	Name           string  `json:"name" validate:"required" form:"name"`
+	Description    string  `json:"description" form:"description"`
+	Image          string  `json:"image" form:"image"`
+	Price          float64 `json:"price" validate:"required,gt=0" form:"price"`
+	ExpiresInHours int     `json:"expires_in_hours" form:"expires_in_hours"`
+	MaxUses        int     `json:"max_uses" form:"max_uses"`
+	CustomerEmail  string  `json:"customer_email" form:"customer_email"`
+}
+
+// ProductOfferResponse is returned after an offer has been minted.
+type ProductOfferResponse struct {
+	OfferID   uint      `json:"offer_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Product is the product information resolved from a valid offer token.
 type Product struct {
-	Name        string  `json:"name" validate:"required" form:"name"`
-	Description string  `json:"description" form:"description"`
-	Image       string  `json:"image" form:"image"`
-	Price       float64 `json:"price" validate:"required,gt=0" form:"price"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Image       string    `json:"image"`
+	Price       float64   `json:"price"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
 
 type EncryptedData struct {
 	Data string `json:"data" validate:"required" form:"data"`
 }
 
+// EncryptProductDetails mints a signed, expiring product offer token. Only authorised staff can call it.
 func EncryptProductDetails(w http.ResponseWriter, r *http.Request) {
-	// Parse the request body
-	var req Product
+	// 1. Get the user from the context (set by AuthMiddleware)
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotAuthenticated, nil)
+		return
+	}
 
-	// Use the common request parser
-	// Define allowed fields for this request
-	allowedFields := []string{"name", "description", "image", "price"}
+	// 2. Parse the request body
+	var req ProductOfferRequest
+	allowedFields := []string{"name", "description", "image", "price", "expires_in_hours", "max_uses", "customer_email"}
+	if err := utils.ParseRequestBody(r, &req, allowedFields); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
 
-	err := utils.ParseRequestBody(r, &req, allowedFields) // Assuming this validates required fields
-	if err != nil {
+	req.Name = strings.TrimSpace(req.Name)
+	req.CustomerEmail = strings.TrimSpace(strings.ToLower(req.CustomerEmail))
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultProductOfferTTLHours
+	}
+
+	// 3. Validate the offer
+	if err := validateProductOfferRequest(&req); err != nil {
 		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
 		return
 	}
 
-	// Marshal struct to JSON
-	jsonData, err := json.Marshal(req)
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// 4. Store the offer so usage limits and revocation can be enforced
+	var customerEmailHash string
+	if req.CustomerEmail != "" {
+		if customerEmailHash, err = utils.BlindIndex(req.CustomerEmail); err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+	}
+	now := time.Now().UTC()
+	offer := models.ProductOffer{
+		Nonce:              nonce,
+		ProductName:        req.Name,
+		ProductDescription: req.Description,
+		ProductImage:       req.Image,
+		ProductPrice:       req.Price,
+		CustomerEmail:      req.CustomerEmail,
+		CustomerEmailHash:  customerEmailHash,
+		MaxUses:            req.MaxUses,
+		IssuedAt:           now,
+		ExpiresAt:          now.Add(time.Duration(req.ExpiresInHours) * time.Hour),
+		CreatedBy:          user.ID,
+	}
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&offer).Error; err != nil {
+			return err
+		}
+		// The customer email restriction is PII and stays out of the audit log
+		return utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditProductOfferCreate,
+			EntityType: utils.AuditEntityProductOffer,
+			EntityID:   offer.ID,
+			After: map[string]interface{}{
+				"product_name":  offer.ProductName,
+				"product_price": offer.ProductPrice,
+				"max_uses":      offer.MaxUses,
+				"expires_at":    offer.ExpiresAt,
+			},
+		})
+	})
 	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgErrorEncodingProductDetails, nil)
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
 		return
 	}
 
-	// Encrypt the JSON string
-	encryptedData, err := utils.Encrypt(string(jsonData))
+	// 5. Sign the token
+	token, err := utils.GenerateProductOfferToken(offer)
 	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgErrorEncryptingProductDetails, map[string]string{"error": err.Error()})
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgErrorSigningProductOffer, nil)
 		return
 	}
-	log.Println(req)
-	// Success response
-	utils.JSONResponse(w, http.StatusOK, true, utils.MsgProductDetailsEncryptedSuccessfully, encryptedData)
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgProductOfferCreatedSuccessfully, ProductOfferResponse{
+		OfferID:   offer.ID,
+		Token:     token,
+		ExpiresAt: offer.ExpiresAt,
+	})
 }
 
-// VerifyProduct validates and verifies an encrypted product data request
+// validateProductOfferRequest validates the product and offer restrictions
+func validateProductOfferRequest(req *ProductOfferRequest) error {
+	if req.Name == "" || !utils.IsValidProductName(req.Name) {
+		return errors.New(utils.MsgInvalidProductName)
+	}
+	if req.Price <= 0 || !utils.IsValidPrice(strconv.FormatFloat(req.Price, 'f', -1, 64)) {
+		return errors.New(utils.MsgInvalidProductPrice)
+	}
+	if len(req.Description) > 1000 {
+		return errors.New(utils.MsgProductDescriptionTooLong)
+	}
+	if req.Image != "" && !(utils.IsValidBase64Image(req.Image) || utils.IsValidImageURL(req.Image)) {
+		return errors.New(utils.MsgInvalidImageFormat)
+	}
+	if req.ExpiresInHours < 1 || req.ExpiresInHours > maxProductOfferTTLHours {
+		return errors.New(utils.MsgInvalidProductOfferExpiry)
+	}
+	if req.MaxUses < 0 {
+		return errors.New(utils.MsgInvalidProductOfferMaxUses)
+	}
+	if req.CustomerEmail != "" && !utils.IsValidEmail(req.CustomerEmail) {
+		return errors.New(utils.MsgInvalidEmailFormat)
+	}
+	return nil
+}
+
+// VerifyProduct validates a product offer token and returns the product it grants
 func VerifyProduct(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body into EncryptedData struct
 	var req EncryptedData
@@ -67,49 +186,63 @@ func VerifyProduct(w http.ResponseWriter, r *http.Request) {
 
 	// Validate that 'data' is present and non-empty in the request
 	if req.Data == "" {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingOrEmptyEncryptedDataParameter, nil)
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingProductOfferToken, nil)
 		return
 	}
 
-	// Attempt to decrypt the encrypted data
-	decryptedData, err := utils.Decrypt(req.Data)
+	offer, err := resolveProductOffer(config.DB.WithContext(r.Context()), req.Data)
 	if err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgFailedToDecryptData, map[string]string{"error": err.Error()})
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
 		return
 	}
 
-	// Unmarshal decrypted JSON into a Product struct
-	var product Product
-	if err := json.Unmarshal([]byte(decryptedData), &product); err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidProductDataFormat, map[string]string{"error": err.Error()})
-		return
-	}
+	// Return the validated product data
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgProductVerifiedSuccessfully, Product{
+		Name:        offer.ProductName,
+		Description: offer.ProductDescription,
+		Image:       offer.ProductImage,
+		Price:       offer.ProductPrice,
+		ExpiresAt:   offer.ExpiresAt,
+	})
+}
 
-	// Validate required fields in the decrypted product data
-	// Validate product name
-	if product.Name == "" || !utils.IsValidProductName(product.Name) {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidProductName, nil)
-		return
+// resolveProductOffer verifies an offer token and loads the matching, still-usable offer.
+// Placing an order doesn't use up the offer, so it isn't locked; a use is only reserved once the
+// order is paid for.
+func resolveProductOffer(db *gorm.DB, token string) (*models.ProductOffer, error) {
+	claims, err := utils.ValidateProductOfferToken(token)
+	if err != nil {
+		return nil, errors.New(utils.MsgInvalidProductOfferToken)
 	}
 
-	// Validate product price
-	if product.Price <= 0 || !utils.IsValidPrice(strconv.FormatFloat(product.Price, 'f', 2, 64)) {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgProductPriceMustBeGreaterThanZero, product)
-		return
+	var offer models.ProductOffer
+	if err := db.Where("nonce = ? AND is_deleted = ?", claims.ID, false).First(&offer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New(utils.MsgProductOfferNotFound)
+		}
+		return nil, err
 	}
 
-	// Validate product description if provided
-	if product.Description != "" && len(product.Description) > 1000 {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgProductDescriptionTooLong, nil)
-		return
+	if time.Now().After(offer.ExpiresAt) {
+		return nil, errors.New(utils.MsgInvalidProductOfferToken)
 	}
-
-	// Validate product image as either base64 or URL
-	if product.Image != "" && !(utils.IsValidBase64Image(product.Image) || utils.IsValidImageURL(product.Image)) {
-		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidImageFormat, nil)
-		return
+	if offer.MaxUses > 0 && offer.UsedCount >= offer.MaxUses {
+		return nil, errors.New(utils.MsgProductOfferExhausted)
 	}
 
-	// Return the decrypted and validated product data
-	utils.JSONResponse(w, http.StatusOK, true, utils.MsgProductVerifiedSuccessfully, product)
+	return &offer, nil
+}
+
+// offerAllowsEmail reports whether an order for the email may use the offer. Offers bound to an
+// email are matched on its blind index, or on the decrypted email for offers minted before the
+// index was stored.
+func offerAllowsEmail(offer *models.ProductOffer, email string) (bool, error) {
+	if offer.CustomerEmailHash == "" {
+		return offer.CustomerEmail == "" || strings.EqualFold(offer.CustomerEmail, strings.TrimSpace(email)), nil
+	}
+	emailHash, err := utils.BlindIndex(email)
+	if err != nil {
+		return false, err
+	}
+	return emailHash == offer.CustomerEmailHash, nil
 }
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+)
+
+func TestOfferAllowsEmail(t *testing.T) {
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	config.AppConfig.BlindIndexKey = base64.StdEncoding.EncodeToString(key)
+
+	janeHash, err := utils.BlindIndex("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		offer models.ProductOffer
+		email string
+		want  bool
+	}{
+		{"unbound", models.ProductOffer{}, "anyone@example.com", true},
+		{"hash matches", models.ProductOffer{CustomerEmail: "jane@example.com", CustomerEmailHash: janeHash}, "jane@example.com", true},
+		{"hash matches after normalising", models.ProductOffer{CustomerEmail: "jane@example.com", CustomerEmailHash: janeHash}, " Jane@Example.com ", true},
+		{"hash differs", models.ProductOffer{CustomerEmail: "jane@example.com", CustomerEmailHash: janeHash}, "john@example.com", false},
+		{"legacy offer matches", models.ProductOffer{CustomerEmail: "jane@example.com"}, "JANE@example.com", true},
+		{"legacy offer differs", models.ProductOffer{CustomerEmail: "jane@example.com"}, "john@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := offerAllowsEmail(&tt.offer, tt.email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("offerAllowsEmail = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"theransticslabs/m/utils"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type ResetPasswordRequest struct {
@@ -73,10 +74,27 @@ func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update user's hashed password and remove the token
+	// Update user's hashed password and sign out every other device
 	user.HashPassword = hashedPassword
-	user.Token = ""
-	if err := config.DB.Save(user).Error; err != nil {
+	var currentSessionID uint
+	if session, ok := middlewares.GetSessionFromContext(r.Context()); ok {
+		currentSessionID = session.ID
+	}
+
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		if err := utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditAccountPasswordReset,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   user.ID,
+		}); err != nil {
+			return err
+		}
+		return utils.RevokeUserSessions(tx, user.ID, currentSessionID, utils.SessionRevokedPasswordReset)
+	})
+	if err != nil {
 		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToUpdateNewPassword, nil)
 		return
 	}
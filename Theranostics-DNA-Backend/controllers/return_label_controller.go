@@ -0,0 +1,196 @@
+// controllers/return_label_controller.go
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetReturnLabelsHandler lists an order's return labels, one per kit.
+func GetReturnLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidOrderID, nil)
+		return
+	}
+
+	db := config.DB.WithContext(r.Context())
+	var order models.Order
+	if err := db.Select("id").First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgOrderNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	var labels []models.ReturnLabel
+	if err := db.Where("order_id = ?", order.ID).Order("unit").Find(&labels).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgReturnLabelsFetched, labels)
+}
+
+// CreateReturnLabelsHandler creates the return labels of a paid order's kits. Labels that
+// already exist keep their kit serials, so calling it again is harmless.
+func CreateReturnLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidOrderID, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	// Lock the order so two requests can't both create the missing labels
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgOrderNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if order.PaymentStatus != utils.PaymentStatusCompleted || order.OrderStatus == utils.OrderStatusCancelled {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgOrderNotShippable, nil)
+		return
+	}
+
+	var before int64
+	if err := tx.Model(&models.ReturnLabel{}).Where("order_id = ?", order.ID).Count(&before).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveReturnLabels, nil)
+		return
+	}
+	labels, err := utils.EnsureReturnLabels(tx, &order)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveReturnLabels, nil)
+		return
+	}
+	if len(labels) > int(before) {
+		if err := recordAudit(tx, r, utils.AuditEntry{
+			Action:     utils.AuditReturnLabelCreate,
+			EntityType: utils.AuditEntityOrder,
+			EntityID:   order.ID,
+			Before:     map[string]interface{}{"return_labels": before},
+			After:      map[string]interface{}{"return_labels": len(labels)},
+		}); err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveReturnLabels, nil)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveReturnLabels, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgReturnLabelsCreated, labels)
+}
+
+// DownloadReturnLabelsHandler renders an order's return labels as a PDF, one page per kit. The
+// unit query parameter limits it to a single kit.
+func DownloadReturnLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidOrderID, nil)
+		return
+	}
+	unit := 0
+	if value := r.URL.Query().Get("unit"); value != "" {
+		if unit, err = strconv.Atoi(value); err != nil || unit < 1 {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidReturnLabelUnit, nil)
+			return
+		}
+	}
+
+	db := config.DB.WithContext(r.Context())
+	var (
+		order    models.Order
+		customer models.Customer
+	)
+	if err := db.First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgOrderNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if err := db.Unscoped().First(&customer, order.CustomerID).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if customer.ErasedAt != nil {
+		utils.JSONResponse(w, http.StatusGone, false, utils.MsgReturnLabelsErased, nil)
+		return
+	}
+
+	query := db.Where("order_id = ?", order.ID).Order("unit")
+	if unit != 0 {
+		query = query.Where("unit = ?", unit)
+	}
+	var labels []models.ReturnLabel
+	if err := query.Find(&labels).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+	if len(labels) == 0 {
+		message := utils.MsgReturnLabelsNotFound
+		if unit != 0 {
+			message = utils.MsgReturnLabelUnitNotFound
+		}
+		utils.JSONResponse(w, http.StatusNotFound, false, message, nil)
+		return
+	}
+
+	// Render in memory so a failure never leaves a half-written response
+	var buf bytes.Buffer
+	if err := utils.WriteReturnLabelsPDF(&buf, labels, &order, &customer); err != nil {
+		if errors.Is(err, utils.ErrLabAddressMissing) {
+			utils.JSONResponse(w, http.StatusServiceUnavailable, false, utils.MsgLabAddressNotConfigured, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToRenderReturnLabels, nil)
+		return
+	}
+
+	// The labels carry the customer's address, so record who printed them
+	after := map[string]interface{}{"return_labels": len(labels)}
+	if unit != 0 {
+		after["unit"] = unit
+	}
+	if err := recordAudit(db, r, utils.AuditEntry{
+		Action:     utils.AuditReturnLabelDownload,
+		EntityType: utils.AuditEntityOrder,
+		EntityID:   order.ID,
+		After:      after,
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToRenderReturnLabels, nil)
+		return
+	}
+
+	fileName := fmt.Sprintf("return_labels_order_%d.pdf", order.ID)
+	if unit != 0 {
+		fileName = fmt.Sprintf("return_label_order_%d_kit_%d.pdf", order.ID, unit)
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, &buf)
+}
@@ -0,0 +1,417 @@
+// controllers/role_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{1,19}$`)
+
+// RoleRequest is the payload for creating a role.
+type RoleRequest struct {
+	Name              string   `json:"name" form:"name"`
+	Permissions       []string `json:"permissions" form:"permissions"`
+	TwoFactorRequired bool     `json:"two_factor_required" form:"two_factor_required"`
+}
+
+// UpdateRoleRequest is the payload for renaming, enabling or disabling a role.
+type UpdateRoleRequest struct {
+	Name   *string `json:"name" form:"name"`
+	Status *bool   `json:"status" form:"status"`
+}
+
+// RolePermissionsRequest replaces the full permission set of a role.
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" form:"permissions"`
+}
+
+// RoleDetails represents a role with its permission keys in API responses.
+type RoleDetails struct {
+	ID                uint     `json:"id"`
+	Name              string   `json:"name"`
+	Status            bool     `json:"status"`
+	IsSystem          bool     `json:"is_system"`
+	TwoFactorRequired bool     `json:"two_factor_required"`
+	UserCount         int64    `json:"user_count"`
+	Permissions       []string `json:"permissions"`
+}
+
+// GetPermissionsHandler lists every permission that can be assigned to a role.
+func GetPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	var permissions []models.Permission
+	if err := config.DB.WithContext(r.Context()).Order("key").Find(&permissions).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgPermissionsFetchedSuccessfully, permissions)
+}
+
+// GetRolesHandler lists roles with their permissions and number of users.
+func GetRolesHandler(w http.ResponseWriter, r *http.Request) {
+	var roles []models.Role
+	if err := config.DB.WithContext(r.Context()).Where("is_deleted = ?", false).Order("id").Find(&roles).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	records := make([]RoleDetails, 0, len(roles))
+	for _, role := range roles {
+		details, err := buildRoleDetails(config.DB.WithContext(r.Context()), role)
+		if err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+			return
+		}
+		records = append(records, details)
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgRolesFetchedSuccessfully, records)
+}
+
+// CreateRoleHandler creates a custom role with an initial permission set.
+func CreateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req RoleRequest
+	allowedFields := []string{"name", "permissions", "two_factor_required"}
+	if err := utils.ParseRequestBody(r, &req, allowedFields); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	req.Name = strings.TrimSpace(strings.ToLower(req.Name))
+	if !roleNamePattern.MatchString(req.Name) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidRoleName, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	// 1. Role names must be unique, including soft-deleted roles
+	var count int64
+	if err := tx.Unscoped().Model(&models.Role{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if count > 0 {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgRoleNameInUse, nil)
+		return
+	}
+
+	// 2. Resolve the requested permission keys
+	permissions, err := findPermissionsByKey(tx, req.Permissions)
+	if err != nil {
+		respondPermissionLookupError(w, err)
+		return
+	}
+
+	// 3. Create the role with its permissions
+	role := models.Role{
+		Name:              req.Name,
+		Status:            true,
+		TwoFactorRequired: req.TwoFactorRequired,
+		Permissions:       permissions,
+	}
+	if err := tx.Create(&role).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCreateRoleRecord, nil)
+		return
+	}
+
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditRoleCreate,
+		EntityType: utils.AuditEntityRole,
+		EntityID:   role.ID,
+		After:      roleAuditState(&role, permissionKeys(permissions)),
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCreateRoleRecord, nil)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCreateRoleRecord, nil)
+		return
+	}
+	utils.InvalidatePermissionCache()
+
+	details, _ := buildRoleDetails(config.DB.WithContext(r.Context()), role)
+	utils.JSONResponse(w, http.StatusCreated, true, utils.MsgRoleCreatedSuccessfully, details)
+}
+
+// UpdateRoleHandler renames a custom role or enables/disables a role.
+func UpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateRoleRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"name", "status"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	if req.Name == nil && req.Status == nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidRequest, nil)
+		return
+	}
+
+	role, err := getExistingRole(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgRoleNotFound, nil)
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(strings.ToLower(*req.Name))
+		if name != role.Name {
+			if role.IsSystem {
+				utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgSystemRoleProtected, nil)
+				return
+			}
+			if !roleNamePattern.MatchString(name) {
+				utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidRoleName, nil)
+				return
+			}
+			var count int64
+			if err := config.DB.WithContext(r.Context()).Unscoped().Model(&models.Role{}).Where("name = ? AND id <> ?", name, role.ID).Count(&count).Error; err != nil {
+				utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+				return
+			}
+			if count > 0 {
+				utils.JSONResponse(w, http.StatusConflict, false, utils.MsgRoleNameInUse, nil)
+				return
+			}
+			updates["name"] = name
+		}
+	}
+	if req.Status != nil && *req.Status != role.Status {
+		if role.Name == utils.RoleSuperAdmin {
+			utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgSystemRoleProtected, nil)
+			return
+		}
+		updates["status"] = *req.Status
+	}
+
+	if len(updates) > 0 {
+		before := roleAuditState(role, nil)
+		err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(role).Updates(updates).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, r, utils.AuditEntry{
+				Action:     utils.AuditRoleUpdate,
+				EntityType: utils.AuditEntityRole,
+				EntityID:   role.ID,
+				Before:     before,
+				After:      updates,
+			})
+		})
+		if err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+	}
+
+	details, err := buildRoleDetails(config.DB.WithContext(r.Context()), *role)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgRoleUpdatedSuccessfully, details)
+}
+
+// SetRolePermissionsHandler replaces the permission set of a role.
+func SetRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	var req RolePermissionsRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"permissions"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	role, err := getExistingRole(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgRoleNotFound, nil)
+		return
+	}
+
+	// The super-admin role always holds every permission
+	if role.Name == utils.RoleSuperAdmin {
+		utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgSystemRoleProtected, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	permissions, err := findPermissionsByKey(tx, req.Permissions)
+	if err != nil {
+		respondPermissionLookupError(w, err)
+		return
+	}
+
+	var previous []models.Permission
+	if err := tx.Model(role).Association("Permissions").Find(&previous); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	if err := tx.Model(role).Association("Permissions").Replace(permissions); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditRolePermissions,
+		EntityType: utils.AuditEntityRole,
+		EntityID:   role.ID,
+		Before:     map[string]interface{}{"permissions": permissionKeys(previous)},
+		After:      map[string]interface{}{"permissions": permissionKeys(permissions)},
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	utils.InvalidatePermissionCache()
+
+	details, err := buildRoleDetails(config.DB.WithContext(r.Context()), *role)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgRolePermissionsUpdatedSuccessfully, details)
+}
+
+// DeleteRoleHandler deletes a custom role that no user is assigned to.
+func DeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	role, err := getExistingRole(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgRoleNotFound, nil)
+		return
+	}
+
+	if role.IsSystem {
+		utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgSystemRoleProtected, nil)
+		return
+	}
+
+	// Users reference roles with a cascading foreign key, so a role in use (even by a
+	// deleted user) must never be removed
+	var userCount int64
+	if err := config.DB.WithContext(r.Context()).Unscoped().Model(&models.User{}).Where("role_id = ?", role.ID).Count(&userCount).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if userCount > 0 {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgRoleInUse, nil)
+		return
+	}
+
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		var previous []models.Permission
+		if err := tx.Model(role).Association("Permissions").Find(&previous); err != nil {
+			return err
+		}
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(role).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, r, utils.AuditEntry{
+			Action:     utils.AuditRoleDelete,
+			EntityType: utils.AuditEntityRole,
+			EntityID:   role.ID,
+			Before:     roleAuditState(role, permissionKeys(previous)),
+		})
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	utils.InvalidatePermissionCache()
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgRoleDeletedSuccessfully, nil)
+}
+
+// getExistingRole loads a role that has not been deleted.
+func getExistingRole(roleID string) (*models.Role, error) {
+	var role models.Role
+	err := config.DB.Where("id = ? AND is_deleted = ?", roleID, false).First(&role).Error
+	return &role, err
+}
+
+// buildRoleDetails collects the permission keys and user count of a role.
+func buildRoleDetails(db *gorm.DB, role models.Role) (RoleDetails, error) {
+	var userCount int64
+	if err := db.Model(&models.User{}).Where("role_id = ? AND is_deleted = ?", role.ID, false).Count(&userCount).Error; err != nil {
+		return RoleDetails{}, err
+	}
+
+	keys, err := utils.RolePermissionKeys(db, role)
+	if err != nil {
+		return RoleDetails{}, err
+	}
+
+	return RoleDetails{
+		ID:                role.ID,
+		Name:              role.Name,
+		Status:            role.Status,
+		IsSystem:          role.IsSystem,
+		TwoFactorRequired: role.TwoFactorRequired,
+		UserCount:         userCount,
+		Permissions:       keys,
+	}, nil
+}
+
+// errUnknownPermission is returned when a request names a permission key that does not exist.
+var errUnknownPermission = errors.New(utils.MsgUnknownPermission)
+
+// findPermissionsByKey loads the permissions for the given keys, rejecting unknown keys.
+func findPermissionsByKey(db *gorm.DB, keys []string) ([]models.Permission, error) {
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key != "" && !utils.StringInSlice(key, unique) {
+			unique = append(unique, key)
+		}
+	}
+
+	permissions := []models.Permission{}
+	if len(unique) == 0 {
+		return permissions, nil
+	}
+	if err := db.Where("key IN ?", unique).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	if len(permissions) != len(unique) {
+		return nil, errUnknownPermission
+	}
+	return permissions, nil
+}
+
+// permissionKeys returns the sorted keys of the permissions.
+func permissionKeys(permissions []models.Permission) []string {
+	keys := make([]string, len(permissions))
+	for i, permission := range permissions {
+		keys[i] = permission.Key
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func respondPermissionLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnknownPermission) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+}
@@ -0,0 +1,180 @@
+// controllers/session_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenRequest represents the payload for exchanging a refresh token.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required" form:"refresh_token"`
+}
+
+// SessionProfile represents a signed-in device in API responses.
+type SessionProfile struct {
+	ID         uint      `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	Current    bool      `json:"current"`
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access and refresh token pair.
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+
+	// Define allowed fields for this request
+	allowedFields := []string{"refresh_token"}
+
+	// Use the common request parser for both JSON and form data, and validate allowed fields
+	if err := utils.ParseRequestBody(r, &req, allowedFields); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+	if req.RefreshToken == "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingRefreshToken, nil)
+		return
+	}
+
+	// Rotate the refresh token; a reused token revokes the whole session
+	tokens, err := utils.RotateRefreshToken(config.DB.WithContext(r.Context()), req.RefreshToken, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrRefreshTokenReused):
+			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgRefreshTokenReused, nil)
+		case errors.Is(err, utils.ErrInvalidRefreshToken):
+			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidRefreshToken, nil)
+		default:
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		}
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgTokenRefreshedSuccessfully, newLoginResponse(tokens))
+}
+
+// GetUserSessionsHandler lists the active sessions of the logged-in user.
+func GetUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the user and session from the context (set by AuthMiddleware)
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+	current, _ := middlewares.GetSessionFromContext(r.Context())
+
+	var sessions []models.Session
+	if err := config.DB.WithContext(r.Context()).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now().UTC()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	profiles := make([]SessionProfile, 0, len(sessions))
+	for _, session := range sessions {
+		profiles = append(profiles, SessionProfile{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			CreatedAt:  session.CreatedAt,
+			Current:    current != nil && current.ID == session.ID,
+		})
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgSessionsFetchedSuccessfully, profiles)
+}
+
+// RevokeUserSessionHandler signs one of the logged-in user's devices out.
+func RevokeUserSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+
+	vars := mux.Vars(r)
+	sessionID, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidSessionID, nil)
+		return
+	}
+
+	// Only the owner's own, still active sessions can be revoked here
+	var session models.Session
+	if err := config.DB.WithContext(r.Context()).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, user.ID).
+		First(&session).Error; err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgSessionNotFound, nil)
+		return
+	}
+
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := utils.RevokeSession(tx, &session, utils.SessionRevokedByUser); err != nil {
+			return err
+		}
+		return utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditAccountSessionRevoke,
+			EntityType: utils.AuditEntitySession,
+			EntityID:   session.ID,
+		})
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgSessionRevokedSuccessfully, nil)
+}
+
+// RevokeOtherSessionsHandler signs the logged-in user out of every device except the current one.
+func RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+	current, ok := middlewares.GetSessionFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgSessionRevokedOrExpired, nil)
+		return
+	}
+
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := utils.RevokeUserSessions(tx, user.ID, current.ID, utils.SessionRevokedByUser); err != nil {
+			return err
+		}
+		return utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditAccountSessionRevoke,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   user.ID,
+		})
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgOtherSessionsRevokedSuccessfully, nil)
+}
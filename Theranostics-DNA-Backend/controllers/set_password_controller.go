@@ -0,0 +1,114 @@
+// controllers/set_password_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/emails"
+	"theransticslabs/m/utils"
+)
+
+// SetPasswordRequest redeems a reset or invite token with the password the user has chosen.
+type SetPasswordRequest struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+// SetPasswordHandler completes a password reset or staff invite. The token is single-use,
+// and every existing session is signed out once the new password is saved.
+func SetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetPasswordRequest
+
+	// Define allowed fields for this request
+	allowedFields := []string{"token", "password"}
+
+	// Use the common request parser for both JSON and form data, and validate allowed fields
+	if err := utils.ParseRequestBody(r, &req, allowedFields); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	req.Password = strings.TrimSpace(req.Password)
+	if req.Token == "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingPasswordToken, nil)
+		return
+	}
+	if !utils.IsValidPassword(req.Password) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgPasswordValidation, nil)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToHashNewPassword, nil)
+		return
+	}
+
+	// Start a transaction
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	// 1. Redeem the token
+	record, err := utils.ConsumePasswordToken(tx, req.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidPasswordToken) {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPasswordToken, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	user := record.User
+
+	// 2. Save the new password and sign out every device
+	if err := tx.Model(&user).Update("hash_password", hashedPassword).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToUpdateNewPassword, nil)
+		return
+	}
+	if err := utils.RevokeUserSessions(tx, user.ID, 0, utils.SessionRevokedPasswordReset); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if err := utils.RecordAudit(tx, r, &user, utils.AuditEntry{
+		Action:     utils.AuditAccountPasswordSet,
+		EntityType: utils.AuditEntityUser,
+		EntityID:   user.ID,
+		After:      map[string]interface{}{"purpose": record.Purpose},
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// 3. Proving control of the mailbox lifts any login lockout
+	if err := utils.ResetThrottle(tx, utils.LoginAccountPolicy, user.Email); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// 4. Confirm the change for password resets; a finished invite needs no follow-up email
+	if record.Purpose == utils.PasswordTokenPurposeReset {
+		email, err := emails.Render(emails.TemplatePasswordUpdated, user.Language, emails.PasswordUpdatedData{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+		})
+		if err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+		if err := utils.QueueEmail(tx, []string{user.Email}, email.Subject, email.HTML); err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgPasswordSetSuccessfully, nil)
+}
@@ -0,0 +1,286 @@
+// controllers/shipment_controller.go
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"theransticslabs/m/carriers"
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
+	"theransticslabs/m/shipments"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const trackingNumberMaxLength = 100
+
+// CreateShipmentRequest starts a shipment for an order. Status defaults to pending; a kit handed
+// to the carrier straight away can be created in_transit with its tracking number.
+type CreateShipmentRequest struct {
+	Direction      string `json:"direction"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	Status         string `json:"status"`
+}
+
+// UpdateShipmentRequest records tracking for a shipment. Status only moves forward.
+type UpdateShipmentRequest struct {
+	Carrier        *string `json:"carrier"`
+	TrackingNumber *string `json:"tracking_number"`
+	Status         *string `json:"status"`
+}
+
+// ShipmentResponse is a shipment with a link to the carrier's tracking page.
+type ShipmentResponse struct {
+	models.Shipment
+	TrackingURL string `json:"tracking_url,omitempty"`
+}
+
+// CarrierResponse describes a carrier shipments can be sent with.
+type CarrierResponse struct {
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Trackable bool   `json:"trackable"` // Whether its status is polled automatically
+}
+
+func newShipmentResponse(shipment *models.Shipment) ShipmentResponse {
+	return ShipmentResponse{Shipment: *shipment, TrackingURL: shipments.TrackingURL(shipment)}
+}
+
+// GetCarriersHandler lists the carriers shipments can be sent with.
+func GetCarriersHandler(w http.ResponseWriter, r *http.Request) {
+	var response []CarrierResponse
+	for _, code := range carriers.Codes() {
+		carrier, _ := carriers.Get(code)
+		_, trackable := carrier.(carriers.Tracker)
+		response = append(response, CarrierResponse{Code: code, Name: carrier.Name(), Trackable: trackable})
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgCarriersFetched, response)
+}
+
+// GetOrderShipmentsHandler lists an order's shipments, oldest first.
+func GetOrderShipmentsHandler(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidOrderID, nil)
+		return
+	}
+
+	db := config.DB.WithContext(r.Context())
+	var order models.Order
+	if err := db.Select("id").First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgOrderNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	var records []models.Shipment
+	if err := db.Where("order_id = ?", order.ID).Order("id").Find(&records).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	response := make([]ShipmentResponse, len(records))
+	for i := range records {
+		response[i] = newShipmentResponse(&records[i])
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgShipmentsFetched, response)
+}
+
+// CreateShipmentHandler creates a shipment for a paid order.
+func CreateShipmentHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotAuthenticated, nil)
+		return
+	}
+
+	orderID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidOrderID, nil)
+		return
+	}
+
+	var req CreateShipmentRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"direction", "carrier", "tracking_number", "status"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	req.Direction = strings.TrimSpace(req.Direction)
+	req.Carrier = strings.ToLower(strings.TrimSpace(req.Carrier))
+	req.TrackingNumber = strings.TrimSpace(req.TrackingNumber)
+	if req.Status == "" {
+		req.Status = shipments.StatusPending
+	}
+	if !shipments.IsDirection(req.Direction) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidShipmentDirection, nil)
+		return
+	}
+	shipment := models.Shipment{
+		OrderID:        uint(orderID),
+		Direction:      req.Direction,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Status:         shipments.StatusPending,
+		CreatedBy:      user.ID,
+	}
+	if message, ok := validateShipment(&shipment, req.Status); !ok {
+		utils.JSONResponse(w, http.StatusBadRequest, false, message, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	// Lock the order so a concurrent cancellation can't slip in between the check and the shipment
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgOrderNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if order.PaymentStatus != utils.PaymentStatusCompleted || order.OrderStatus == utils.OrderStatusCancelled {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgOrderNotShippable, nil)
+		return
+	}
+
+	if err := tx.Create(&shipment).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+		return
+	}
+	if req.Status != shipments.StatusPending {
+		if err := shipments.Update(tx, &shipment, req.Status, time.Now()); err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+			return
+		}
+	}
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditShipmentCreate,
+		EntityType: utils.AuditEntityShipment,
+		EntityID:   shipment.ID,
+		After:      shipments.AuditState(&shipment),
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusCreated, true, utils.MsgShipmentCreated, newShipmentResponse(&shipment))
+}
+
+// UpdateShipmentHandler records a shipment's carrier, tracking number or progress. Marking an
+// outbound kit in transit emails the customer its tracking details.
+func UpdateShipmentHandler(w http.ResponseWriter, r *http.Request) {
+	shipmentID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidShipmentID, nil)
+		return
+	}
+
+	var req UpdateShipmentRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"carrier", "tracking_number", "status"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	if req.Carrier == nil && req.TrackingNumber == nil && req.Status == nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgNoShipmentChanges, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	// Lock the row so the tracker can't apply a carrier update half-way through
+	var shipment models.Shipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shipment, shipmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgShipmentNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if shipment.Status == shipments.StatusDelivered {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgShipmentAlreadyDelivered, nil)
+		return
+	}
+	before := shipments.AuditState(&shipment)
+
+	if req.Carrier != nil {
+		shipment.Carrier = strings.ToLower(strings.TrimSpace(*req.Carrier))
+	}
+	if req.TrackingNumber != nil {
+		shipment.TrackingNumber = strings.TrimSpace(*req.TrackingNumber)
+	}
+	status := shipment.Status
+	if req.Status != nil {
+		status = *req.Status
+	}
+	if message, ok := validateShipment(&shipment, status); !ok {
+		utils.JSONResponse(w, http.StatusBadRequest, false, message, nil)
+		return
+	}
+
+	if err := shipments.Update(tx, &shipment, status, time.Now()); err != nil {
+		if errors.Is(err, shipments.ErrStatusBackwards) {
+			utils.JSONResponse(w, http.StatusConflict, false, utils.MsgShipmentStatusBackwards, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+		return
+	}
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditShipmentUpdate,
+		EntityType: utils.AuditEntityShipment,
+		EntityID:   shipment.ID,
+		Before:     before,
+		After:      shipments.AuditState(&shipment),
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveShipment, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgShipmentUpdated, newShipmentResponse(&shipment))
+}
+
+// validateShipment checks the shipment's carrier and tracking number, and that it can move to
+// status. It returns the message to respond with when it can't.
+func validateShipment(shipment *models.Shipment, status string) (string, bool) {
+	if _, ok := carriers.Get(shipment.Carrier); !ok {
+		return fmt.Sprintf(utils.MsgUnknownCarrier, strings.Join(carriers.Codes(), ", ")), false
+	}
+	if len([]rune(shipment.TrackingNumber)) > trackingNumberMaxLength {
+		return utils.MsgInvalidTrackingNumber, false
+	}
+	if !shipments.IsStatus(status) {
+		return utils.MsgInvalidShipmentStatus, false
+	}
+	// The customer's tracking email needs a number to quote
+	if shipment.Direction == shipments.DirectionOutbound && status != shipments.StatusPending && shipment.TrackingNumber == "" {
+		return utils.MsgTrackingNumberRequired, false
+	}
+	return "", true
+}
@@ -0,0 +1,404 @@
+// controllers/two_factor_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// TwoFactorChallengeResponse is returned from the password step when the user has 2FA enabled.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"` // Challenge lifetime in seconds
+}
+
+// TwoFactorLoginRequest completes a login with either a TOTP code or a recovery code.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required" form:"challenge_token"`
+	Code           string `json:"code" form:"code"`
+	RecoveryCode   string `json:"recovery_code" form:"recovery_code"`
+}
+
+// TwoFactorCodeRequest carries a TOTP code for enrolment and management actions.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required" form:"code"`
+}
+
+// TwoFactorSetupResponse carries the secret for a pending enrolment.
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to render as a QR code
+}
+
+// RecoveryCodesResponse returns freshly issued recovery codes. They are only ever shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// UpdateRoleTwoFactorRequest toggles 2FA enforcement for a role.
+type UpdateRoleTwoFactorRequest struct {
+	Required bool `json:"required" form:"required"`
+}
+
+// TwoFactorLoginHandler completes the second step of a 2FA login and starts a session.
+func TwoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+
+	// Define allowed fields for this request
+	allowedFields := []string{"challenge_token", "code", "recovery_code"}
+
+	if err := utils.ParseRequestBody(r, &req, allowedFields); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	req.ChallengeToken = strings.TrimSpace(req.ChallengeToken)
+	req.Code = strings.TrimSpace(req.Code)
+	req.RecoveryCode = strings.TrimSpace(req.RecoveryCode)
+	if req.ChallengeToken == "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingTwoFactorChallenge, nil)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingTwoFactorCode, nil)
+		return
+	}
+
+	// 1. Validate the challenge issued by the password step
+	userID, claims, err := utils.ValidateTwoFactorChallenge(req.ChallengeToken)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidTwoFactorChallenge, nil)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.WithContext(r.Context()).Preload("Role").First(&user, userID).Error; err != nil {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidTwoFactorChallenge, nil)
+		return
+	}
+	if !user.ActiveStatus || user.IsDeleted {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserInactive, nil)
+		return
+	}
+	if !user.TwoFactorEnabled {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidTwoFactorChallenge, nil)
+		return
+	}
+
+	// 2. Verify the TOTP or recovery code; failures count towards the login lockout
+	if !checkLoginThrottle(w, r, user.Email) {
+		return
+	}
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		return utils.VerifyTwoFactorCode(tx, user.ID, req.Code, req.RecoveryCode)
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidTwoFactorCode) {
+			recordLoginFailure(r, user.Email, &user.ID)
+			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidTwoFactorCode, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	if err := utils.ResetThrottle(config.DB.WithContext(r.Context()), utils.LoginAccountPolicy, user.Email); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// 3. Start a new session for this device and issue its token pair
+	tokens, err := utils.CreateSession(config.DB.WithContext(r.Context()), user, r, claims.DeviceName)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgTokenCreationFailed, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgLoginSuccess, newLoginResponse(tokens))
+}
+
+// SetupTwoFactorHandler generates a new pending TOTP secret for the logged-in user.
+func SetupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgTwoFactorAlreadyEnabled, nil)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	// Store the secret encrypted; it only takes effect once a code has been confirmed
+	if err := config.DB.WithContext(r.Context()).Model(&models.User{ID: user.ID}).
+		Select("two_factor_secret", "two_factor_last_step").
+		Updates(models.User{TwoFactorSecret: secret}).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgTwoFactorSetupStarted, TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+// EnableTwoFactorHandler confirms the pending secret with a code and issues recovery codes.
+func EnableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+
+	req, ok := parseTwoFactorCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgTwoFactorAlreadyEnabled, nil)
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgTwoFactorSetupNotStarted, nil)
+		return
+	}
+
+	var codes []string
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := utils.VerifyTwoFactorCode(tx, user.ID, req.Code, ""); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_enabled":      true,
+			"two_factor_confirmed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditAccountTwoFactorOn,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   user.ID,
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = utils.ReplaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		respondTwoFactorError(w, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgTwoFactorEnabledSuccessfully, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodesHandler replaces the logged-in user's recovery codes.
+func RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+
+	req, ok := parseTwoFactorCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgTwoFactorNotEnabled, nil)
+		return
+	}
+
+	var codes []string
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := utils.VerifyTwoFactorCode(tx, user.ID, req.Code, ""); err != nil {
+			return err
+		}
+		if err := utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditAccountRecoveryCodes,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   user.ID,
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = utils.ReplaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		respondTwoFactorError(w, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgRecoveryCodesRegenerated, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactorHandler turns 2FA off for the logged-in user, unless their role requires it.
+func DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotFound, nil)
+		return
+	}
+
+	req, ok := parseTwoFactorCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgTwoFactorNotEnabled, nil)
+		return
+	}
+	if user.Role.TwoFactorRequired {
+		utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgTwoFactorRequiredByRole, nil)
+		return
+	}
+
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := utils.VerifyTwoFactorCode(tx, user.ID, req.Code, ""); err != nil {
+			return err
+		}
+		if err := utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditAccountTwoFactorOff,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   user.ID,
+		}); err != nil {
+			return err
+		}
+		return utils.ResetTwoFactor(tx, user.ID)
+	})
+	if err != nil {
+		respondTwoFactorError(w, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgTwoFactorDisabledSuccessfully, nil)
+}
+
+// ResetUserTwoFactorHandler lets staff managers clear a user's 2FA, e.g. after a lost device.
+// The user is signed out everywhere and must enrol again if their role requires it.
+func ResetUserTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	actor, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotAuthenticated, nil)
+		return
+	}
+
+	existingUser, err := getExistingUser(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgUserDoesNotExist, nil)
+		return
+	}
+
+	if !canManageUser(w, actor, existingUser) {
+		return
+	}
+
+	err = config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := utils.ResetTwoFactor(tx, existingUser.ID); err != nil {
+			return err
+		}
+		if err := utils.RecordAudit(tx, r, actor, utils.AuditEntry{
+			Action:     utils.AuditStaffTwoFactorReset,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   existingUser.ID,
+		}); err != nil {
+			return err
+		}
+		return utils.RevokeUserSessions(tx, existingUser.ID, 0, utils.SessionRevokedTwoFactor)
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgTwoFactorResetSuccessfully, nil)
+}
+
+// UpdateRoleTwoFactorHandler lets a super-admin require 2FA for every user with a role.
+func UpdateRoleTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateRoleTwoFactorRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"required"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.WithContext(r.Context()).Where("id = ? AND is_deleted = ?", mux.Vars(r)["id"], false).First(&role).Error; err != nil {
+		utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgRoleNotFound, nil)
+		return
+	}
+
+	before := roleAuditState(&role, nil)
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("two_factor_required", req.Required).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, r, utils.AuditEntry{
+			Action:     utils.AuditRoleUpdate,
+			EntityType: utils.AuditEntityRole,
+			EntityID:   role.ID,
+			Before:     before,
+			After:      roleAuditState(&role, nil),
+		})
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgRoleTwoFactorUpdatedSuccessfully, role)
+}
+
+// parseTwoFactorCodeRequest parses and validates a request carrying a single TOTP code.
+func parseTwoFactorCodeRequest(w http.ResponseWriter, r *http.Request) (*TwoFactorCodeRequest, bool) {
+	var req TwoFactorCodeRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"code"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return nil, false
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgMissingTwoFactorCode, nil)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// respondTwoFactorError maps code verification failures to a client error and anything else to a server error.
+func respondTwoFactorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, utils.ErrInvalidTwoFactorCode) {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidTwoFactorCode, nil)
+		return
+	}
+	utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+}
@@ -0,0 +1,479 @@
+// controllers/webhook_controller.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/middlewares"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookSecretMinLength = 16
+	webhookSecretMaxLength = 255
+	webhookDescMaxLength   = 255
+)
+
+// CreateWebhookRequest subscribes a partner endpoint to events. When no secret is given one is
+// generated; either way it is only returned in the create response.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret"`
+	Active      *bool    `json:"active"`
+}
+
+// UpdateWebhookRequest changes the given fields of a subscription. A new secret takes effect for
+// every delivery sent from then on, retries included.
+type UpdateWebhookRequest struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	Secret      *string   `json:"secret"`
+	Active      *bool     `json:"active"`
+}
+
+// WebhookResponse is a subscription as returned by the API. Secret is only set on creation.
+type WebhookResponse struct {
+	ID          uint      `json:"id"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveriesResponse represents the paginated delivery log of a subscription.
+type WebhookDeliveriesResponse struct {
+	Page         int                      `json:"page"`
+	PerPage      int                      `json:"per_page"`
+	TotalRecords int64                    `json:"total_records"`
+	TotalPages   int                      `json:"total_pages"`
+	Records      []models.WebhookDelivery `json:"records"`
+}
+
+func newWebhookResponse(subscription *models.WebhookSubscription) WebhookResponse {
+	return WebhookResponse{
+		ID:          subscription.ID,
+		Description: subscription.Description,
+		URL:         subscription.URL,
+		Events:      utils.SplitWebhookEvents(subscription.Events),
+		Active:      subscription.Active,
+		CreatedBy:   subscription.CreatedBy,
+		CreatedAt:   subscription.CreatedAt,
+		UpdatedAt:   subscription.UpdatedAt,
+	}
+}
+
+// GetWebhooksHandler lists the webhook subscriptions.
+func GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	var subscriptions []models.WebhookSubscription
+	if err := config.DB.WithContext(r.Context()).Where("is_deleted = ?", false).Order("id").Find(&subscriptions).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	response := make([]WebhookResponse, len(subscriptions))
+	for i := range subscriptions {
+		response[i] = newWebhookResponse(&subscriptions[i])
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgWebhooksFetched, response)
+}
+
+// CreateWebhookHandler subscribes an endpoint to events.
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserNotAuthenticated, nil)
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"url", "description", "events", "secret", "active"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	req.Description = strings.TrimSpace(req.Description)
+	if !validWebhookURL(r.Context(), req.URL) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookURL, nil)
+		return
+	}
+	if len(req.Description) > webhookDescMaxLength {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookDesc, nil)
+		return
+	}
+	events, ok := normalizeWebhookEvents(req.Events)
+	if !ok {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookEvents, nil)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		token, err := utils.GenerateRandomToken(24)
+		if err != nil {
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+		secret = "whsec_" + token
+	} else if !validWebhookSecret(secret) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookSecret, nil)
+		return
+	}
+
+	subscription := models.WebhookSubscription{
+		Description: req.Description,
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      events,
+		Active:      req.Active == nil || *req.Active,
+		CreatedBy:   user.ID,
+	}
+
+	err := config.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		// Create would skip a false Active in favour of the column default
+		if err := tx.Select("*").Omit("ID").Create(&subscription).Error; err != nil {
+			return err
+		}
+		return utils.RecordAudit(tx, r, user, utils.AuditEntry{
+			Action:     utils.AuditWebhookCreate,
+			EntityType: utils.AuditEntityWebhook,
+			EntityID:   subscription.ID,
+			After:      webhookAuditState(&subscription),
+		})
+	})
+	if err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+
+	response := newWebhookResponse(&subscription)
+	response.Secret = secret
+	utils.JSONResponse(w, http.StatusCreated, true, utils.MsgWebhookCreated, response)
+}
+
+// UpdateWebhookHandler changes a subscription's URL, description, events, secret or status.
+func UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateWebhookRequest
+	if err := utils.ParseRequestBody(r, &req, []string{"url", "description", "events", "secret", "active"}); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	if req.URL == nil && req.Description == nil && req.Events == nil && req.Secret == nil && req.Active == nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgNoWebhookChanges, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	subscription, ok := getWebhookSubscription(w, tx, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	before := webhookAuditState(subscription)
+
+	if req.URL != nil {
+		subscription.URL = strings.TrimSpace(*req.URL)
+		if !validWebhookURL(r.Context(), subscription.URL) {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookURL, nil)
+			return
+		}
+	}
+	if req.Description != nil {
+		subscription.Description = strings.TrimSpace(*req.Description)
+		if len(subscription.Description) > webhookDescMaxLength {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookDesc, nil)
+			return
+		}
+	}
+	if req.Events != nil {
+		events, ok := normalizeWebhookEvents(*req.Events)
+		if !ok {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookEvents, nil)
+			return
+		}
+		subscription.Events = events
+	}
+	if req.Secret != nil {
+		if !validWebhookSecret(*req.Secret) {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookSecret, nil)
+			return
+		}
+		subscription.Secret = *req.Secret
+	}
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+
+	if err := tx.Model(subscription).Select("URL", "Description", "Events", "Secret", "Active", "UpdatedAt").Updates(subscription).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditWebhookUpdate,
+		EntityType: utils.AuditEntityWebhook,
+		EntityID:   subscription.ID,
+		Before:     before,
+		After:      webhookAuditState(subscription),
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgWebhookUpdated, newWebhookResponse(subscription))
+}
+
+// DeleteWebhookHandler removes a subscription. Its delivery log is kept, and deliveries still
+// pending are dead-lettered by the worker instead of being sent.
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	subscription, ok := getWebhookSubscription(w, tx, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	before := webhookAuditState(subscription)
+
+	subscription.Active = false
+	subscription.IsDeleted = true
+	if err := tx.Model(subscription).Select("Active", "IsDeleted", "UpdatedAt").Updates(subscription).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditWebhookDelete,
+		EntityType: utils.AuditEntityWebhook,
+		EntityID:   subscription.ID,
+		Before:     before,
+		After:      webhookAuditState(subscription),
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToSaveWebhook, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgWebhookDeleted, nil)
+}
+
+// GetWebhookDeliveriesHandler lists a subscription's deliveries, newest first, optionally
+// filtered by status.
+func GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !utils.AllowFields(query, []string{"page", "per_page", "status"}) {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidQueryParameters, nil)
+		return
+	}
+
+	subscription, ok := getWebhookSubscription(w, config.DB.WithContext(r.Context()), mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	// Default and validation for 'page'
+	page := 1
+	if val := query.Get("page"); val != "" {
+		if p, err := strconv.Atoi(val); err == nil && p > 0 {
+			page = p
+		} else {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPageParameter, nil)
+			return
+		}
+	}
+
+	// Default and validation for 'per_page'
+	perPage := 25
+	if val := query.Get("per_page"); val != "" {
+		if pp, err := strconv.Atoi(val); err == nil && pp > 0 {
+			perPage = pp
+		} else {
+			utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidPerPageParameter, nil)
+			return
+		}
+	}
+
+	db := config.DB.WithContext(r.Context()).Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscription.ID)
+	switch status := query.Get("status"); status {
+	case "":
+	case utils.OutboxStatusPending, utils.OutboxStatusSent, utils.OutboxStatusDead:
+		db = db.Where("status = ?", status)
+	default:
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidDeliveryStatus, nil)
+		return
+	}
+
+	var totalRecords int64
+	if err := db.Count(&totalRecords).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToCountRecords, nil)
+		return
+	}
+
+	records := []models.WebhookDelivery{}
+	if err := db.Omit("payload").Order("id desc").Limit(perPage).Offset((page - 1) * perPage).Find(&records).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToFetchRecords, nil)
+		return
+	}
+
+	response := WebhookDeliveriesResponse{
+		Page:         page,
+		PerPage:      perPage,
+		TotalRecords: totalRecords,
+		TotalPages:   int((totalRecords + int64(perPage) - 1) / int64(perPage)),
+		Records:      records,
+	}
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgWebhookDeliveriesFetched, response)
+}
+
+// ReplayWebhookDeliveryHandler queues a delivery to be sent again with the same event ID and
+// payload, e.g. after a partner fixed their endpoint. The original stays in the log untouched.
+func ReplayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidDeliveryID, nil)
+		return
+	}
+
+	tx := config.DB.WithContext(r.Context()).Begin()
+	defer tx.Rollback()
+
+	var original models.WebhookDelivery
+	if err := tx.First(&original, deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgDeliveryNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+
+	var subscription models.WebhookSubscription
+	if err := tx.Where("id = ? AND is_deleted = ?", original.SubscriptionID, false).First(&subscription).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgWebhookNotFound, nil)
+			return
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return
+	}
+	if !subscription.Active {
+		utils.JSONResponse(w, http.StatusConflict, false, utils.MsgWebhookInactive, nil)
+		return
+	}
+
+	replay := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         utils.OutboxStatusPending,
+		NextAttemptAt:  time.Now(),
+		ReplayOfID:     &original.ID,
+	}
+	if err := tx.Create(&replay).Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToReplayDelivery, nil)
+		return
+	}
+
+	if err := recordAudit(tx, r, utils.AuditEntry{
+		Action:     utils.AuditWebhookReplay,
+		EntityType: utils.AuditEntityWebhook,
+		EntityID:   subscription.ID,
+		After:      map[string]interface{}{"delivery_id": replay.ID, "replay_of_id": original.ID, "event_id": original.EventID},
+	}); err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToReplayDelivery, nil)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgFailedToReplayDelivery, nil)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, true, utils.MsgDeliveryQueuedForReplay, replay)
+}
+
+// getWebhookSubscription loads the subscription with the given ID, writing the error response
+// when it can't.
+func getWebhookSubscription(w http.ResponseWriter, db *gorm.DB, id string) (*models.WebhookSubscription, bool) {
+	subscriptionID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, false, utils.MsgInvalidWebhookID, nil)
+		return nil, false
+	}
+
+	var subscription models.WebhookSubscription
+	if err := db.Where("id = ? AND is_deleted = ?", subscriptionID, false).First(&subscription).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSONResponse(w, http.StatusNotFound, false, utils.MsgWebhookNotFound, nil)
+			return nil, false
+		}
+		utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+		return nil, false
+	}
+	return &subscription, true
+}
+
+// validWebhookURL accepts absolute https URLs, and http ones outside production so local
+// receivers can be used while developing. The host must resolve to public addresses only.
+func validWebhookURL(ctx context.Context, raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || config.IsProduction()) {
+		return false
+	}
+	return utils.CheckWebhookHost(ctx, u.Hostname()) == nil
+}
+
+func validWebhookSecret(secret string) bool {
+	return len(secret) >= webhookSecretMinLength && len(secret) <= webhookSecretMaxLength
+}
+
+// normalizeWebhookEvents validates and de-duplicates event types, returning them in the order of
+// utils.WebhookEvents as stored on the subscription.
+func normalizeWebhookEvents(events []string) (string, bool) {
+	if len(events) == 0 {
+		return "", false
+	}
+	wanted := map[string]bool{}
+	for _, event := range events {
+		if !utils.IsWebhookEvent(event) {
+			return "", false
+		}
+		wanted[event] = true
+	}
+
+	var ordered []string
+	for _, event := range utils.WebhookEvents {
+		if wanted[event] {
+			ordered = append(ordered, event)
+		}
+	}
+	return strings.Join(ordered, ","), true
+}
@@ -0,0 +1,107 @@
+// create_superadmin.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+)
+
+// runCreateSuperAdmin creates a super-admin account and prints a link for them to choose a
+// password. It is how the first account of a production deployment is created.
+func runCreateSuperAdmin(args []string) int {
+	flags := flag.NewFlagSet("create-superadmin", flag.ContinueOnError)
+	email := flags.String("email", "", "email address of the new super-admin")
+	firstName := flags.String("first-name", "", "first name")
+	lastName := flags.String("last-name", "", "last name")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	*email = strings.ToLower(strings.TrimSpace(*email))
+	*firstName = strings.TrimSpace(*firstName)
+	*lastName = strings.TrimSpace(*lastName)
+	if *email == "" || *firstName == "" {
+		fmt.Fprintln(os.Stderr, utils.MsgFirstNameEmailRequired)
+		return 2
+	}
+	if !utils.IsValidEmail(*email) {
+		fmt.Fprintln(os.Stderr, utils.MsgEmailValidation)
+		return 2
+	}
+	if !utils.IsValidFirstName(*firstName) {
+		fmt.Fprintln(os.Stderr, utils.MsgInvalidFirstName)
+		return 2
+	}
+	if *lastName != "" && !utils.IsValidLastName(*lastName) {
+		fmt.Fprintln(os.Stderr, utils.MsgInvalidLastName)
+		return 2
+	}
+
+	config.InitDB()
+
+	var token string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Where("name = ?", utils.RoleSuperAdmin).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("the super-admin role does not exist; run the seed command first")
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", *email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New(utils.MsgEmailAlreadyInUse)
+		}
+
+		user := models.User{
+			FirstName:    *firstName,
+			LastName:     *lastName,
+			Email:        *email,
+			RoleID:       role.ID,
+			ActiveStatus: true,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if token, _, err = utils.IssuePasswordToken(tx, user.ID, utils.PasswordTokenPurposeInvite, nil); err != nil {
+			return err
+		}
+
+		return utils.RecordAudit(tx, nil, nil, utils.AuditEntry{
+			Action:     utils.AuditStaffCreate,
+			EntityType: utils.AuditEntityUser,
+			EntityID:   user.ID,
+			After: map[string]interface{}{
+				"first_name":    user.FirstName,
+				"last_name":     user.LastName,
+				"email":         user.Email,
+				"role_id":       user.RoleID,
+				"active_status": user.ActiveStatus,
+			},
+		})
+	})
+	if err != nil {
+		slog.Error(utils.MsgFailedToCreateUser, "error", err)
+		return 1
+	}
+
+	fmt.Printf("Super-admin created. Set the password within %s at:\n%s\n",
+		utils.PasswordTokenExpiryText(utils.PasswordTokenPurposeInvite), utils.SetPasswordURL(token))
+	return 0
+}
@@ -0,0 +1,117 @@
+// emails/data.go
+package emails
+
+// WelcomeData is rendered by TemplateWelcome, sent when a staff account is created.
+type WelcomeData struct {
+	FirstName      string
+	LastName       string
+	Email          string
+	SetPasswordURL string
+	ExpiresInHours int
+}
+
+// ResetPasswordData is rendered by TemplateResetPassword.
+type ResetPasswordData struct {
+	FirstName      string
+	LastName       string
+	ResetURL       string
+	ExpiresInHours int
+}
+
+// PasswordUpdatedData is rendered by TemplatePasswordUpdated.
+type PasswordUpdatedData struct {
+	FirstName string
+	LastName  string
+}
+
+// UserDetailsUpdatedData is rendered by TemplateUserDetailsUpdated.
+type UserDetailsUpdatedData struct {
+	FirstName string
+	LastName  string
+	Email     string
+}
+
+// UserStatusChangedData is rendered by TemplateUserStatusChanged.
+type UserStatusChangedData struct {
+	FirstName string
+	LastName  string
+	Active    bool
+}
+
+// OrderConfirmationData is rendered by TemplateOrderConfirmation, sent to the customer.
+type OrderConfirmationData struct {
+	FirstName   string
+	LastName    string
+	ProductName string
+	Quantity    int
+	TotalPrice  float64
+	InvoiceURL  string
+}
+
+// OrderNotificationData is rendered by TemplateOrderNotification, sent to the admin.
+type OrderNotificationData struct {
+	FirstName   string
+	LastName    string
+	Email       string
+	ProductName string
+	Quantity    int
+	TotalPrice  float64
+	InvoiceURL  string
+}
+
+// PaymentFailedData is rendered by TemplatePaymentFailed.
+type PaymentFailedData struct {
+	FirstName   string
+	LastName    string
+	ProductName string
+}
+
+// KitShippedData is rendered by TemplateKitShipped. TrackingURL may be empty.
+type KitShippedData struct {
+	FirstName           string
+	LastName            string
+	ProductName         string
+	Carrier             string
+	TrackingNumber      string
+	TrackingURL         string
+	ReturnLabelAttached bool // The prepaid return labels are attached as a PDF
+}
+
+// SampleReceivedData is rendered by TemplateSampleReceived.
+type SampleReceivedData struct {
+	FirstName   string
+	LastName    string
+	ProductName string
+}
+
+// samples holds the preview data of every template.
+var samples = map[string]interface{}{
+	TemplateWelcome: WelcomeData{
+		FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com",
+		SetPasswordURL: "https://example.com/set-password?token=sample", ExpiresInHours: 72,
+	},
+	TemplateResetPassword: ResetPasswordData{
+		FirstName: "Jane", LastName: "Doe",
+		ResetURL: "https://example.com/set-password?token=sample", ExpiresInHours: 1,
+	},
+	TemplatePasswordUpdated: PasswordUpdatedData{FirstName: "Jane", LastName: "Doe"},
+	TemplateUserDetailsUpdated: UserDetailsUpdatedData{
+		FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com",
+	},
+	TemplateUserStatusChanged: UserStatusChangedData{FirstName: "Jane", LastName: "Doe", Active: true},
+	TemplateOrderConfirmation: OrderConfirmationData{
+		FirstName: "John", LastName: "Smith", ProductName: "DNA Test Kit", Quantity: 2, TotalPrice: 398,
+		InvoiceURL: "https://example.com/invoices/sample.pdf",
+	},
+	TemplateOrderNotification: OrderNotificationData{
+		FirstName: "John", LastName: "Smith", Email: "john.smith@example.com", ProductName: "DNA Test Kit",
+		Quantity: 2, TotalPrice: 398, InvoiceURL: "https://example.com/invoices/sample.pdf",
+	},
+	TemplatePaymentFailed: PaymentFailedData{FirstName: "John", LastName: "Smith", ProductName: "DNA Test Kit"},
+	TemplateKitShipped: KitShippedData{
+		FirstName: "John", LastName: "Smith", ProductName: "DNA Test Kit", Carrier: "UPS",
+		TrackingNumber: "1Z999AA10123456784", TrackingURL: "https://example.com/track/1Z999AA10123456784",
+		ReturnLabelAttached: true,
+	},
+	TemplateSampleReceived: SampleReceivedData{FirstName: "John", LastName: "Smith", ProductName: "DNA Test Kit"},
+}
@@ -0,0 +1,217 @@
+// emails/emails.go
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"mime"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/mailer"
+)
+
+// Templates are HTML files under templates/<locale>/, each defining "subject", "heading" and
+// "content" blocks that are rendered inside the shared layout. Images are embedded in the email
+// rather than loaded from the API, so they show without remote content enabled.
+//
+//go:embed templates images
+var files embed.FS
+
+// DefaultLocale is used when a recipient has no language or one without translations.
+const DefaultLocale = "en"
+
+// Template names.
+const (
+	TemplateWelcome            = "welcome"
+	TemplateResetPassword      = "reset_password"
+	TemplatePasswordUpdated    = "password_updated"
+	TemplateUserDetailsUpdated = "user_details_updated"
+	TemplateUserStatusChanged  = "user_status_changed"
+	TemplateOrderConfirmation  = "order_confirmation"
+	TemplateOrderNotification  = "order_notification"
+	TemplatePaymentFailed      = "payment_failed"
+	TemplateKitShipped         = "kit_shipped"
+	TemplateSampleReceived     = "sample_received"
+)
+
+// Locales lists the languages every template is translated into, DefaultLocale first.
+var Locales = []string{DefaultLocale, "es"}
+
+// Email is a rendered email.
+type Email struct {
+	Subject string
+	HTML    string
+}
+
+// button is the data of the shared "button" block.
+type button struct {
+	URL   string
+	Label string
+}
+
+var (
+	// mailTemplates reference images by Content-ID; previewTemplates inline them as data URIs.
+	mailTemplates    map[string]map[string]*template.Template
+	previewTemplates map[string]map[string]*template.Template
+
+	matcher    language.Matcher
+	cidPattern = regexp.MustCompile(`cid:([A-Za-z0-9._-]+)`)
+)
+
+func init() {
+	tags := make([]language.Tag, len(Locales))
+	for i, locale := range Locales {
+		tags[i] = language.MustParse(locale)
+	}
+	matcher = language.NewMatcher(tags)
+
+	mailTemplates = map[string]map[string]*template.Template{}
+	previewTemplates = map[string]map[string]*template.Template{}
+	for _, locale := range Locales {
+		mailTemplates[locale] = map[string]*template.Template{}
+		previewTemplates[locale] = map[string]*template.Template{}
+		for _, name := range Names() {
+			t := template.Must(template.New("layout").Funcs(funcs(locale, mailImage)).ParseFS(files,
+				"templates/layout.html",
+				path.Join("templates", locale, "common.html"),
+				path.Join("templates", locale, name+".html"),
+			))
+			preview := template.Must(t.Clone())
+			preview.Funcs(template.FuncMap{"image": previewImage})
+			mailTemplates[locale][name] = t
+			previewTemplates[locale][name] = preview
+		}
+	}
+}
+
+// funcs returns the template functions for a locale.
+func funcs(locale string, image func(string) (template.URL, error)) template.FuncMap {
+	return template.FuncMap{
+		"locale": func() string { return locale },
+		"appURL": func() string { return config.AppConfig.AppUrl },
+		"image":  image,
+		"button": func(url, label string) button { return button{URL: url, Label: label} },
+	}
+}
+
+func mailImage(name string) (template.URL, error) {
+	if _, err := fs.Stat(files, path.Join("images", name)); err != nil {
+		return "", err
+	}
+	return template.URL("cid:" + name), nil
+}
+
+func previewImage(name string) (template.URL, error) {
+	data, err := files.ReadFile(path.Join("images", name))
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:" + mime.TypeByExtension(path.Ext(name)) + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// Names returns the names of all templates, sorted.
+func Names() []string {
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsSupportedLocale reports whether templates are translated into locale.
+func IsSupportedLocale(locale string) bool {
+	_, ok := mailTemplates[locale]
+	return ok
+}
+
+// MatchLocale picks the supported locale that best fits an Accept-Language header, falling back
+// to DefaultLocale.
+func MatchLocale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return Locales[index]
+}
+
+// Render renders the named template in the given locale, or in DefaultLocale when the locale is
+// not supported. Values in data are HTML-escaped.
+func Render(name, locale string, data interface{}) (Email, error) {
+	return render(mailTemplates, name, locale, data)
+}
+
+// RenderPreview renders the named template with its sample data, with images inlined so the
+// result can be shown in a browser.
+func RenderPreview(name, locale string) (Email, error) {
+	data, ok := samples[name]
+	if !ok {
+		return Email{}, fmt.Errorf("unknown email template %q", name)
+	}
+	return render(previewTemplates, name, locale, data)
+}
+
+func render(set map[string]map[string]*template.Template, name, locale string, data interface{}) (Email, error) {
+	templates, ok := set[locale]
+	if !ok {
+		templates = set[DefaultLocale]
+	}
+	t, ok := templates[name]
+	if !ok {
+		return Email{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := t.ExecuteTemplate(&body, "layout", data); err != nil {
+		return Email{}, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+
+	// The subject is escaped as HTML text but sent as a plain header
+	return Email{
+		Subject: strings.TrimSpace(html.UnescapeString(subject.String())),
+		HTML:    body.String(),
+	}, nil
+}
+
+// InlineImages returns the embedded images referenced by Content-ID in a rendered body, to be
+// sent as inline attachments.
+func InlineImages(body string) []mailer.Attachment {
+	var attachments []mailer.Attachment
+	seen := map[string]bool{}
+	for _, match := range cidPattern.FindAllStringSubmatch(body, -1) {
+		name := match[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		data, err := files.ReadFile(path.Join("images", name))
+		if err != nil {
+			continue
+		}
+		attachments = append(attachments, mailer.Attachment{
+			Filename:    name,
+			ContentType: mime.TypeByExtension(path.Ext(name)),
+			Data:        data,
+			Inline:      true,
+		})
+	}
+	return attachments
+}
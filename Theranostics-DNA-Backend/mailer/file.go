@@ -0,0 +1,56 @@
+// mailer/file.go
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// fromLinePattern matches body lines that mbox readers would take for a message separator.
+var fromLinePattern = regexp.MustCompile(`(?m)^(>*From )`)
+
+// FileMailer appends each message to an mbox file instead of sending it, so local development
+// needs no SMTP server. The file can be opened with most mail clients.
+type FileMailer struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Send appends msg to the mbox file, creating it when needed.
+func (f *FileMailer) Send(_ context.Context, msg *Message) error {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	content := bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte("\n"))
+	content = fromLinePattern.ReplaceAll(content, []byte(">$1"))
+
+	sender := "MAILER-DAEMON"
+	if from, _, err := msg.envelope(); err == nil {
+		sender = from
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "From %s %s\n%s\n", sender, time.Now().UTC().Format(time.ANSIC), content); err != nil {
+		return err
+	}
+	return file.Close()
+}
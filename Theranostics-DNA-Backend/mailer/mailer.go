@@ -0,0 +1,114 @@
+// mailer/mailer.go
+package mailer
+
+import (
+	"context"
+	"html"
+	"io"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Mailer delivers email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Message is an email with a plain-text body, an HTML body or both, plus optional attachments.
+// When only HTML is given, a plain-text alternative is derived from it.
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message. ContentType is guessed from the file name when empty.
+// An inline attachment is shown within the HTML body, which references it as cid:<Filename>.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	Inline      bool
+}
+
+// WriteTo writes the message in MIME format.
+func (msg *Message) WriteTo(w io.Writer) (int64, error) {
+	m := gomail.NewMessage()
+	m.SetHeader("From", msg.From)
+	m.SetHeader("To", msg.To...)
+	m.SetHeader("Subject", msg.Subject)
+
+	text := msg.Text
+	if text == "" && msg.HTML != "" {
+		text = PlainText(msg.HTML)
+	}
+	m.SetBody("text/plain", text)
+	if msg.HTML != "" {
+		m.AddAlternative("text/html", msg.HTML)
+	}
+
+	for _, attachment := range msg.Attachments {
+		data := attachment.Data
+		settings := []gomail.FileSetting{gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})}
+		if attachment.ContentType != "" {
+			settings = append(settings, gomail.SetHeader(map[string][]string{"Content-Type": {attachment.ContentType}}))
+		}
+		if attachment.Inline {
+			m.Embed(attachment.Filename, settings...)
+		} else {
+			m.Attach(attachment.Filename, settings...)
+		}
+	}
+
+	return m.WriteTo(w)
+}
+
+// envelope returns the bare sender and recipient addresses for the SMTP envelope.
+func (msg *Message) envelope() (string, []string, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return "", nil, err
+	}
+	to := make([]string, len(msg.To))
+	for i, recipient := range msg.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return "", nil, err
+		}
+		to[i] = address.Address
+	}
+	return from.Address, to, nil
+}
+
+var (
+	blockEndPattern  = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|table)>`)
+	dropPattern      = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	tagPattern       = regexp.MustCompile(`<[^>]*>`)
+	spacePattern     = regexp.MustCompile(`[ \t]+`)
+	blankLinePattern = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// PlainText renders an HTML email body as readable plain text.
+func PlainText(body string) string {
+	text := dropPattern.ReplaceAllString(body, "")
+	text = blockEndPattern.ReplaceAllString(text, "\n")
+	text = tagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\r", "")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLinePattern.ReplaceAllString(text, "\n\n"))
+}
@@ -0,0 +1,43 @@
+// mailer/memory.go
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer keeps sent messages in memory instead of delivering them. Use it in tests and
+// local development to inspect what would have been sent.
+type MemoryMailer struct {
+	// Limit caps how many messages are kept, dropping the oldest first. Zero keeps them all.
+	Limit int
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send records a copy of msg.
+func (m *MemoryMailer) Send(_ context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, *msg)
+	if m.Limit > 0 && len(m.messages) > m.Limit {
+		m.messages = m.messages[len(m.messages)-m.Limit:]
+	}
+	return nil
+}
+
+// Messages returns the recorded messages, oldest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Reset discards the recorded messages.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
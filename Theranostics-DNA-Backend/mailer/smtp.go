@@ -0,0 +1,119 @@
+// mailer/smtp.go
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// TLS modes for SMTPMailer.
+const (
+	// TLSModeStartTLS upgrades a plain connection and fails if the server can't.
+	TLSModeStartTLS = "starttls"
+	// TLSModeImplicit connects over TLS from the start, usually on port 465.
+	TLSModeImplicit = "tls"
+	// TLSModeNone sends in the clear. Only use it for local capture servers.
+	TLSModeNone = "none"
+)
+
+// defaultSMTPTimeout bounds a send whose context has no deadline.
+const defaultSMTPTimeout = 30 * time.Second
+
+// SMTPMailer sends messages through an SMTP server, authenticating when Username is set.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLSMode  string
+}
+
+// Send delivers msg to the server. The context bounds the whole exchange.
+func (s *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	from, to, err := msg.envelope()
+	if err != nil {
+		return fmt.Errorf("smtp: invalid address: %w", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultSMTPTimeout)
+		defer cancel()
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support authentication")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the server and secures the connection according to TLSMode.
+func (s *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: s.Host, MinVersion: tls.VersionTLS12}
+	if s.TLSMode == TLSModeImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.TLSMode == "" || s.TLSMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
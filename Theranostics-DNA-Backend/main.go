@@ -2,22 +2,118 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/rs/cors"
 
 	"theransticslabs/m/config"
-	"theransticslabs/m/models"
+	"theransticslabs/m/migrations"
 	"theransticslabs/m/routes"
 	"theransticslabs/m/seeds"
+	"theransticslabs/m/shipments"
 	"theransticslabs/m/utils"
 )
 
+// Server timeouts. The write timeout leaves room for payment capture and CSV exports.
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 2 * time.Minute
+	idleTimeout       = 2 * time.Minute
+	shutdownTimeout   = 30 * time.Second
+)
+
+// outboxPollInterval is how often the email outbox is checked for due emails.
+const outboxPollInterval = 5 * time.Second
+
+// shipmentTrackingInterval is how often carriers with a tracking API are asked about a shipment.
+const shipmentTrackingInterval = 30 * time.Minute
+
+const usage = `Usage: theranostics <command> [arguments]
+
+Commands:
+  serve                          Start the API server (the default)
+  migrate up                     Apply pending database migrations
+  migrate down [steps]           Revert the latest migrations (default 1)
+  migrate status                 List migrations and whether they are applied
+  migrate baseline <version>     Mark migrations up to version as applied without running them
+  seed [--env <environment>]     Seed roles and permissions, plus demo users outside production
+  create-superadmin --email <email> --first-name <name> [--last-name <name>]
+                                 Create a super-admin and print their set-password link
+  rotate-keys                    Re-encrypt every encrypted column with the active key
+  regenerate-invoice <id>        Rebuild the PDF of an invoice
+  reconcile-payments [--older-than <duration>] [--dry-run]
+                                 Settle pending payments from their state at PayPal
+`
+
+// commands maps each subcommand to its runner, which returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"serve":              runServe,
+	"migrate":            runMigrate,
+	"seed":               runSeed,
+	"create-superadmin":  runCreateSuperAdmin,
+	"rotate-keys":        runRotateKeys,
+	"regenerate-invoice": runRegenerateInvoice,
+	"reconcile-payments": runReconcilePayments,
+}
+
 func main() {
-	// Load environment variables
-	config.LoadEnv()
+	name, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load and validate the configuration, then set up structured logging
+	if err := config.LoadEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	config.InitLogger()
+	config.InitMailer()
+	config.InitSMS()
+
+	os.Exit(run(args))
+}
+
+// runServe runs the API server.
+func runServe(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	serve()
+	return 0
+}
+
+// serve runs the API server until it receives SIGTERM or Ctrl+C.
+func serve() {
+	// Cancelled on SIGTERM or Ctrl+C to start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Tracks background jobs so shutdown can wait for them
+	var jobs sync.WaitGroup
 
 	allowedOrigins := strings.Split(config.AppConfig.AllowedOrigins, ",")
 
@@ -25,27 +121,69 @@ func main() {
 		AllowedOrigins:   allowedOrigins, // Replace with your frontend URL(s)
 		AllowCredentials: true,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", utils.HeaderRequestID},
+		ExposedHeaders:   []string{utils.HeaderRequestID},
 	})
 
 	// Initialize the Database
 	config.InitDB()
 
-	// Create enum types
-	if err := config.CreateEnumTypes(); err != nil {
-		log.Fatalf("Failed to create enum types: %v", err)
+	// The schema is migrated by the migrate command; refuse to run against an outdated one
+	pending, err := migrations.Pending(ctx, config.DB)
+	if err != nil {
+		slog.Error("Failed to check database migrations", "error", err)
+		os.Exit(1)
+	}
+	if pending > 0 {
+		slog.Error(utils.MsgMigrationsPending, "pending", pending)
+		os.Exit(1)
 	}
 
-	// Auto-Migrate the models
-	err := config.DB.AutoMigrate(&models.Role{}, &models.User{}, &models.Kit{}, &models.Customer{}, &models.Order{}, &models.Payment{}, &models.Invoice{})
-	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	// Encrypt customer rows written before field-level encryption was enabled
+	if count, err := utils.EncryptLegacyCustomers(config.DB); err != nil {
+		slog.Error("Failed to encrypt legacy customer records", "error", err)
+		os.Exit(1)
+	} else if count > 0 {
+		slog.Info("Encrypted legacy customer records", "count", count)
 	}
 
-	log.Println(utils.MsgDatabaseMigrated)
+	// Move stored ciphertexts onto the active encryption key in the background. Each batch is
+	// committed on its own, so a shutdown part way through resumes on the next start.
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		counts, err := utils.ReencryptAll(config.DB.WithContext(ctx), 100)
+		if errors.Is(err, context.Canceled) {
+			slog.Info("Re-encryption paused for shutdown", "counts", counts)
+			return
+		}
+		if err != nil {
+			slog.Error("Re-encryption stopped", "counts", counts, "error", err)
+			return
+		}
+		for table, count := range counts {
+			if count > 0 {
+				slog.Info("Re-encrypted stored records", "table", table, "count", count, "key_id", utils.ActiveKeyID())
+			}
+		}
+	}()
+
+	// Send queued emails in the background until shutdown
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		utils.RunOutboxWorker(ctx, config.DB, outboxPollInterval)
+	}()
 
-	// Run the Seeders
-	seeds.SeedAll()
+	// Poll carriers for shipment progress in the background until shutdown
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		shipments.RunTracker(ctx, config.DB, shipmentTrackingInterval)
+	}()
+
+	// Keep roles and permissions in sync with the code; demo users are seeded by the seed command
+	seeds.SeedReferenceData()
 
 	// Initialize the Router and Routes
 	router := routes.SetupRoutes()
@@ -57,9 +195,58 @@ func main() {
 	// Wrap your router with CORS middleware
 	handler := c.Handler(router)
 
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(config.AppConfig.ServerPort),
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
 	// Start the Server
-	log.Printf(utils.MsgServerStarted, config.AppConfig.ServerPort)
-	if err := http.ListenAndServe(":"+config.AppConfig.ServerPort, handler); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info(utils.MsgServerStarted, "port", config.AppConfig.ServerPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		slog.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// Fail readiness checks and keep serving while load balancers notice, then stop accepting
+	// connections and let in-flight requests and background jobs finish within the shutdown
+	// timeout. A second signal exits immediately.
+	stop()
+	slog.Info("Shutting down")
+	config.SetDraining()
+	time.Sleep(time.Duration(config.AppConfig.ShutdownDrainSeconds) * time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain in-flight requests", "error", err)
+	}
+
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Background jobs did not finish before the shutdown timeout")
+	}
+
+	if sqlDB, err := config.DB.DB(); err == nil {
+		sqlDB.Close()
 	}
+	slog.Info(utils.MsgServerStopped)
 }
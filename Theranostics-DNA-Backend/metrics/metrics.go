@@ -0,0 +1,137 @@
+// metrics/metrics.go
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Order outcomes counted by OrdersTotal.
+const (
+	OrderCreated = "created"
+	OrderPaid    = "paid"
+	OrderFailed  = "failed"
+)
+
+// PayPal API operations timed by PayPalRequestDuration.
+const (
+	PayPalAccessToken = "access_token"
+	PayPalCreateOrder = "create_order"
+	PayPalCapture     = "capture"
+	PayPalGetOrder    = "get_order"
+)
+
+// Registry holds every application metric plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration is labelled by the mux route template rather than the raw path, so
+	// IDs in URLs don't create a series per record.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by route template, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Number of HTTP requests currently being served.",
+	})
+
+	OrdersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_total",
+		Help: "Orders by outcome: created, paid or failed.",
+	}, []string{"outcome"})
+
+	PayPalRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "paypal_request_duration_seconds",
+		Help:    "Duration of PayPal API calls by operation.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"operation"})
+
+	PayPalErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "paypal_errors_total",
+		Help: "Failed PayPal API calls by operation.",
+	}, []string{"operation"})
+
+	EmailsSentTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "emails_sent_total",
+		Help: "Email send attempts by result: success or failure.",
+	}, []string{"result"})
+
+	SMSSentTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sms_sent_total",
+		Help: "SMS send attempts by result: success or failure.",
+	}, []string{"result"})
+
+	WebhooksSentTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhooks_sent_total",
+		Help: "Webhook delivery attempts by result: success or failure.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		OrdersTotal,
+		PayPalRequestDuration,
+		PayPalErrorsTotal,
+		EmailsSentTotal,
+		SMSSentTotal,
+		WebhooksSentTotal,
+	)
+}
+
+// RegisterDBStats exports the connection pool statistics of db.
+func RegisterDBStats(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObservePayPal records the duration of a PayPal call that started at start, counting it as an
+// error when err is set.
+func ObservePayPal(operation string, start time.Time, err error) {
+	PayPalRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		PayPalErrorsTotal.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveEmail counts an email send attempt.
+func ObserveEmail(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	EmailsSentTotal.WithLabelValues(result).Inc()
+}
+
+// ObserveSMS counts an SMS send attempt.
+func ObserveSMS(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	SMSSentTotal.WithLabelValues(result).Inc()
+}
+
+// ObserveWebhook counts a webhook delivery attempt.
+func ObserveWebhook(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	WebhooksSentTotal.WithLabelValues(result).Inc()
+}
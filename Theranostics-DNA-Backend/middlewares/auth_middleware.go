@@ -5,6 +5,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"theransticslabs/m/config"
 	"theransticslabs/m/models"
@@ -16,10 +17,13 @@ import (
 // Define a type for context keys to avoid collisions
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey    = contextKey("user")
+	sessionContextKey = contextKey("session")
+)
 
-// AuthMiddleware verifies the JWT token, ensures the user exists and is active,
-// and sets the user information in the request context.
+// AuthMiddleware verifies the JWT token, ensures the user exists and is active and that the
+// token's session has not been revoked, and sets the user and session in the request context.
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -52,7 +56,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		// Fetch the user from the database
 		var user models.User
-		result := config.DB.Preload("Role").First(&user, userID)
+		result := config.DB.WithContext(r.Context()).Preload("Role").First(&user, userID)
 		if result.Error != nil {
 			if result.Error == gorm.ErrRecordNotFound {
 				utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUserDoesNotExist, nil)
@@ -68,18 +72,53 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Compare the token in the request with the one stored in the database
-		if user.Token != tokenString {
-			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUnauthorizedUser, nil)
+		// Ensure the token belongs to an active session of this user
+		sessionID, ok := claims["sid"].(string)
+		if !ok {
+			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgInvalidTokenClaims, nil)
+			return
+		}
+
+		var session models.Session
+		if err := config.DB.WithContext(r.Context()).Where("id = ? AND user_id = ?", sessionID, user.ID).First(&session).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgUnauthorizedUser, nil)
+				return
+			}
+			utils.JSONResponse(w, http.StatusInternalServerError, false, utils.MsgInternalServerError, nil)
+			return
+		}
+
+		if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			utils.JSONResponse(w, http.StatusUnauthorized, false, utils.MsgSessionRevokedOrExpired, nil)
 			return
 		}
 
-		// Set user information in the context
+		// Users whose role enforces 2FA may only enrol (or log out) until they have done so
+		if utils.TwoFactorEnrolmentPending(user) && !isTwoFactorEnrolmentRoute(r) {
+			utils.JSONResponse(w, http.StatusForbidden, false, utils.MsgTwoFactorEnrolmentRequired, nil)
+			return
+		}
+
+		// Set user and session information in the context
 		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, sessionContextKey, session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// isTwoFactorEnrolmentRoute reports whether the request is allowed before a required 2FA enrolment is complete.
+func isTwoFactorEnrolmentRoute(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/api" + utils.RouteTwoFactorSetup,
+		"/api" + utils.RouteTwoFactorEnable,
+		"/api" + utils.RouteGetUserProfile,
+		"/api" + utils.RouteLogout:
+		return true
+	}
+	return false
+}
+
 // GetUserFromContext retrieves the user information from the context.
 // It returns the user and a boolean indicating whether the user was found.
 func GetUserFromContext(ctx context.Context) (*models.User, bool) {
@@ -89,3 +128,12 @@ func GetUserFromContext(ctx context.Context) (*models.User, bool) {
 	}
 	return &user, true
 }
+
+// GetSessionFromContext retrieves the session the request was authenticated with.
+func GetSessionFromContext(ctx context.Context) (*models.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(models.Session)
+	if !ok {
+		return nil, false
+	}
+	return &session, true
+}
@@ -2,45 +2,62 @@
 package middlewares
 
 import (
-    "log"
-    "net/http"
-    "time"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/utils"
 )
 
-// LoggingMiddleware logs each incoming HTTP request with details such as method, URI, status code, and duration.
+// requestIDPattern limits which incoming request IDs are trusted, so a client can't inject
+// arbitrary text into the logs.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// LoggingMiddleware assigns each request an ID, returns it in the X-Request-ID header, stores it
+// in the request context for later log records and queries, and logs the completed request.
+// An ID set by an upstream proxy is kept so logs can be correlated across services.
 func LoggingMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        // Capture the start time
-        start := time.Now()
-
-        // Use a ResponseWriter wrapper to capture the status code
-        rw := &responseWriter{w, http.StatusOK}
-
-        // Process the request
-        next.ServeHTTP(rw, r)
-
-        // Calculate the duration
-        duration := time.Since(start)
-
-        // Log the details
-        log.Printf(
-            "%s %s %d %s",
-            r.Method,
-            r.RequestURI,
-            rw.statusCode,
-            duration,
-        )
-    })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Capture the start time
+		start := time.Now()
+
+		requestID := r.Header.Get(utils.HeaderRequestID)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID, _ = utils.GenerateRandomToken(16)
+		}
+		w.Header().Set(utils.HeaderRequestID, requestID)
+		r = r.WithContext(config.WithRequestID(r.Context(), requestID))
+
+		// Use a ResponseWriter wrapper to capture the status code
+		rw := &responseWriter{w, http.StatusOK}
+
+		// Process the request
+		next.ServeHTTP(rw, r)
+
+		// Only the path is logged; query strings can carry tokens and email addresses
+		level := slog.LevelInfo
+		if rw.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request completed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rw.statusCode),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
 }
 
 // responseWriter is a wrapper around http.ResponseWriter to capture the status code
 type responseWriter struct {
-    http.ResponseWriter
-    statusCode int
+	http.ResponseWriter
+	statusCode int
 }
 
 // WriteHeader captures the status code for logging
 func (rw *responseWriter) WriteHeader(code int) {
-    rw.statusCode = code
-    rw.ResponseWriter.WriteHeader(code)
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
 }
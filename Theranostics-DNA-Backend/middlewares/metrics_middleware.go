@@ -0,0 +1,36 @@
+// middlewares/metrics_middleware.go
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"theransticslabs/m/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// MetricsMiddleware records the duration and status of each request against the route template
+// it matched, e.g. "/api/kit-info/{id}". Requests that matched no route are recorded as
+// "unmatched", so probing for unknown paths can't create a label per path.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		rw := &responseWriter{w, http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(route, r.Method, strconv.Itoa(rw.statusCode)).
+			Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,104 @@
+// migrate.go
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/migrations"
+	"theransticslabs/m/utils"
+)
+
+// runMigrate runs a migrate subcommand and returns the process exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		if err := config.EnsureDatabase(); err != nil {
+			slog.Error("Failed to prepare database", "error", err)
+			return 1
+		}
+		config.InitDB()
+		applied, err := migrations.Up(config.DB)
+		for _, m := range applied {
+			slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			slog.Error("Failed to migrate database", "error", err)
+			return 1
+		}
+		slog.Info(utils.MsgDatabaseMigrated, "applied", len(applied))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return 2
+			}
+			steps = n
+		}
+		config.InitDB()
+		reverted, err := migrations.Down(config.DB, steps)
+		for _, m := range reverted {
+			slog.Info("Reverted migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			slog.Error("Failed to revert migrations", "error", err)
+			return 1
+		}
+
+	case "status":
+		config.InitDB()
+		list, err := migrations.List(config.DB)
+		if err != nil {
+			slog.Error("Failed to read migration status", "error", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, m := range list {
+			appliedAt := "pending"
+			if m.AppliedAt != nil {
+				appliedAt = m.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, appliedAt)
+		}
+		w.Flush()
+
+	case "baseline":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "baseline needs the version the existing schema matches")
+			return 2
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 1 {
+			fmt.Fprintln(os.Stderr, "version must be a positive number")
+			return 2
+		}
+		config.InitDB()
+		recorded, err := migrations.Baseline(config.DB, version)
+		if err != nil {
+			slog.Error("Failed to baseline migrations", "error", err)
+			return 1
+		}
+		for _, m := range recorded {
+			slog.Info("Marked migration as applied", "version", m.Version, "name", m.Name)
+		}
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	return 0
+}
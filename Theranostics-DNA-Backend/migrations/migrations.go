@@ -0,0 +1,293 @@
+// migrations/migrations.go
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration files live in sql/ and are named "<version>_<name>.up.sql" with a matching
+// ".down.sql". Versions are positive integers applied in ascending order; a released migration
+// must never be edited, only followed by a new one.
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// lockKey serialises migration runs across processes via a Postgres advisory lock.
+const lockKey = 7246150418
+
+const createTableSQL = `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+	"version" bigint PRIMARY KEY,
+	"name" varchar(255) NOT NULL,
+	"applied_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether it has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations in version order.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+
+		content, err := files.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction, and returns the
+// ones it applied. On a database created by AutoMigrate the initial migration only creates the
+// missing tables, and 0010_baseline_upgrade brings the existing ones up to date.
+func Up(db *gorm.DB) ([]Migration, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(createTableSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var applied []Migration
+	for _, m := range all {
+		ran := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+				return err
+			}
+			// Another process may have applied it while we waited for the lock
+			done, err := isApplied(tx, m.Version)
+			if err != nil || done {
+				return err
+			}
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Exec(`INSERT INTO "schema_migrations" ("version", "name") VALUES (?, ?)`, m.Version, m.Name).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// Down reverts the latest steps applied migrations, newest first, and returns the ones it reverted.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(createTableSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	byVersion := map[int]Migration{}
+	for _, m := range all {
+		byVersion[m.Version] = m
+	}
+
+	var reverted []Migration
+	for i := 0; i < steps; i++ {
+		var m Migration
+		done := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+				return err
+			}
+			var versions []int
+			if err := tx.Raw(`SELECT "version" FROM "schema_migrations" ORDER BY "version" DESC LIMIT 1`).Scan(&versions).Error; err != nil {
+				return err
+			}
+			if len(versions) == 0 {
+				done = true
+				return nil
+			}
+			var ok bool
+			if m, ok = byVersion[versions[0]]; !ok {
+				return fmt.Errorf("applied migration %d is not known to this build", versions[0])
+			}
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`DELETE FROM "schema_migrations" WHERE "version" = ?`, m.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("reverting migration failed: %w", err)
+		}
+		if done {
+			break
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+// Baseline records every migration up to and including version as applied without running it.
+// Use it for a database whose schema is already known to match those migrations.
+func Baseline(db *gorm.DB, version int) ([]Migration, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(createTableSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var recorded []Migration
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		for _, m := range all {
+			if m.Version > version {
+				break
+			}
+			result := tx.Exec(`INSERT INTO "schema_migrations" ("version", "name") VALUES (?, ?) ON CONFLICT ("version") DO NOTHING`, m.Version, m.Name)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				recorded = append(recorded, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// List returns every known migration with the time it was applied, if it has been.
+func List(db *gorm.DB) ([]Status, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Status, 0, len(all))
+	for _, m := range all {
+		status := Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		list = append(list, status)
+	}
+	return list, nil
+}
+
+// Pending returns how many known migrations have not been applied.
+func Pending(ctx context.Context, db *gorm.DB) (int, error) {
+	all, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedVersions(db.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, m := range all {
+		if _, ok := applied[m.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// appliedVersions maps applied versions to when they were applied. A database without the
+// schema_migrations table has none.
+func appliedVersions(db *gorm.DB) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+	if !db.Migrator().HasTable("schema_migrations") {
+		return applied, nil
+	}
+
+	var rows []struct {
+		Version   int
+		AppliedAt time.Time
+	}
+	if err := db.Raw(`SELECT "version", "applied_at" FROM "schema_migrations"`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+func isApplied(tx *gorm.DB, version int) (bool, error) {
+	var count int64
+	err := tx.Raw(`SELECT COUNT(*) FROM "schema_migrations" WHERE "version" = ?`, version).Scan(&count).Error
+	return count > 0, err
+}
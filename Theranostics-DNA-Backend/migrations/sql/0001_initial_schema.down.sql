@@ -0,0 +1,22 @@
+-- Drops the whole schema. Only use this to tear down a development database.
+
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "password_tokens";
+DROP TABLE IF EXISTS "account_lock_events";
+DROP TABLE IF EXISTS "login_throttles";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "invoices";
+DROP TABLE IF EXISTS "payments";
+DROP TABLE IF EXISTS "orders";
+DROP TABLE IF EXISTS "product_offers";
+DROP TABLE IF EXISTS "customers";
+DROP TABLE IF EXISTS "kits";
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "permissions";
+
+DROP TYPE IF EXISTS order_status;
+DROP TYPE IF EXISTS payment_status;
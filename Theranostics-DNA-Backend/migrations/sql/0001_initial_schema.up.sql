@@ -0,0 +1,290 @@
+-- Initial schema, matching what GORM AutoMigrate created before versioned migrations.
+-- Every statement is idempotent so databases created by AutoMigrate are baselined by
+-- running this migration: existing objects are left untouched.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'payment_status') THEN
+        CREATE TYPE payment_status AS ENUM ('Pending', 'Completed', 'Failed');
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'order_status') THEN
+        CREATE TYPE order_status AS ENUM ('Pending', 'Shipped', 'Delivered', 'Cancelled');
+    END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+    "id" bigserial,
+    "key" varchar(50) NOT NULL,
+    "description" varchar(255),
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_permissions_key" UNIQUE ("key")
+);
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" bigserial,
+    "name" varchar(20) NOT NULL,
+    "status" boolean DEFAULT true,
+    "two_factor_required" boolean DEFAULT false,
+    "is_system" boolean DEFAULT false,
+    "is_deleted" boolean DEFAULT false,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_roles_name" UNIQUE ("name")
+);
+CREATE INDEX IF NOT EXISTS "idx_roles_deleted_at" ON "roles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+    "role_id" bigint,
+    "permission_id" bigint,
+    PRIMARY KEY ("role_id","permission_id"),
+    CONSTRAINT "fk_role_permissions_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id"),
+    CONSTRAINT "fk_role_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "first_name" varchar(50) NOT NULL,
+    "last_name" varchar(50),
+    "email" varchar(100) NOT NULL,
+    "hash_password" varchar(255),
+    "role_id" bigint NOT NULL,
+    "active_status" boolean DEFAULT true,
+    "is_deleted" boolean DEFAULT false,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "deleted_at" timestamptz,
+    "two_factor_enabled" boolean DEFAULT false,
+    "two_factor_secret" text,
+    "two_factor_last_step" bigint DEFAULT 0,
+    "two_factor_confirmed_at" timestamp,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_roles_users" FOREIGN KEY ("role_id") REFERENCES "roles"("id"),
+    CONSTRAINT "uni_users_email" UNIQUE ("email")
+);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "kits" (
+    "id" bigserial,
+    "type" varchar(10) NOT NULL,
+    "quantity" bigint NOT NULL DEFAULT 0,
+    "extra_info" json,
+    "created_by" bigint NOT NULL,
+    "status" boolean DEFAULT true,
+    "is_deleted" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_kits" FOREIGN KEY ("created_by") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_kits_deleted_at" ON "kits" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "customers" (
+    "id" bigserial NOT NULL,
+    "first_name" text NOT NULL,
+    "last_name" text,
+    "email" text NOT NULL,
+    "email_hash" varchar(64),
+    "phone_number" text NOT NULL,
+    "country" varchar(50) NOT NULL,
+    "street_address" text NOT NULL,
+    "town_city" text NOT NULL,
+    "region" text,
+    "postcode" text NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "is_deleted" boolean DEFAULT false,
+    "erased_at" timestamp,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_customers_email_hash" ON "customers" ("email_hash");
+CREATE INDEX IF NOT EXISTS "idx_customers_deleted_at" ON "customers" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "product_offers" (
+    "id" bigserial,
+    "nonce" varchar(64) NOT NULL,
+    "product_name" varchar(100) NOT NULL,
+    "product_description" text,
+    "product_image" text,
+    "product_price" decimal(10,2) NOT NULL,
+    "customer_email" varchar(100),
+    "max_uses" bigint NOT NULL DEFAULT 0,
+    "used_count" bigint NOT NULL DEFAULT 0,
+    "issued_at" timestamp NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "created_by" bigint NOT NULL,
+    "is_deleted" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_offers_created_by_user" FOREIGN KEY ("created_by") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_product_offers_deleted_at" ON "product_offers" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_product_offers_nonce" ON "product_offers" ("nonce");
+
+CREATE TABLE IF NOT EXISTS "orders" (
+    "id" bigserial NOT NULL,
+    "customer_id" bigint NOT NULL,
+    "product_offer_id" bigint,
+    "product_name" varchar(100) NOT NULL,
+    "product_description" text,
+    "product_image" text,
+    "product_price" decimal(10,2) NOT NULL,
+    "quantity" bigint NOT NULL,
+    "total_price" decimal(10,2) NOT NULL,
+    "payment_status" varchar(50) NOT NULL,
+    "order_status" varchar(50) NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "is_deleted" boolean DEFAULT false,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_product_offer" FOREIGN KEY ("product_offer_id") REFERENCES "product_offers"("id"),
+    CONSTRAINT "fk_customers_orders" FOREIGN KEY ("customer_id") REFERENCES "customers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_orders_deleted_at" ON "orders" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_orders_product_offer_id" ON "orders" ("product_offer_id");
+
+CREATE TABLE IF NOT EXISTS "payments" (
+    "id" bigserial NOT NULL,
+    "order_id" bigint NOT NULL,
+    "payment_status" varchar(50) NOT NULL,
+    "transaction_id" varchar(100) NOT NULL,
+    "amount" decimal(10,2) NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "is_deleted" boolean DEFAULT false,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_payments" FOREIGN KEY ("order_id") REFERENCES "orders"("id"),
+    CONSTRAINT "uni_payments_transaction_id" UNIQUE ("transaction_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payments_deleted_at" ON "payments" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "invoices" (
+    "id" bigserial NOT NULL,
+    "payment_id" bigint NOT NULL,
+    "invoice_link" varchar(255) NOT NULL,
+    "price" decimal(10,2) NOT NULL,
+    "invoice_id" varchar(100) NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "is_deleted" boolean DEFAULT false,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_payments_invoices" FOREIGN KEY ("payment_id") REFERENCES "payments"("id"),
+    CONSTRAINT "uni_invoices_invoice_id" UNIQUE ("invoice_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_invoices_deleted_at" ON "invoices" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "device_name" varchar(100),
+    "user_agent" varchar(255),
+    "ip_address" varchar(45),
+    "last_used_at" timestamp NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "revoked_at" timestamp,
+    "revoked_reason" varchar(50),
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_sessions" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_deleted_at" ON "sessions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+    "id" bigserial,
+    "session_id" bigint NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "used_at" timestamp,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_refresh_tokens" FOREIGN KEY ("session_id") REFERENCES "sessions"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_session_id" ON "refresh_tokens" ("session_id");
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "used_at" timestamp,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_recovery_codes" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "login_throttles" (
+    "id" bigserial,
+    "key" varchar(100) NOT NULL,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "first_failure_at" timestamp NOT NULL,
+    "next_attempt_at" timestamp,
+    "locked_until" timestamp,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_login_throttles_key" ON "login_throttles" ("key");
+
+CREATE TABLE IF NOT EXISTS "account_lock_events" (
+    "id" bigserial,
+    "user_id" bigint,
+    "scope" varchar(30) NOT NULL,
+    "event" varchar(20) NOT NULL,
+    "ip_address" varchar(45),
+    "locked_until" timestamp,
+    "actor_id" bigint,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_account_lock_events_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_account_lock_events_user_id" ON "account_lock_events" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "password_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "purpose" varchar(20) NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "used_at" timestamp,
+    "created_by" bigint,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_password_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_password_tokens_user_id" ON "password_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_password_tokens_token_hash" ON "password_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" bigserial,
+    "actor_id" bigint,
+    "actor_email" varchar(100),
+    "action" varchar(50) NOT NULL,
+    "entity_type" varchar(30) NOT NULL,
+    "entity_id" varchar(50),
+    "changes" jsonb NOT NULL DEFAULT '{}',
+    "ip_address" varchar(45),
+    "request_id" varchar(64),
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_request_id" ON "audit_logs" ("request_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_entity" ON "audit_logs" ("entity_type","entity_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
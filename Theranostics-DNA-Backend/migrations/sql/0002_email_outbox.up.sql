@@ -0,0 +1,16 @@
+CREATE TABLE "email_outbox" (
+    "id" bigserial,
+    "recipients" text NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "body" text NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamp NOT NULL,
+    "last_error" text,
+    "sent_at" timestamp,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_email_outbox_due" ON "email_outbox" ("status", "next_attempt_at");
+CREATE INDEX "idx_email_outbox_created_at" ON "email_outbox" ("created_at");
@@ -0,0 +1,2 @@
+ALTER TABLE "customers" DROP COLUMN IF EXISTS "language";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "language";
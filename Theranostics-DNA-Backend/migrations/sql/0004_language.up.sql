@@ -0,0 +1,2 @@
+ALTER TABLE "users" ADD COLUMN "language" varchar(10) NOT NULL DEFAULT 'en';
+ALTER TABLE "customers" ADD COLUMN "language" varchar(10) NOT NULL DEFAULT 'en';
@@ -0,0 +1,28 @@
+CREATE TABLE "sms_outbox" (
+    "id" bigserial,
+    "phone_number" text NOT NULL,
+    "body" text NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamp NOT NULL,
+    "last_error" text,
+    "sent_at" timestamp,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_sms_outbox_due" ON "sms_outbox" ("status", "next_attempt_at");
+CREATE INDEX "idx_sms_outbox_created_at" ON "sms_outbox" ("created_at");
+
+CREATE TABLE "notification_preferences" (
+    "id" bigserial,
+    "recipient_type" varchar(20) NOT NULL,
+    "recipient_id" bigint NOT NULL,
+    "event" varchar(50) NOT NULL,
+    "channel" varchar(20) NOT NULL,
+    "enabled" boolean NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_notification_preferences_key" ON "notification_preferences" ("recipient_type", "recipient_id", "event", "channel");
@@ -0,0 +1,36 @@
+CREATE TABLE "webhook_subscriptions" (
+    "id" bigserial,
+    "description" varchar(255),
+    "url" text NOT NULL,
+    "secret" text NOT NULL,
+    "events" text NOT NULL,
+    "active" boolean NOT NULL DEFAULT true,
+    "is_deleted" boolean DEFAULT false,
+    "created_by" bigint NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "webhook_deliveries" (
+    "id" bigserial,
+    "subscription_id" bigint NOT NULL,
+    "event_id" varchar(64) NOT NULL,
+    "event" varchar(50) NOT NULL,
+    "payload" jsonb NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamp NOT NULL,
+    "response_status" bigint NOT NULL DEFAULT 0,
+    "last_error" text,
+    "sent_at" timestamp,
+    "replay_of_id" bigint,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_deliveries_subscription" FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_webhook_deliveries_subscription_id" ON "webhook_deliveries" ("subscription_id");
+CREATE INDEX "idx_webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
+CREATE INDEX "idx_webhook_deliveries_due" ON "webhook_deliveries" ("status", "next_attempt_at");
+CREATE INDEX "idx_webhook_deliveries_created_at" ON "webhook_deliveries" ("created_at");
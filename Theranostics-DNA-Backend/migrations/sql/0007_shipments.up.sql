@@ -0,0 +1,19 @@
+CREATE TABLE "shipments" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL,
+    "direction" varchar(20) NOT NULL,
+    "carrier" varchar(50) NOT NULL,
+    "tracking_number" varchar(100),
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "carrier_status" varchar(255),
+    "shipped_at" timestamp,
+    "delivered_at" timestamp,
+    "last_checked_at" timestamp,
+    "created_by" bigint NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_shipments_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_shipments_order_id" ON "shipments" ("order_id");
+CREATE INDEX "idx_shipments_tracking" ON "shipments" ("status", "last_checked_at");
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "return_labels";
@@ -0,0 +1,11 @@
+CREATE TABLE "return_labels" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL,
+    "unit" bigint NOT NULL,
+    "kit_serial" varchar(32) NOT NULL,
+    "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_return_labels_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX "idx_return_labels_order_unit" ON "return_labels" ("order_id", "unit");
+CREATE UNIQUE INDEX "idx_return_labels_kit_serial" ON "return_labels" ("kit_serial");
@@ -0,0 +1,21 @@
+DROP TRIGGER IF EXISTS "trg_invoices_immutable" ON "invoices";
+DROP FUNCTION IF EXISTS "invoices_immutable"();
+DROP INDEX IF EXISTS "idx_invoices_number";
+ALTER TABLE "invoices"
+    DROP COLUMN IF EXISTS "series",
+    DROP COLUMN IF EXISTS "year",
+    DROP COLUMN IF EXISTS "sequence",
+    DROP COLUMN IF EXISTS "issued_at",
+    DROP COLUMN IF EXISTS "currency",
+    DROP COLUMN IF EXISTS "seller_name",
+    DROP COLUMN IF EXISTS "seller_address",
+    DROP COLUMN IF EXISTS "seller_tax_id",
+    DROP COLUMN IF EXISTS "billing_name",
+    DROP COLUMN IF EXISTS "billing_address",
+    DROP COLUMN IF EXISTS "billing_email",
+    DROP COLUMN IF EXISTS "line_items",
+    DROP COLUMN IF EXISTS "subtotal",
+    DROP COLUMN IF EXISTS "tax_rate",
+    DROP COLUMN IF EXISTS "tax_amount",
+    DROP COLUMN IF EXISTS "payment_reference";
+DROP TABLE IF EXISTS "invoice_sequences";
@@ -0,0 +1,61 @@
+CREATE TABLE "invoice_sequences" (
+    "series" varchar(10) NOT NULL,
+    "year" bigint NOT NULL,
+    "last_number" bigint NOT NULL,
+    PRIMARY KEY ("series", "year")
+);
+
+ALTER TABLE "invoices"
+    ADD COLUMN "series" varchar(10),
+    ADD COLUMN "year" bigint,
+    ADD COLUMN "sequence" bigint,
+    ADD COLUMN "issued_at" timestamp,
+    ADD COLUMN "currency" varchar(3),
+    ADD COLUMN "seller_name" varchar(255),
+    ADD COLUMN "seller_address" text,
+    ADD COLUMN "seller_tax_id" varchar(50),
+    ADD COLUMN "billing_name" text,
+    ADD COLUMN "billing_address" text,
+    ADD COLUMN "billing_email" text,
+    ADD COLUMN "line_items" jsonb,
+    ADD COLUMN "subtotal" decimal(10,2),
+    ADD COLUMN "tax_rate" decimal(5,2),
+    ADD COLUMN "tax_amount" decimal(10,2),
+    ADD COLUMN "payment_reference" varchar(100);
+
+-- Existing invoices keep their payment ID numbers. Fill in what the database already knows;
+-- their billing details are encrypted on the customer and can't be copied here.
+UPDATE "invoices" SET
+    "issued_at" = COALESCE("invoices"."created_at", CURRENT_TIMESTAMP),
+    "currency" = 'USD',
+    "subtotal" = "invoices"."price",
+    "tax_rate" = 0,
+    "tax_amount" = 0,
+    "payment_reference" = "payments"."transaction_id",
+    "line_items" = jsonb_build_array(jsonb_build_object(
+        'description', "orders"."product_name",
+        'quantity', "orders"."quantity",
+        'unit_price', "orders"."product_price",
+        'amount', "invoices"."price"
+    ))
+FROM "payments"
+JOIN "orders" ON "orders"."id" = "payments"."order_id"
+WHERE "payments"."id" = "invoices"."payment_id";
+
+ALTER TABLE "invoices"
+    ALTER COLUMN "issued_at" SET NOT NULL,
+    ALTER COLUMN "currency" SET NOT NULL,
+    ALTER COLUMN "line_items" SET NOT NULL,
+    ALTER COLUMN "subtotal" SET NOT NULL,
+    ALTER COLUMN "tax_rate" SET NOT NULL,
+    ALTER COLUMN "tax_amount" SET NOT NULL;
+CREATE UNIQUE INDEX "idx_invoices_number" ON "invoices" ("series", "year", "sequence");
+
+-- Issued invoices are accounting records: refuse any change or deletion
+CREATE FUNCTION "invoices_immutable"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'invoice % has been issued and can''t be changed', OLD."invoice_id";
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER "trg_invoices_immutable" BEFORE UPDATE OR DELETE ON "invoices"
+    FOR EACH ROW EXECUTE FUNCTION "invoices_immutable"();
@@ -0,0 +1,3 @@
+-- Nothing to undo: the upgrade only brings old databases in line with 0001_initial_schema, and
+-- reverting it would break the schema every later migration expects.
+SELECT 1;
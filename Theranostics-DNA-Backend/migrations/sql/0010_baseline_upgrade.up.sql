@@ -0,0 +1,61 @@
+-- Brings tables created by the old boot-time AutoMigrate up to 0001_initial_schema, whose
+-- CREATE TABLE IF NOT EXISTS statements left them untouched. Every statement is idempotent, so
+-- this is a no-op on databases created by the migrations.
+
+-- roles and users: two-factor authentication and system roles
+ALTER TABLE "roles"
+    ADD COLUMN IF NOT EXISTS "two_factor_required" boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "is_system" boolean DEFAULT false;
+
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "two_factor_enabled" boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "two_factor_secret" text,
+    ADD COLUMN IF NOT EXISTS "two_factor_last_step" bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "two_factor_confirmed_at" timestamp,
+    -- Plaintext reset tokens, replaced by the hashed password_tokens
+    DROP COLUMN IF EXISTS "token";
+
+-- customers: personal data is encrypted, so its columns must hold ciphertext. The email is
+-- looked up by its blind index instead of a unique plaintext column.
+ALTER TABLE "customers"
+    ALTER COLUMN "first_name" TYPE text,
+    ALTER COLUMN "last_name" TYPE text,
+    ALTER COLUMN "email" TYPE text,
+    ALTER COLUMN "phone_number" TYPE text,
+    ALTER COLUMN "street_address" TYPE text,
+    ALTER COLUMN "town_city" TYPE text,
+    ALTER COLUMN "region" TYPE text,
+    ALTER COLUMN "postcode" TYPE text,
+    ADD COLUMN IF NOT EXISTS "email_hash" varchar(64),
+    ADD COLUMN IF NOT EXISTS "erased_at" timestamp,
+    DROP CONSTRAINT IF EXISTS "uni_customers_email";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_customers_email_hash" ON "customers" ("email_hash");
+
+-- orders: product offers
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "product_offer_id" bigint;
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "fk_orders_product_offer";
+ALTER TABLE "orders" ADD CONSTRAINT "fk_orders_product_offer"
+    FOREIGN KEY ("product_offer_id") REFERENCES "product_offers"("id");
+CREATE INDEX IF NOT EXISTS "idx_orders_product_offer_id" ON "orders" ("product_offer_id");
+
+-- Foreign keys cascade as the models declare. Recreated so both kinds of database end up with
+-- the same definitions.
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "fk_roles_users";
+ALTER TABLE "users" ADD CONSTRAINT "fk_roles_users"
+    FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE "kits" DROP CONSTRAINT IF EXISTS "fk_users_kits";
+ALTER TABLE "kits" ADD CONSTRAINT "fk_users_kits"
+    FOREIGN KEY ("created_by") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "fk_customers_orders";
+ALTER TABLE "orders" ADD CONSTRAINT "fk_customers_orders"
+    FOREIGN KEY ("customer_id") REFERENCES "customers"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "fk_orders_payments";
+ALTER TABLE "payments" ADD CONSTRAINT "fk_orders_payments"
+    FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE "invoices" DROP CONSTRAINT IF EXISTS "fk_payments_invoices";
+ALTER TABLE "invoices" ADD CONSTRAINT "fk_payments_invoices"
+    FOREIGN KEY ("payment_id") REFERENCES "payments"("id") ON DELETE CASCADE ON UPDATE CASCADE;
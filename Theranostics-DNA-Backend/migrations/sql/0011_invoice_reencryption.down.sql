@@ -0,0 +1,5 @@
+CREATE OR REPLACE FUNCTION "invoices_immutable"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'invoice % has been issued and can''t be changed', OLD."invoice_id";
+END;
+$$ LANGUAGE plpgsql;
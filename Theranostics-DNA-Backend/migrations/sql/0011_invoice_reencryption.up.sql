@@ -0,0 +1,13 @@
+-- Issued invoices stay immutable, except that their billing snapshot may be rewritten onto a new
+-- encryption key by rotate-keys: an update touching nothing but the encrypted billing columns is
+-- let through, so old keys can be retired.
+CREATE OR REPLACE FUNCTION "invoices_immutable"() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND to_jsonb(NEW) - ARRAY['billing_name', 'billing_address', 'billing_email']
+            = to_jsonb(OLD) - ARRAY['billing_name', 'billing_address', 'billing_email'] THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'invoice % has been issued and can''t be changed', OLD."invoice_id";
+END;
+$$ LANGUAGE plpgsql;
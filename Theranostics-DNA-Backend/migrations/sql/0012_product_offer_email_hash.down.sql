@@ -0,0 +1,5 @@
+-- Encrypted emails don't fit the old column, so they are dropped along with their index
+UPDATE "product_offers" SET "customer_email" = NULL WHERE length("customer_email") > 100;
+ALTER TABLE "product_offers"
+    DROP COLUMN IF EXISTS "customer_email_hash",
+    ALTER COLUMN "customer_email" TYPE varchar(100);
@@ -0,0 +1,6 @@
+-- The email an offer is bound to is personal data: it is now stored encrypted, which needs a
+-- text column, and matched by its blind index. Offers minted before keep an empty index and
+-- are matched on the decrypted email; rotate-keys encrypts their plaintext.
+ALTER TABLE "product_offers"
+    ALTER COLUMN "customer_email" TYPE text,
+    ADD COLUMN "customer_email_hash" varchar(64);
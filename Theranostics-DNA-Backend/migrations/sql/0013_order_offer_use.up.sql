@@ -0,0 +1,6 @@
+-- An order now takes a use of its product offer before its payment is captured, and gives it
+-- back if the capture or the payment fails, so the offer needn't stay locked while PayPal is
+-- called. Paid orders have already been counted.
+ALTER TABLE "orders" ADD COLUMN "offer_use_counted" boolean NOT NULL DEFAULT false;
+UPDATE "orders" SET "offer_use_counted" = true
+WHERE "product_offer_id" IS NOT NULL AND "payment_status" = 'Completed';
@@ -0,0 +1,62 @@
+// models/audit_log.go
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditChange holds the old and new value of one field. Before is omitted for created
+// records and After for deleted ones.
+type AuditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditChanges maps field names to their change, stored in the changes JSON column.
+type AuditChanges map[string]AuditChange
+
+// Value makes AuditChanges implement the driver.Valuer interface.
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan makes AuditChanges implement the sql.Scanner interface.
+func (c *AuditChanges) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*c = nil
+		return nil
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(data, c)
+}
+
+// AuditLog records one action taken by a staff member. Entries are append-only.
+type AuditLog struct {
+	ID         uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID    *uint        `gorm:"index" json:"actor_id,omitempty"`                                          // Staff user who performed the action
+	ActorEmail string       `gorm:"type:varchar(100)" json:"actor_email"`                                     // Kept so entries stay readable if the actor's email changes
+	Action     string       `gorm:"type:varchar(50);not null;index" json:"action"`                            // Dotted action name, e.g. "kit.update"
+	EntityType string       `gorm:"type:varchar(30);not null;index:idx_audit_logs_entity" json:"entity_type"` // Kind of record affected, e.g. "kit"
+	EntityID   string       `gorm:"type:varchar(50);index:idx_audit_logs_entity" json:"entity_id"`            // ID of the record affected
+	Changes    AuditChanges `gorm:"type:jsonb;not null;default:'{}'" json:"changes"`                          // Field-level before/after diff
+	IPAddress  string       `gorm:"type:varchar(45)" json:"ip_address"`                                       // Client IP of the request
+	RequestID  string       `gorm:"type:varchar(64);index" json:"request_id"`                                 // Correlates the entry with request logs
+	CreatedAt  time.Time    `gorm:"type:timestamp;default:CURRENT_TIMESTAMP;index" json:"created_at"`         // When the action happened
+}
@@ -8,21 +8,24 @@ import (
 	"gorm.io/gorm"
 )
 
-// Customer model
+// Customer model. Personal data columns are encrypted at rest via the "encrypted" serializer.
 type Customer struct {
 	ID            uint           `gorm:"primaryKey;autoIncrement;not null" json:"id"`
-	FirstName     string         `gorm:"type:varchar(50);not null" json:"first_name" validate:"required"`
-	LastName      string         `gorm:"type:varchar(50);null" json:"last_name"`
-	Email         string         `gorm:"type:varchar(100);not null;unique" json:"email" validate:"required,email"`
-	PhoneNumber   string         `gorm:"type:varchar(15);not null" json:"phone_number" validate:"required"`
+	FirstName     string         `gorm:"type:text;not null;serializer:encrypted" json:"first_name" validate:"required"`
+	LastName      string         `gorm:"type:text;null;serializer:encrypted" json:"last_name"`
+	Email         string         `gorm:"type:text;not null;serializer:encrypted" json:"email" validate:"required,email"`
+	EmailHash     string         `gorm:"type:varchar(64);uniqueIndex" json:"-"` // Keyed HMAC of the email, used for lookups since Email is encrypted
+	PhoneNumber   string         `gorm:"type:text;not null;serializer:encrypted" json:"phone_number" validate:"required"`
 	Country       string         `gorm:"type:varchar(50);not null" json:"country" validate:"required"`
-	StreetAddress string         `gorm:"type:varchar(255);not null" json:"street_address" validate:"required"`
-	TownCity      string         `gorm:"type:varchar(100);not null" json:"town_city" validate:"required"`
-	Region        string         `gorm:"type:varchar(100)" json:"region"`
-	Postcode      string         `gorm:"type:varchar(20); not null" json:"postcode"`
+	StreetAddress string         `gorm:"type:text;not null;serializer:encrypted" json:"street_address" validate:"required"`
+	TownCity      string         `gorm:"type:text;not null;serializer:encrypted" json:"town_city" validate:"required"`
+	Region        string         `gorm:"type:text;serializer:encrypted" json:"region"`
+	Postcode      string         `gorm:"type:text;not null;serializer:encrypted" json:"postcode"`
+	Language      string         `gorm:"type:varchar(10);not null;default:'en'" json:"language"` // Locale of the emails sent to the customer
 	Orders        []Order        `gorm:"foreignKey:CustomerID" json:"customers,omitempty"`
 	CreatedAt     time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt     time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
 	IsDeleted     bool           `gorm:"default:false" json:"is_deleted"`
+	ErasedAt      *time.Time     `gorm:"type:timestamp;null" json:"erased_at,omitempty"` // Set once the customer's PII has been anonymised
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -0,0 +1,73 @@
+// models/email_outbox.go
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// OutboxAttachment names a file to attach when the email is sent. The file is read at send time,
+// so it must outlive the queued email.
+type OutboxAttachment struct {
+	Filename    string `json:"filename"`               // Name shown to the recipient
+	Path        string `json:"path"`                   // Location of the file on disk
+	ContentType string `json:"content_type,omitempty"` // MIME type, guessed from Filename when empty
+	Temporary   bool   `json:"temporary,omitempty"`    // Deleted once the email is sent or given up on
+}
+
+// OutboxAttachments is stored in the attachments JSON column.
+type OutboxAttachments []OutboxAttachment
+
+// Value makes OutboxAttachments implement the driver.Valuer interface.
+func (a OutboxAttachments) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan makes OutboxAttachments implement the sql.Scanner interface.
+func (a *OutboxAttachments) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*a = nil
+		return nil
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(data, a)
+}
+
+// EmailOutbox is an email queued in the same transaction as the change it reports and sent
+// afterwards by the outbox worker. Recipients and body are encrypted at rest because they carry
+// personal data and single-use links, and so is the last error, which can quote an address.
+type EmailOutbox struct {
+	ID            uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	Recipients    string            `gorm:"type:text;not null;serializer:encrypted" json:"recipients"`                            // Comma-separated recipient addresses
+	Subject       string            `gorm:"type:varchar(255);not null" json:"subject"`                                            // Email subject
+	Body          string            `gorm:"type:text;not null;serializer:encrypted" json:"-"`                                     // HTML body, hidden in API responses
+	Attachments   OutboxAttachments `gorm:"type:jsonb;not null;default:'[]'" json:"attachments"`                                  // Files attached when sending
+	Status        string            `gorm:"type:varchar(20);not null;default:'pending';index:idx_email_outbox_due" json:"status"` // "pending", "sent" or "dead"
+	Attempts      int               `gorm:"not null;default:0" json:"attempts"`                                                   // Send attempts so far
+	NextAttemptAt time.Time         `gorm:"type:timestamp;not null;index:idx_email_outbox_due" json:"next_attempt_at"`            // When the worker may next try to send it
+	LastError     string            `gorm:"type:text;serializer:encrypted" json:"last_error,omitempty"`                           // Error from the latest failed attempt
+	SentAt        *time.Time        `gorm:"type:timestamp;null" json:"sent_at,omitempty"`                                         // Set once the email is accepted by the SMTP server
+	CreatedAt     time.Time         `gorm:"type:timestamp;default:CURRENT_TIMESTAMP;index" json:"created_at"`                     // Timestamp of creation
+	UpdatedAt     time.Time         `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`                           // Timestamp of last update
+}
+
+// TableName keeps the table name singular, as an outbox is one queue.
+func (EmailOutbox) TableName() string {
+	return "email_outbox"
+}
@@ -1,23 +1,94 @@
-// models/payment.go
+// models/invoice.go
 
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// Invoice model
+// Invoice model. An invoice is a snapshot of the sale taken when it is issued, so its PDF can be
+// rebuilt from the row alone. Rows can't be changed or deleted once written; the database rejects
+// it, except for rewriting the encrypted billing columns onto a new key. Invoices issued before
+// numbering was introduced have no series and carry the payment ID as their InvoiceID.
 type Invoice struct {
-	ID          uint           `gorm:"primaryKey;autoIncrement;not null" json:"id"`
-	PaymentID   uint           `gorm:"not null" json:"payment_id" validate:"required"`
-	Payment     Payment        `gorm:"foreignKey:PaymentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"payment,omitempty"`
-	InvoiceLink string         `gorm:"type:varchar(255);not null" json:"invoice_link" validate:"required,url"`
-	Price       float64        `gorm:"type:decimal(10,2);not null" json:"price" validate:"required,gt=0"`
-	InvoiceID   string         `gorm:"type:varchar(100);not null;unique" json:"invoice_id" validate:"required"`
-	CreatedAt   time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt   time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
-	IsDeleted   bool           `gorm:"default:false" json:"is_deleted"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uint             `gorm:"primaryKey;autoIncrement;not null" json:"id"`
+	PaymentID        uint             `gorm:"not null" json:"payment_id" validate:"required"`
+	Payment          Payment          `gorm:"foreignKey:PaymentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"payment,omitempty"`
+	InvoiceLink      string           `gorm:"type:varchar(255);not null" json:"invoice_link" validate:"required,url"`
+	Price            float64          `gorm:"type:decimal(10,2);not null" json:"price" validate:"required,gt=0"`       // Total including tax
+	InvoiceID        string           `gorm:"type:varchar(100);not null;unique" json:"invoice_id" validate:"required"` // Invoice number, e.g. INV-2026-000042
+	Series           string           `gorm:"type:varchar(10);uniqueIndex:idx_invoices_number" json:"series,omitempty"`
+	Year             int              `gorm:"uniqueIndex:idx_invoices_number" json:"year,omitempty"`
+	Sequence         int              `gorm:"uniqueIndex:idx_invoices_number" json:"sequence,omitempty"` // Numbered from 1 per series and year, without gaps
+	IssuedAt         time.Time        `gorm:"type:timestamp;not null" json:"issued_at"`
+	Currency         string           `gorm:"type:varchar(3);not null" json:"currency"`
+	SellerName       string           `gorm:"type:varchar(255)" json:"seller_name"`
+	SellerAddress    string           `gorm:"type:text" json:"seller_address"` // One address line per line
+	SellerTaxID      string           `gorm:"type:varchar(50)" json:"seller_tax_id"`
+	BillingName      string           `gorm:"type:text;serializer:encrypted" json:"billing_name"`
+	BillingAddress   string           `gorm:"type:text;serializer:encrypted" json:"billing_address"` // One address line per line
+	BillingEmail     string           `gorm:"type:text;serializer:encrypted" json:"billing_email"`
+	LineItems        InvoiceLineItems `gorm:"type:jsonb;not null" json:"line_items"`
+	Subtotal         float64          `gorm:"type:decimal(10,2);not null" json:"subtotal"` // Total excluding tax
+	TaxRate          float64          `gorm:"type:decimal(5,2);not null" json:"tax_rate"`  // Percentage included in the prices
+	TaxAmount        float64          `gorm:"type:decimal(10,2);not null" json:"tax_amount"`
+	PaymentReference string           `gorm:"type:varchar(100)" json:"payment_reference"` // The payment provider's transaction ID
+	CreatedAt        time.Time        `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt        time.Time        `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	IsDeleted        bool             `gorm:"default:false" json:"is_deleted"`
+	DeletedAt        gorm.DeletedAt   `gorm:"index" json:"-"`
+}
+
+// InvoiceSequence holds the last invoice number used in a series and year. Numbers are taken by
+// incrementing it in the transaction that creates the invoice, so a rolled back invoice gives
+// its number back.
+type InvoiceSequence struct {
+	Series     string `gorm:"type:varchar(10);primaryKey" json:"series"`
+	Year       int    `gorm:"primaryKey" json:"year"`
+	LastNumber int    `gorm:"not null" json:"last_number"`
+}
+
+// InvoiceLineItem is one line of an invoice. Amounts include tax.
+type InvoiceLineItem struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+}
+
+// InvoiceLineItems is stored in the line_items JSON column.
+type InvoiceLineItems []InvoiceLineItem
+
+// Value makes InvoiceLineItems implement the driver.Valuer interface.
+func (l InvoiceLineItems) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan makes InvoiceLineItems implement the sql.Scanner interface.
+func (l *InvoiceLineItems) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*l = nil
+		return nil
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(data, l)
 }
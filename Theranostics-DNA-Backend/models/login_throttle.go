@@ -0,0 +1,29 @@
+// models/login_throttle.go
+package models
+
+import "time"
+
+// LoginThrottle tracks failed attempts for one throttle key, such as an account or a client IP.
+type LoginThrottle struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key            string     `gorm:"type:varchar(100);not null;uniqueIndex" json:"key"`          // Scope and hashed subject, e.g. "login:ip:<hash>"
+	Failures       int        `gorm:"not null;default:0" json:"failures"`                         // Failed attempts in the current window
+	FirstFailureAt time.Time  `gorm:"type:timestamp;not null" json:"first_failure_at"`            // Start of the current window
+	NextAttemptAt  *time.Time `gorm:"type:timestamp;null" json:"next_attempt_at,omitempty"`       // Progressive delay before the next attempt
+	LockedUntil    *time.Time `gorm:"type:timestamp;null" json:"locked_until,omitempty"`          // Temporary lockout
+	CreatedAt      time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"` // Timestamp of creation
+	UpdatedAt      time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"` // Timestamp of last update
+}
+
+// AccountLockEvent records a temporary lockout being applied or lifted.
+type AccountLockEvent struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      *uint      `gorm:"index" json:"user_id,omitempty"`                                                          // Affected staff user, when the key belongs to a known account
+	User        *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"` // Affected staff user
+	Scope       string     `gorm:"type:varchar(30);not null" json:"scope"`                                                  // Throttle scope, e.g. "login:account"
+	Event       string     `gorm:"type:varchar(20);not null" json:"event"`                                                  // "locked" or "unlocked"
+	IPAddress   string     `gorm:"type:varchar(45)" json:"ip_address"`                                                      // Client IP that triggered the event
+	LockedUntil *time.Time `gorm:"type:timestamp;null" json:"locked_until,omitempty"`                                       // End of the lockout for "locked" events
+	ActorID     *uint      `gorm:"null" json:"actor_id,omitempty"`                                                          // Super-admin who lifted the lock
+	CreatedAt   time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`                              // Timestamp of the event
+}
@@ -0,0 +1,34 @@
+// models/models.go
+
+package models
+
+// All returns one value of every model stored in its own table. Tools that walk the schema,
+// such as key rotation, rely on it, so add new models here.
+func All() []interface{} {
+	return []interface{}{
+		&AccountLockEvent{},
+		&AuditLog{},
+		&Customer{},
+		&EmailOutbox{},
+		&Invoice{},
+		&InvoiceSequence{},
+		&Kit{},
+		&LoginThrottle{},
+		&NotificationPreference{},
+		&Order{},
+		&PasswordToken{},
+		&Payment{},
+		&Permission{},
+		&ProductOffer{},
+		&RecoveryCode{},
+		&RefreshToken{},
+		&ReturnLabel{},
+		&Role{},
+		&Session{},
+		&Shipment{},
+		&SMSOutbox{},
+		&User{},
+		&WebhookDelivery{},
+		&WebhookSubscription{},
+	}
+}
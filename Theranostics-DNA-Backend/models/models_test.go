@@ -0,0 +1,54 @@
+package models
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAllListsEveryModel fails when a struct with gorm columns is missing from All, since key
+// rotation would then leave its encrypted columns on a retired key.
+func TestAllListsEveryModel(t *testing.T) {
+	listed := map[string]bool{}
+	for _, model := range All() {
+		listed[reflect.TypeOf(model).Elem().Name()] = true
+	}
+
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			structType, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+			for _, field := range structType.Fields.List {
+				if field.Tag != nil && strings.Contains(field.Tag.Value, `gorm:"`) {
+					if !listed[spec.Name.Name] {
+						t.Errorf("model %s is not listed in All", spec.Name.Name)
+					}
+					break
+				}
+			}
+			return false
+		})
+	}
+}
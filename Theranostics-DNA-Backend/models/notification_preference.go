@@ -0,0 +1,17 @@
+// models/notification_preference.go
+package models
+
+import "time"
+
+// NotificationPreference records whether a customer or staff user wants an event delivered on a
+// channel. Without a row the event's default for the channel applies.
+type NotificationPreference struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RecipientType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_notification_preferences_key" json:"recipient_type"` // "customer" or "user"
+	RecipientID   uint      `gorm:"not null;uniqueIndex:idx_notification_preferences_key" json:"recipient_id"`                    // Customer or user ID
+	Event         string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_preferences_key" json:"event"`          // e.g. "order_confirmed"
+	Channel       string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_notification_preferences_key" json:"channel"`        // "email" or "sms"
+	Enabled       bool      `gorm:"not null" json:"enabled"`
+	CreatedAt     time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
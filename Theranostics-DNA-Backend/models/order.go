@@ -11,6 +11,9 @@ type Order struct {
 	ID                 uint           `gorm:"primaryKey;autoIncrement;not null" json:"id"`
 	CustomerID         uint           `gorm:"not null" json:"customer_id" validate:"required"`
 	Customer           Customer       `gorm:"foreignKey:CustomerID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"customer,omitempty"`
+	ProductOfferID     *uint          `gorm:"index" json:"product_offer_id,omitempty"`
+	ProductOffer       *ProductOffer  `gorm:"foreignKey:ProductOfferID;references:ID" json:"product_offer,omitempty"`
+	OfferUseCounted    bool           `gorm:"not null;default:false" json:"-"` // Whether the order holds one of its offer's uses
 	ProductName        string         `gorm:"type:varchar(100);not null" json:"product_name" validate:"required"`
 	ProductDescription string         `gorm:"type:text" json:"product_description"`
 	ProductImage       string         `gorm:"type:text" json:"product_image"`
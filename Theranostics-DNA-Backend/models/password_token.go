@@ -0,0 +1,18 @@
+// models/password_token.go
+package models
+
+import "time"
+
+// PasswordToken is a single-use token emailed to a user so they can set their own password,
+// either after a reset request or when invited as a new staff member. Only its hash is stored.
+type PasswordToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`                                                          // User the token belongs to
+	User      User       `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"` // User the token belongs to
+	TokenHash string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`                                         // SHA-256 of the token, never the token itself
+	Purpose   string     `gorm:"type:varchar(20);not null" json:"purpose"`                                               // "reset" or "invite"
+	ExpiresAt time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`                                              // Token expiry
+	UsedAt    *time.Time `gorm:"type:timestamp;null" json:"used_at,omitempty"`                                           // Set when the token is redeemed or superseded
+	CreatedBy *uint      `gorm:"null" json:"created_by,omitempty"`                                                       // Staff member who triggered the email, if not the user
+	CreatedAt time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`                             // Timestamp of creation
+}
@@ -0,0 +1,14 @@
+// models/permission.go
+package models
+
+import "time"
+
+// Permission is a named capability, such as "kits.read", that routes require and roles grant.
+type Permission struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key         string    `gorm:"type:varchar(50);unique;not null" json:"key"`                // Permission key checked by the routes
+	Description string    `gorm:"type:varchar(255)" json:"description"`                       // Human-readable description for the admin UI
+	Roles       []Role    `gorm:"many2many:role_permissions;" json:"-"`                       // Roles that grant this permission
+	CreatedAt   time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"` // Timestamp of creation
+	UpdatedAt   time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"` // Timestamp of last update
+}
@@ -0,0 +1,31 @@
+// models/product_offer.go
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductOffer records a signed product link minted by staff. The token handed to the
+// customer carries the nonce, which is used to enforce expiry, usage limits and revocation.
+type ProductOffer struct {
+	ID                 uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Nonce              string         `gorm:"type:varchar(64);not null;uniqueIndex" json:"nonce"`                                                                // Random token ID (jti claim)
+	ProductName        string         `gorm:"type:varchar(100);not null" json:"product_name" validate:"required"`                                                // Product name shown to the customer
+	ProductDescription string         `gorm:"type:text" json:"product_description"`                                                                              // Optional product description
+	ProductImage       string         `gorm:"type:text" json:"product_image"`                                                                                    // Optional base64 image or image URL
+	ProductPrice       float64        `gorm:"type:decimal(10,2);not null" json:"product_price" validate:"required,gt=0"`                                         // Unit price locked in by the offer
+	CustomerEmail      string         `gorm:"type:text;serializer:encrypted" json:"customer_email,omitempty"`                                                    // Optional email the offer is bound to
+	CustomerEmailHash  string         `gorm:"type:varchar(64)" json:"-"`                                                                                         // Keyed HMAC of CustomerEmail, used to match it since it is encrypted
+	MaxUses            int            `gorm:"not null;default:0" json:"max_uses"`                                                                                // Maximum number of orders (0 means unlimited)
+	UsedCount          int            `gorm:"not null;default:0" json:"used_count"`                                                                              // Number of paid orders placed with this offer
+	IssuedAt           time.Time      `gorm:"type:timestamp;not null" json:"issued_at"`                                                                          // When the token was minted
+	ExpiresAt          time.Time      `gorm:"type:timestamp;not null" json:"expires_at"`                                                                         // When the token stops being accepted
+	CreatedBy          uint           `gorm:"not null" json:"created_by"`                                                                                        // ID of the staff member who minted the offer
+	CreatedByUser      User           `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"created_by_user,omitempty"` // Staff member who minted the offer
+	IsDeleted          bool           `gorm:"default:false" json:"is_deleted"`                                                                                   // Soft delete flag, also used to revoke an offer
+	CreatedAt          time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,14 @@
+// models/recovery_code.go
+package models
+
+import "time"
+
+// RecoveryCode is a single-use code that can stand in for a TOTP code when the authenticator is lost.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`                                                          // Owner of the code
+	User      User       `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"` // Owner of the code
+	CodeHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`                                         // SHA-256 of the normalised code
+	UsedAt    *time.Time `gorm:"type:timestamp;null" json:"used_at,omitempty"`                                           // Set when the code is redeemed
+	CreatedAt time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`                             // Timestamp of creation
+}
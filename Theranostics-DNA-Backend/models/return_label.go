@@ -0,0 +1,15 @@
+// models/return_label.go
+package models
+
+import "time"
+
+// ReturnLabel is the label a customer puts on the parcel returning one kit's sample to the lab.
+// Its kit serial is printed as a barcode, so the lab can match the sample to the order on
+// arrival. The PDF is rendered from the order and customer whenever it is needed.
+type ReturnLabel struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID   uint      `gorm:"not null;uniqueIndex:idx_return_labels_order_unit" json:"order_id"`
+	Unit      int       `gorm:"not null;uniqueIndex:idx_return_labels_order_unit" json:"unit"` // Kit number within the order, from 1
+	KitSerial string    `gorm:"type:varchar(32);not null;uniqueIndex" json:"kit_serial"`       // Printed on the kit and encoded in the barcode
+	CreatedAt time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
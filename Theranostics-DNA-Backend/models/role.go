@@ -10,12 +10,15 @@ import (
 
 // Role model
 type Role struct {
-	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name      string         `gorm:"type:varchar(20);unique;not null" json:"name" validate:"required,max=20"` // Limit to 20 characters and required
-	Status    bool           `gorm:"default:true" json:"status"`
-	IsDeleted bool           `gorm:"default:false" json:"is_deleted"`
-	CreatedAt time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
-	Users     []User         `gorm:"foreignKey:RoleID" json:"users,omitempty"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name              string         `gorm:"type:varchar(20);unique;not null" json:"name" validate:"required,max=20"` // Limit to 20 characters and required
+	Status            bool           `gorm:"default:true" json:"status"`
+	TwoFactorRequired bool           `gorm:"default:false" json:"two_factor_required"` // Users with this role must enrol in 2FA
+	IsSystem          bool           `gorm:"default:false" json:"is_system"`           // Built-in roles cannot be renamed or deleted
+	Permissions       []Permission   `gorm:"many2many:role_permissions;" json:"permissions,omitempty"`
+	IsDeleted         bool           `gorm:"default:false" json:"is_deleted"`
+	CreatedAt         time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	Users             []User         `gorm:"foreignKey:RoleID" json:"users,omitempty"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -0,0 +1,39 @@
+// models/session.go
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session represents one signed-in device. Access tokens carry the session ID, so revoking
+// the session immediately invalidates every token issued for it.
+type Session struct {
+	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        uint           `gorm:"not null;index" json:"user_id"`                                                          // Owner of the session
+	User          User           `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"` // Owner of the session
+	DeviceName    string         `gorm:"type:varchar(100)" json:"device_name"`                                                   // Optional client-supplied device label
+	UserAgent     string         `gorm:"type:varchar(255)" json:"user_agent"`                                                    // User agent at login
+	IPAddress     string         `gorm:"type:varchar(45)" json:"ip_address"`                                                     // Client IP at the last login or refresh
+	LastUsedAt    time.Time      `gorm:"type:timestamp;not null" json:"last_used_at"`                                            // Last login or refresh
+	ExpiresAt     time.Time      `gorm:"type:timestamp;not null" json:"expires_at"`                                              // Absolute lifetime of the session
+	RevokedAt     *time.Time     `gorm:"type:timestamp;null" json:"revoked_at,omitempty"`                                        // Set when the session is logged out or revoked
+	RevokedReason string         `gorm:"type:varchar(50)" json:"revoked_reason,omitempty"`                                       // Why the session was revoked
+	RefreshTokens []RefreshToken `gorm:"foreignKey:SessionID" json:"-"`                                                          // Refresh tokens issued for this session
+	CreatedAt     time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`                             // Timestamp of creation
+	UpdatedAt     time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`                             // Timestamp of last update
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`                                                                         // Soft deletion, hidden in API responses
+}
+
+// RefreshToken stores the hash of a single-use refresh token. Presenting a token that has
+// already been rotated out is treated as theft and revokes the whole session.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SessionID uint       `gorm:"not null;index" json:"session_id"`                                                          // Session the token belongs to
+	Session   Session    `gorm:"foreignKey:SessionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"` // Session the token belongs to
+	TokenHash string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`                                            // SHA-256 of the token, never the token itself
+	ExpiresAt time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`                                                 // Token expiry
+	UsedAt    *time.Time `gorm:"type:timestamp;null" json:"used_at,omitempty"`                                              // Set when the token is rotated
+	CreatedAt time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`                                // Timestamp of creation
+}
@@ -0,0 +1,22 @@
+// models/shipment.go
+package models
+
+import "time"
+
+// Shipment is a parcel sent for an order: the kit going out to the customer, or the sample coming
+// back to the lab.
+type Shipment struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID        uint       `gorm:"not null;index" json:"order_id"`
+	Direction      string     `gorm:"type:varchar(20);not null" json:"direction"`                                             // "outbound" or "return"
+	Carrier        string     `gorm:"type:varchar(50);not null" json:"carrier"`                                               // Carrier code, e.g. "ups"
+	TrackingNumber string     `gorm:"type:varchar(100)" json:"tracking_number"`                                               // Empty until the label is printed
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_shipments_tracking" json:"status"` // "pending", "in_transit" or "delivered"
+	CarrierStatus  string     `gorm:"type:varchar(255)" json:"carrier_status,omitempty"`                                      // Latest description reported by the carrier
+	ShippedAt      *time.Time `gorm:"type:timestamp;null" json:"shipped_at,omitempty"`                                        // When the parcel was handed to the carrier
+	DeliveredAt    *time.Time `gorm:"type:timestamp;null" json:"delivered_at,omitempty"`                                      // When the carrier delivered it
+	LastCheckedAt  *time.Time `gorm:"type:timestamp;null;index:idx_shipments_tracking" json:"last_checked_at,omitempty"`      // When the carrier was last polled
+	CreatedBy      uint       `gorm:"not null" json:"created_by"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
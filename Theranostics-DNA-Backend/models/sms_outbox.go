@@ -0,0 +1,24 @@
+// models/sms_outbox.go
+package models
+
+import "time"
+
+// SMSOutbox is a text message queued in the same transaction as the change it reports and sent
+// afterwards by the outbox worker. The phone number, body and last error are encrypted at rest.
+type SMSOutbox struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	PhoneNumber   string     `gorm:"type:text;not null;serializer:encrypted" json:"-"`                                   // Recipient phone number
+	Body          string     `gorm:"type:text;not null;serializer:encrypted" json:"-"`                                   // Message text
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_sms_outbox_due" json:"status"` // "pending", "sent" or "dead"
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`                                                 // Send attempts so far
+	NextAttemptAt time.Time  `gorm:"type:timestamp;not null;index:idx_sms_outbox_due" json:"next_attempt_at"`            // When the worker may next try to send it
+	LastError     string     `gorm:"type:text;serializer:encrypted" json:"last_error,omitempty"`                         // Error from the latest failed attempt
+	SentAt        *time.Time `gorm:"type:timestamp;null" json:"sent_at,omitempty"`                                       // Set once the provider accepts the message
+	CreatedAt     time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP;index" json:"created_at"`                   // Timestamp of creation
+	UpdatedAt     time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`                         // Timestamp of last update
+}
+
+// TableName keeps the table name singular, as an outbox is one queue.
+func (SMSOutbox) TableName() string {
+	return "sms_outbox"
+}
@@ -16,11 +16,18 @@ type User struct {
 	HashPassword string         `gorm:"type:varchar(255);null" json:"hash_password" validate:"required,max=255"`          // Password hash is required and max 255 characters
 	RoleID       uint           `gorm:"not null" json:"role_id" validate:"required"`                                      // Role ID is required
 	Role         Role           `gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"role,omitempty"`
-	Token        string         `gorm:"type:text;null" json:"token"`                                // Token is optional and can be text
+	Language     string         `gorm:"type:varchar(10);not null;default:'en'" json:"language"`     // Locale of the emails sent to the user
 	ActiveStatus bool           `gorm:"default:true" json:"active_status" validate:"required"`      // Active status is required (default is true)
 	IsDeleted    bool           `gorm:"default:false" json:"is_deleted"`                            // Soft delete flag (default is false)
 	CreatedAt    time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"` // Timestamp of creation
 	UpdatedAt    time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"` // Timestamp of last update
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`                                             // Soft deletion, hidden in API responses
 	Kits         []Kit          `gorm:"foreignKey:CreatedBy" json:"kits,omitempty"`                 // Relationship with Kits (optional, as user may not have kits)
+	Sessions     []Session      `gorm:"foreignKey:UserID" json:"-"`                                 // Signed-in devices
+
+	TwoFactorEnabled     bool           `gorm:"default:false" json:"two_factor_enabled"`                      // Set once the user has confirmed a TOTP code
+	TwoFactorSecret      string         `gorm:"type:text;serializer:encrypted" json:"-"`                      // Encrypted TOTP secret, pending until TwoFactorEnabled
+	TwoFactorLastStep    int64          `gorm:"default:0" json:"-"`                                           // Last accepted TOTP time step, prevents code replay
+	TwoFactorConfirmedAt *time.Time     `gorm:"type:timestamp;null" json:"two_factor_confirmed_at,omitempty"` // When 2FA was enabled
+	RecoveryCodes        []RecoveryCode `gorm:"foreignKey:UserID" json:"-"`                                   // Single-use 2FA recovery codes
 }
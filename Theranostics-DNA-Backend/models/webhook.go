@@ -0,0 +1,39 @@
+// models/webhook.go
+package models
+
+import "time"
+
+// WebhookSubscription is a partner endpoint that receives signed JSON deliveries for the events it
+// subscribes to. The secret signs every delivery and is encrypted at rest.
+type WebhookSubscription struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	URL         string    `gorm:"type:text;not null" json:"url"`
+	Secret      string    `gorm:"type:text;not null;serializer:encrypted" json:"-"`
+	Events      string    `gorm:"type:text;not null" json:"-"` // Comma-separated event types
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	IsDeleted   bool      `gorm:"default:false" json:"is_deleted"`
+	CreatedBy   uint      `gorm:"not null" json:"created_by"`
+	CreatedAt   time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent to one subscription. Deliveries are queued in the transaction
+// that makes the change and sent by the outbox worker, which keeps retrying until the endpoint
+// answers with a 2xx status. Payloads carry IDs and statuses, never customer PII.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        string     `gorm:"type:varchar(64);not null;index" json:"event_id"`                                            // Shared by every delivery of the event, so receivers can deduplicate
+	Event          string     `gorm:"type:varchar(50);not null" json:"event"`                                                     // e.g. "order.created"
+	Payload        string     `gorm:"type:jsonb;not null" json:"-"`                                                               // Signed request body
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_webhook_deliveries_due" json:"status"` // "pending", "sent" or "dead"
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`                                                         // Send attempts so far
+	NextAttemptAt  time.Time  `gorm:"type:timestamp;not null;index:idx_webhook_deliveries_due" json:"next_attempt_at"`            // When the worker may next try to send it
+	ResponseStatus int        `gorm:"not null;default:0" json:"response_status"`                                                  // HTTP status of the latest attempt, 0 when no response arrived
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`                                                      // Error from the latest failed attempt
+	SentAt         *time.Time `gorm:"type:timestamp;null" json:"sent_at,omitempty"`                                               // Set once the endpoint accepts the delivery
+	ReplayOfID     *uint      `gorm:"null" json:"replay_of_id,omitempty"`                                                         // The delivery this one replays
+	CreatedAt      time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
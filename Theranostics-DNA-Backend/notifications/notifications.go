@@ -0,0 +1,261 @@
+// notifications/notifications.go
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/emails"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events a recipient can be notified of.
+const (
+	EventOrderConfirmed       = "order_confirmed"
+	EventKitShipped           = "kit_shipped"
+	EventSampleReceived       = "sample_received"
+	EventAccountStatusChanged = "account_status_changed"
+)
+
+// Delivery channels.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Recipient types. Customers can be reached by email and SMS; staff users only have an email.
+const (
+	RecipientCustomer = "customer"
+	RecipientUser     = "user"
+)
+
+var (
+	// ErrUnknownEvent is returned for events that don't exist or don't apply to the recipient.
+	ErrUnknownEvent = errors.New("unknown notification event")
+	// ErrUnsupportedChannel is returned for channels the recipient can't be reached on.
+	ErrUnsupportedChannel = errors.New("unsupported notification channel")
+)
+
+// eventSpec describes who receives an event and the email template it is rendered with.
+type eventSpec struct {
+	recipient string
+	template  string
+}
+
+var events = map[string]eventSpec{
+	EventOrderConfirmed:       {RecipientCustomer, emails.TemplateOrderConfirmation},
+	EventKitShipped:           {RecipientCustomer, emails.TemplateKitShipped},
+	EventSampleReceived:       {RecipientCustomer, emails.TemplateSampleReceived},
+	EventAccountStatusChanged: {RecipientUser, emails.TemplateUserStatusChanged},
+}
+
+var channels = map[string][]string{
+	RecipientCustomer: {ChannelEmail, ChannelSMS},
+	RecipientUser:     {ChannelEmail},
+}
+
+// defaultEnabled applies when a recipient has no preference for an event and channel. Email is on
+// by default; SMS needs the recipient to opt in.
+var defaultEnabled = map[string]bool{
+	ChannelEmail: true,
+	ChannelSMS:   false,
+}
+
+// Recipient is who a notification is delivered to. Channels whose address is empty are skipped.
+type Recipient struct {
+	Type        string
+	ID          uint
+	Email       string
+	PhoneNumber string
+	Language    string
+}
+
+// Customer returns the recipient for a customer. An erased customer has no addresses left, so
+// nothing is delivered to them.
+func Customer(customer *models.Customer) Recipient {
+	recipient := Recipient{Type: RecipientCustomer, ID: customer.ID, Language: customer.Language}
+	if customer.ErasedAt == nil {
+		recipient.Email = customer.Email
+		recipient.PhoneNumber = customer.PhoneNumber
+	}
+	return recipient
+}
+
+// User returns the recipient for a staff user.
+func User(user *models.User) Recipient {
+	return Recipient{Type: RecipientUser, ID: user.ID, Email: user.Email, Language: user.Language}
+}
+
+// Notification is an event to deliver to one recipient. Data is the template data of the event's
+// email template, such as emails.OrderConfirmationData, and is also used for the SMS text.
+// Attachments are only sent by email.
+type Notification struct {
+	Event       string
+	Recipient   Recipient
+	Data        interface{}
+	Attachments []models.OutboxAttachment
+}
+
+// Dispatch queues the notification on every channel the recipient has enabled for the event. Call
+// it with the transaction that makes the change the notification reports.
+func Dispatch(tx *gorm.DB, n Notification) error {
+	spec, ok := events[n.Event]
+	if !ok || spec.recipient != n.Recipient.Type {
+		return fmt.Errorf("%w: %s for %s", ErrUnknownEvent, n.Event, n.Recipient.Type)
+	}
+
+	enabled, err := enabledChannels(tx, n.Recipient, n.Event)
+	if err != nil {
+		return err
+	}
+
+	if enabled[ChannelEmail] && n.Recipient.Email != "" {
+		email, err := emails.Render(spec.template, n.Recipient.Language, n.Data)
+		if err != nil {
+			return err
+		}
+		if err := utils.QueueEmail(tx, []string{n.Recipient.Email}, email.Subject, email.HTML, n.Attachments...); err != nil {
+			return err
+		}
+	}
+
+	// Nothing is queued while SMS is disabled, so messages don't pile up undeliverable
+	if enabled[ChannelSMS] && n.Recipient.PhoneNumber != "" && config.SMSEnabled() {
+		body, err := renderSMS(n.Event, n.Recipient.Language, n.Data)
+		if err != nil {
+			return err
+		}
+		if err := utils.QueueSMS(tx, n.Recipient.PhoneNumber, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func enabledChannels(db *gorm.DB, recipient Recipient, event string) (map[string]bool, error) {
+	enabled := map[string]bool{}
+	for _, channel := range channels[recipient.Type] {
+		enabled[channel] = defaultEnabled[channel]
+	}
+
+	var stored []models.NotificationPreference
+	if err := db.Where("recipient_type = ? AND recipient_id = ? AND event = ?", recipient.Type, recipient.ID, event).
+		Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, preference := range stored {
+		if _, ok := enabled[preference.Channel]; ok {
+			enabled[preference.Channel] = preference.Enabled
+		}
+	}
+	return enabled, nil
+}
+
+// Preference is whether an event is delivered on a channel.
+type Preference struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Preferences returns every event and channel that applies to the recipient, with stored choices
+// applied over the defaults, sorted by event and channel.
+func Preferences(db *gorm.DB, recipientType string, recipientID uint) ([]Preference, error) {
+	var stored []models.NotificationPreference
+	if err := db.Where("recipient_type = ? AND recipient_id = ?", recipientType, recipientID).
+		Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	choices := map[string]bool{}
+	for _, preference := range stored {
+		choices[preference.Event+"."+preference.Channel] = preference.Enabled
+	}
+
+	var preferences []Preference
+	for event, spec := range events {
+		if spec.recipient != recipientType {
+			continue
+		}
+		for _, channel := range channels[recipientType] {
+			enabled, ok := choices[event+"."+channel]
+			if !ok {
+				enabled = defaultEnabled[channel]
+			}
+			preferences = append(preferences, Preference{Event: event, Channel: channel, Enabled: enabled})
+		}
+	}
+	sort.Slice(preferences, func(i, j int) bool {
+		if preferences[i].Event != preferences[j].Event {
+			return preferences[i].Event < preferences[j].Event
+		}
+		return preferences[i].Channel < preferences[j].Channel
+	})
+	return preferences, nil
+}
+
+// Validate checks that the preference's event and channel apply to the recipient type.
+func (p Preference) Validate(recipientType string) error {
+	if spec, ok := events[p.Event]; !ok || spec.recipient != recipientType {
+		return fmt.Errorf("%w: %s", ErrUnknownEvent, p.Event)
+	}
+	for _, channel := range channels[recipientType] {
+		if channel == p.Channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedChannel, p.Channel)
+}
+
+// SetPreferences stores the given choices for the recipient, leaving other events and channels
+// as they are.
+func SetPreferences(tx *gorm.DB, recipientType string, recipientID uint, preferences []Preference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+
+	// One row per event and channel, the last choice winning, as an upsert can't touch a row twice
+	rows := make([]models.NotificationPreference, 0, len(preferences))
+	index := map[string]int{}
+	for _, preference := range preferences {
+		if err := preference.Validate(recipientType); err != nil {
+			return err
+		}
+		key := preference.Event + "." + preference.Channel
+		if i, ok := index[key]; ok {
+			rows[i].Enabled = preference.Enabled
+			continue
+		}
+		index[key] = len(rows)
+		rows = append(rows, models.NotificationPreference{
+			RecipientType: recipientType,
+			RecipientID:   recipientID,
+			Event:         preference.Event,
+			Channel:       preference.Channel,
+			Enabled:       preference.Enabled,
+		})
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "recipient_type"}, {Name: "recipient_id"}, {Name: "event"}, {Name: "channel"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"enabled": gorm.Expr("excluded.enabled"), "updated_at": gorm.Expr("CURRENT_TIMESTAMP")}),
+	}).Create(&rows).Error
+}
+
+// ChannelPreferences returns a preference for every event of the recipient type on one channel,
+// e.g. to opt a customer in to SMS for all their order updates.
+func ChannelPreferences(recipientType, channel string, enabled bool) []Preference {
+	var preferences []Preference
+	for event, spec := range events {
+		if spec.recipient == recipientType {
+			preferences = append(preferences, Preference{Event: event, Channel: channel, Enabled: enabled})
+		}
+	}
+	return preferences
+}
@@ -0,0 +1,59 @@
+// notifications/sms.go
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"theransticslabs/m/emails"
+)
+
+// smsTexts holds the text message of each customer event by locale. Messages are plain text and
+// kept short enough for a single SMS where the data allows.
+var smsTexts = map[string]map[string]string{
+	"en": {
+		EventOrderConfirmed: `Theranostics: Hi {{.FirstName}}, your order for {{.Quantity}} x {{.ProductName}} is confirmed. ` +
+			`Your invoice has been emailed to you.`,
+		EventKitShipped: `Theranostics: Your {{.ProductName}} has shipped with {{.Carrier}}, tracking number {{.TrackingNumber}}.` +
+			`{{with .TrackingURL}} Track it at {{.}}{{end}}`,
+		EventSampleReceived: `Theranostics: We have received your sample for the {{.ProductName}}. ` +
+			`Our team will be in touch when your results are ready.`,
+	},
+	"es": {
+		EventOrderConfirmed: `Theranostics: Hola {{.FirstName}}, tu pedido de {{.Quantity}} x {{.ProductName}} está confirmado. ` +
+			`Te hemos enviado la factura por correo.`,
+		EventKitShipped: `Theranostics: Tu {{.ProductName}} se ha enviado con {{.Carrier}}, número de seguimiento {{.TrackingNumber}}.` +
+			`{{with .TrackingURL}} Síguelo en {{.}}{{end}}`,
+		EventSampleReceived: `Theranostics: Hemos recibido tu muestra para el {{.ProductName}}. ` +
+			`Nuestro equipo se pondrá en contacto contigo cuando tus resultados estén listos.`,
+	},
+}
+
+var smsTemplates = map[string]map[string]*template.Template{}
+
+func init() {
+	for locale, texts := range smsTexts {
+		smsTemplates[locale] = map[string]*template.Template{}
+		for event, text := range texts {
+			smsTemplates[locale][event] = template.Must(template.New(event).Option("missingkey=error").Parse(text))
+		}
+	}
+}
+
+// renderSMS renders the text message of an event, falling back to the default locale.
+func renderSMS(event, locale string, data interface{}) (string, error) {
+	t, ok := smsTemplates[locale][event]
+	if !ok {
+		t, ok = smsTemplates[emails.DefaultLocale][event]
+	}
+	if !ok {
+		return "", fmt.Errorf("no text message for notification event %q", event)
+	}
+
+	var body strings.Builder
+	if err := t.Execute(&body, data); err != nil {
+		return "", fmt.Errorf("failed to render %s text message: %w", event, err)
+	}
+	return body.String(), nil
+}
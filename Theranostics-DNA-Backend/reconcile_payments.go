@@ -0,0 +1,229 @@
+// reconcile_payments.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/controllers"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+)
+
+// PayPal order statuses that decide how a pending payment is settled.
+const (
+	paypalOrderCompleted = "COMPLETED"
+	paypalOrderApproved  = "APPROVED"
+	paypalOrderVoided    = "VOIDED"
+)
+
+// Reconciliation outcomes.
+const (
+	reconcilePaid      = "paid"
+	reconcileCaptured  = "captured"
+	reconcileFailed    = "failed"
+	reconcileUnchanged = "unchanged"
+)
+
+// runReconcilePayments settles payments left pending, typically because the buyer never came
+// back from PayPal or the return request failed after the capture. Each payment is compared with
+// its PayPal order: captured orders are completed, approved orders are captured and completed,
+// and voided, expired or long abandoned orders, or ones never sent to PayPal, are marked as
+// failed.
+func runReconcilePayments(args []string) int {
+	flags := flag.NewFlagSet("reconcile-payments", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", 30*time.Minute, "only check payments pending for at least this long")
+	abandonAfter := flags.Duration("abandon-after", 24*time.Hour, "fail payments the buyer never approved after this long")
+	dryRun := flags.Bool("dry-run", false, "report what would change without changing anything")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config.InitDB()
+
+	var payments []models.Payment
+	if err := config.DB.
+		Where("payment_status = ? AND created_at < ?", utils.PaymentStatusPending, time.Now().Add(-*olderThan)).
+		Order("id").
+		Find(&payments).Error; err != nil {
+		slog.Error("Failed to load pending payments", "error", err)
+		return 1
+	}
+	if len(payments) == 0 {
+		slog.Info("No pending payments to reconcile")
+		return 0
+	}
+
+	accessToken, err := utils.GetPayPalAccessToken()
+	if err != nil {
+		slog.Error("Failed to get PayPal access token", "error", err)
+		return 1
+	}
+
+	counts := map[string]int{}
+	failures := 0
+	for _, payment := range payments {
+		// A payment without a PayPal order is one whose order was committed but never sent to
+		// PayPal, so the buyer had nothing to approve; it is only failed once abandoned
+		var (
+			order utils.PayPalOrderResponse
+			err   error
+		)
+		if payment.TransactionID != "" {
+			order, err = utils.GetPayPalOrder(payment.TransactionID, accessToken)
+		}
+		if err != nil && !errors.Is(err, utils.ErrPayPalOrderNotFound) {
+			slog.Error("Failed to get PayPal order", "payment_id", payment.ID, "error", err)
+			failures++
+			continue
+		}
+
+		outcome := reconcileUnchanged
+		switch {
+		case errors.Is(err, utils.ErrPayPalOrderNotFound), order.Status == paypalOrderVoided:
+			outcome = reconcileFailed
+		case order.Status == paypalOrderCompleted:
+			outcome = reconcilePaid
+		case order.Status == paypalOrderApproved:
+			outcome = reconcileCaptured
+		case time.Since(payment.CreatedAt) > *abandonAfter:
+			outcome = reconcileFailed
+		}
+
+		if outcome == reconcileUnchanged || *dryRun {
+			slog.Info("Payment reconciled", "payment_id", payment.ID, "paypal_status", order.Status, "outcome", outcome, "dry_run", *dryRun)
+			counts[outcome]++
+			continue
+		}
+
+		settled, err := settlePayment(payment.ID, outcome, accessToken)
+		if err != nil {
+			slog.Error("Failed to settle payment", "payment_id", payment.ID, "outcome", outcome, "error", err)
+			failures++
+			continue
+		}
+		if !settled {
+			outcome = reconcileUnchanged
+		}
+		slog.Info("Payment reconciled", "payment_id", payment.ID, "paypal_status", order.Status, "outcome", outcome)
+		counts[outcome]++
+	}
+
+	slog.Info("Reconciliation finished",
+		"checked", len(payments),
+		reconcilePaid, counts[reconcilePaid],
+		reconcileCaptured, counts[reconcileCaptured],
+		reconcileFailed, counts[reconcileFailed],
+		reconcileUnchanged, counts[reconcileUnchanged],
+		"errors", failures,
+		"dry_run", *dryRun,
+	)
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// settlePayment applies a reconciliation outcome to a payment that is still pending. It reports
+// false when the payment had already been settled by the time it was locked.
+func settlePayment(paymentID uint, outcome string, accessToken string) (bool, error) {
+	switch outcome {
+	case reconcileFailed:
+		return updatePendingPayment(paymentID, func(tx *gorm.DB, payment *models.Payment) error {
+			if err := controllers.FailPayment(tx, payment); err != nil {
+				return err
+			}
+			return recordPaymentAudit(tx, payment, utils.PaymentStatusFailed)
+		})
+	case reconcileCaptured:
+		return capturePendingPayment(paymentID, accessToken)
+	default:
+		return completePendingPayment(paymentID)
+	}
+}
+
+// updatePendingPayment locks the payment and applies update to it if it is still pending, which
+// it reports.
+func updatePendingPayment(paymentID uint, update func(tx *gorm.DB, payment *models.Payment) error) (bool, error) {
+	var pending bool
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		payment, err := controllers.LockPayment(tx, paymentID)
+		if err != nil {
+			return err
+		}
+		// The buyer's return request may have settled it in the meantime
+		if payment.PaymentStatus != utils.PaymentStatusPending {
+			return nil
+		}
+		pending = true
+		return update(tx, payment)
+	})
+	return pending && err == nil, err
+}
+
+// completePendingPayment completes a payment PayPal has captured.
+func completePendingPayment(paymentID uint) (bool, error) {
+	var invoice *models.Invoice
+	settled, err := updatePendingPayment(paymentID, func(tx *gorm.DB, payment *models.Payment) error {
+		var err error
+		if invoice, err = controllers.CompletePayment(tx, payment); err != nil {
+			return err
+		}
+		return recordPaymentAudit(tx, payment, utils.PaymentStatusCompleted)
+	})
+	if err != nil && invoice != nil {
+		utils.RemoveInvoicePDF(invoice)
+	}
+	return settled, err
+}
+
+// capturePendingPayment captures a payment the buyer approved and completes it. A use of the
+// order's offer is reserved and committed first, so no row stays locked while PayPal is called.
+func capturePendingPayment(paymentID uint, accessToken string) (bool, error) {
+	var (
+		reserved      bool
+		transactionID string
+	)
+	settled, err := updatePendingPayment(paymentID, func(tx *gorm.DB, payment *models.Payment) error {
+		var err error
+		if reserved, err = controllers.ReserveOfferUse(tx, payment); err != nil {
+			return err
+		}
+		// Don't take money for an order whose offer ran out while it waited
+		if !reserved {
+			if err := controllers.FailPayment(tx, payment); err != nil {
+				return err
+			}
+			return recordPaymentAudit(tx, payment, utils.PaymentStatusFailed)
+		}
+		transactionID = payment.TransactionID
+		return nil
+	})
+	if err != nil || !settled || !reserved {
+		return settled, err
+	}
+
+	if err := utils.CapturePayPalPayment(transactionID, accessToken); err != nil {
+		if releaseErr := controllers.ReleaseOfferUse(config.DB, paymentID); releaseErr != nil {
+			slog.Error("Failed to release a product offer use", "payment_id", paymentID, "error", releaseErr)
+		}
+		return false, err
+	}
+	return completePendingPayment(paymentID)
+}
+
+// recordPaymentAudit records a status change made by reconciliation.
+func recordPaymentAudit(tx *gorm.DB, payment *models.Payment, status string) error {
+	return utils.RecordAudit(tx, nil, nil, utils.AuditEntry{
+		Action:     utils.AuditPaymentReconcile,
+		EntityType: utils.AuditEntityPayment,
+		EntityID:   payment.ID,
+		Before:     map[string]interface{}{"payment_status": utils.PaymentStatusPending},
+		After:      map[string]interface{}{"payment_status": status},
+	})
+}
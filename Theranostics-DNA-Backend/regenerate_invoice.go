@@ -0,0 +1,44 @@
+// regenerate_invoice.go
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+)
+
+// runRegenerateInvoice rebuilds the PDF of an invoice from its stored snapshot, e.g. after the
+// file was lost. The invoice itself is never changed.
+func runRegenerateInvoice(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "regenerate-invoice needs the invoice ID")
+		return 2
+	}
+	invoiceID, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invoice ID must be a number")
+		return 2
+	}
+
+	config.InitDB()
+
+	var invoice models.Invoice
+	if err := config.DB.Unscoped().First(&invoice, invoiceID).Error; err != nil {
+		slog.Error("Invoice not found", "invoice_id", invoiceID, "error", err)
+		return 1
+	}
+
+	invoicePath, err := utils.SaveInvoicePDF(&invoice)
+	if err != nil {
+		slog.Error("Failed to generate invoice", "invoice_id", invoice.ID, "error", err)
+		return 1
+	}
+
+	slog.Info("Invoice regenerated", "invoice_id", invoice.ID, "path", invoicePath)
+	return 0
+}
@@ -0,0 +1,35 @@
+// rotate_keys.go
+package main
+
+import (
+	"flag"
+	"log/slog"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/utils"
+)
+
+// runRotateKeys re-encrypts every column stored with the encrypted serializer, in every model's
+// table, whose value isn't on the active encryption key. Run it after switching
+// ENCRYPTION_ACTIVE_KEY_ID, before removing old keys from the keyring.
+func runRotateKeys(args []string) int {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	batchSize := flags.Int("batch-size", 100, "rows loaded per query")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config.InitDB()
+
+	counts, err := utils.ReencryptAll(config.DB, *batchSize)
+	for table, count := range counts {
+		slog.Info("Re-encrypted table", "table", table, "count", count)
+	}
+	if err != nil {
+		slog.Error("Re-encryption stopped", "error", err)
+		return 1
+	}
+
+	slog.Info("Re-encryption completed", "key_id", utils.ActiveKeyID())
+	return 0
+}
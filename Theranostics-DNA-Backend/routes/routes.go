@@ -14,44 +14,108 @@ import (
 func SetupRoutes() *mux.Router {
 	router := mux.NewRouter()
 
-	// Apply the Logging Middleware to all routes
-	router.Use(middlewares.LoggingMiddleware)
+	// Apply the Logging and Metrics Middlewares to all routes
+	router.Use(middlewares.LoggingMiddleware, middlewares.MetricsMiddleware)
 
 	// Define Routes
 	router.HandleFunc(utils.RouteWelcome, controllers.WelcomeHandler).Methods("GET")
 	router.HandleFunc(utils.RouteLogin, controllers.LoginHandler).Methods("POST")                   // Login Route
+	router.HandleFunc(utils.RouteRefreshToken, controllers.RefreshTokenHandler).Methods("POST")     // Token Refresh Route
+	router.HandleFunc(utils.RouteTwoFactorLogin, controllers.TwoFactorLoginHandler).Methods("POST") // Two-Factor Login Route
 	router.HandleFunc(utils.RouteForgetPassword, controllers.ForgetPasswordHandler).Methods("POST") // Login Route
-	router.HandleFunc(utils.RouteEncryptProductDetails, controllers.EncryptProductDetails).Methods("POST")
+	router.HandleFunc(utils.RouteSetPassword, controllers.SetPasswordHandler).Methods("POST")       // Reset/Invite Completion Route
 	router.HandleFunc(utils.RouteVerifyProductDetails, controllers.VerifyProduct).Methods("POST")
 	router.HandleFunc(utils.RouteProductPaymentDetails, controllers.OrderCreateHandler).Methods("POST")
 	router.HandleFunc(utils.RoutePaymentSuccessPaypal, controllers.HandlePaymentSuccess).Methods("GET")
+	router.HandleFunc(utils.RouteMetrics, controllers.MetricsHandler).Methods("GET")
+	router.HandleFunc(utils.RouteHealthz, controllers.HealthzHandler).Methods("GET")
+	router.HandleFunc(utils.RouteReadyz, controllers.ReadyzHandler).Methods("GET")
 
 	// Protected Routes
 	protected := router.PathPrefix("/api").Subrouter()
 	protected.Use(middlewares.AuthMiddleware)
-	protected.Use(middlewares.CreatePermissionMiddleware())
-	// Add protected routes here
-	// e.g., protected.HandleFunc("/users", controllers.GetUsers).Methods("GET")
+
+	// Account routes, available to every signed-in user
 	protected.HandleFunc(utils.RouteResetPassword, controllers.ResetPasswordHandler).Methods("PATCH")
 	protected.HandleFunc(utils.RouteUpdateUser, controllers.UpdateUserInfoHandler).Methods("PATCH")
 	// Private User Profile Route
 	protected.HandleFunc(utils.RouteGetUserProfile, controllers.GetUserProfileHandler).Methods("GET")
-	// Admin Users List Route
-	protected.HandleFunc(utils.RouteGetAdminUserList, controllers.GetAdminUsersHandler).Methods("GET")
-	protected.HandleFunc(utils.RouteCreateAdminUser, controllers.CreateUserHandler).Methods("POST")
-	protected.HandleFunc(utils.RouteUpdateAdminUserProfile, controllers.UpdateUserProfileHandler).Methods("PATCH")
-	protected.HandleFunc(utils.RouteUpdateAdminUserStatus, controllers.UpdateUserStatusHandler).Methods("PATCH")
-	protected.HandleFunc(utils.RouteUpdateAdminUserPassword, controllers.UpdateUserPasswordHandler).Methods("PATCH")
-
-	protected.HandleFunc(utils.RouteDeleteAdminUser, controllers.DeleteUserHandler).Methods("DELETE")
-	protected.HandleFunc(utils.RouteKitInfo, controllers.CreateKitHandler).Methods("POST")
-	protected.HandleFunc(utils.RouteKitInfo, controllers.GetKitsListHandler).Methods("GET")
-	protected.HandleFunc(utils.RouteKitInfoID, controllers.UpdateKitHandler).Methods("PATCH")
-	protected.HandleFunc(utils.RouteKitInfoID, controllers.DeleteKitHandler).Methods("DELETE")
 	protected.HandleFunc(utils.RouteLogout, controllers.LogoutHandler).Methods("DELETE")
+	protected.HandleFunc(utils.RouteUserSessions, controllers.GetUserSessionsHandler).Methods("GET")
+	protected.HandleFunc(utils.RouteUserSessions, controllers.RevokeOtherSessionsHandler).Methods("DELETE")
+	protected.HandleFunc(utils.RouteUserSessionID, controllers.RevokeUserSessionHandler).Methods("DELETE")
+	protected.HandleFunc(utils.RouteUserNotifications, controllers.GetUserNotificationPreferencesHandler).Methods("GET")
+	protected.HandleFunc(utils.RouteUserNotifications, controllers.UpdateUserNotificationPreferencesHandler).Methods("PATCH")
+	protected.HandleFunc(utils.RouteTwoFactorSetup, controllers.SetupTwoFactorHandler).Methods("POST")
+	protected.HandleFunc(utils.RouteTwoFactorEnable, controllers.EnableTwoFactorHandler).Methods("POST")
+	protected.HandleFunc(utils.RouteTwoFactorRecoveryCodes, controllers.RegenerateRecoveryCodesHandler).Methods("POST")
+	protected.HandleFunc(utils.RouteTwoFactor, controllers.DisableTwoFactorHandler).Methods("DELETE")
+
+	// Admin Users Routes
+	protected.Handle(utils.RouteGetAdminUserList, middlewares.RequirePermission(utils.PermStaffRead, controllers.GetAdminUsersHandler)).Methods("GET")
+	protected.Handle(utils.RouteCreateAdminUser, middlewares.RequirePermission(utils.PermStaffCreate, controllers.CreateUserHandler)).Methods("POST")
+	protected.Handle(utils.RouteUpdateAdminUserProfile, middlewares.RequirePermission(utils.PermStaffUpdate, controllers.UpdateUserProfileHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteUpdateAdminUserStatus, middlewares.RequirePermission(utils.PermStaffUpdate, controllers.UpdateUserStatusHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteUpdateAdminUserPassword, middlewares.RequirePermission(utils.PermStaffUpdate, controllers.UpdateUserPasswordHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteUpdateAdminUserRole, middlewares.RequirePermission(utils.PermStaffAssignRole, controllers.UpdateUserRoleHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteDeleteAdminUser, middlewares.RequirePermission(utils.PermStaffDelete, controllers.DeleteUserHandler)).Methods("DELETE")
+	protected.Handle(utils.RouteUnlockAdminUser, middlewares.RequirePermission(utils.PermStaffSecurity, controllers.UnlockUserHandler)).Methods("POST")
+	protected.Handle(utils.RouteResetStaffTwoFactor, middlewares.RequirePermission(utils.PermStaffSecurity, controllers.ResetUserTwoFactorHandler)).Methods("DELETE")
+
+	// Role and Permission Routes
+	protected.Handle(utils.RoutePermissions, middlewares.RequirePermission(utils.PermRolesRead, controllers.GetPermissionsHandler)).Methods("GET")
+	protected.Handle(utils.RouteRoles, middlewares.RequirePermission(utils.PermRolesRead, controllers.GetRolesHandler)).Methods("GET")
+	protected.Handle(utils.RouteRoles, middlewares.RequirePermission(utils.PermRolesManage, controllers.CreateRoleHandler)).Methods("POST")
+	protected.Handle(utils.RouteRoleID, middlewares.RequirePermission(utils.PermRolesManage, controllers.UpdateRoleHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteRoleID, middlewares.RequirePermission(utils.PermRolesManage, controllers.DeleteRoleHandler)).Methods("DELETE")
+	protected.Handle(utils.RouteRolePermissions, middlewares.RequirePermission(utils.PermRolesManage, controllers.SetRolePermissionsHandler)).Methods("PUT")
+	protected.Handle(utils.RouteRoleTwoFactor, middlewares.RequirePermission(utils.PermRolesManage, controllers.UpdateRoleTwoFactorHandler)).Methods("PATCH")
+
+	// Kit Routes
+	protected.Handle(utils.RouteKitInfo, middlewares.RequirePermission(utils.PermKitsWrite, controllers.CreateKitHandler)).Methods("POST")
+	protected.Handle(utils.RouteKitInfo, middlewares.RequirePermission(utils.PermKitsRead, controllers.GetKitsListHandler)).Methods("GET")
+	protected.Handle(utils.RouteKitInfoID, middlewares.RequirePermission(utils.PermKitsWrite, controllers.UpdateKitHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteKitInfoID, middlewares.RequirePermission(utils.PermKitsWrite, controllers.DeleteKitHandler)).Methods("DELETE")
+
+	// Product Offer and Customer Routes
+	protected.Handle(utils.RouteEncryptProductDetails, middlewares.RequirePermission(utils.PermProductOfferCreate, controllers.EncryptProductDetails)).Methods("POST")
+	protected.Handle(utils.RouteCustomerDataExport, middlewares.RequirePermission(utils.PermCustomersExport, controllers.ExportCustomerDataHandler)).Methods("GET")
+	protected.Handle(utils.RouteCustomerErase, middlewares.RequirePermission(utils.PermCustomersErase, controllers.EraseCustomerDataHandler)).Methods("POST")
+	protected.Handle(utils.RouteCustomerNotifications, middlewares.RequirePermission(utils.PermCustomersNotify, controllers.GetCustomerNotificationPreferencesHandler)).Methods("GET")
+	protected.Handle(utils.RouteCustomerNotifications, middlewares.RequirePermission(utils.PermCustomersNotify, controllers.UpdateCustomerNotificationPreferencesHandler)).Methods("PATCH")
+
+	// Audit Log Routes
+	protected.Handle(utils.RouteAuditLogs, middlewares.RequirePermission(utils.PermAuditRead, controllers.GetAuditLogsHandler)).Methods("GET")
+	protected.Handle(utils.RouteAuditLogsExport, middlewares.RequirePermission(utils.PermAuditRead, controllers.ExportAuditLogsHandler)).Methods("GET")
+
+	// Email Outbox Routes
+	protected.Handle(utils.RouteEmailOutbox, middlewares.RequirePermission(utils.PermEmailsManage, controllers.GetEmailOutboxHandler)).Methods("GET")
+	protected.Handle(utils.RouteEmailOutboxResend, middlewares.RequirePermission(utils.PermEmailsManage, controllers.ResendEmailHandler)).Methods("POST")
+	protected.Handle(utils.RouteEmailTemplates, middlewares.RequireSuperAdmin(controllers.GetEmailTemplatesHandler)).Methods("GET")
+	protected.Handle(utils.RouteEmailTemplatePreview, middlewares.RequireSuperAdmin(controllers.PreviewEmailTemplateHandler)).Methods("GET")
+	protected.Handle(utils.RouteCapturedEmails, middlewares.RequireSuperAdmin(controllers.GetCapturedEmailsHandler)).Methods("GET")
+	protected.Handle(utils.RouteCapturedEmails, middlewares.RequireSuperAdmin(controllers.ClearCapturedEmailsHandler)).Methods("DELETE")
+
+	// Webhook Routes
+	protected.Handle(utils.RouteWebhooks, middlewares.RequirePermission(utils.PermWebhooksManage, controllers.GetWebhooksHandler)).Methods("GET")
+	protected.Handle(utils.RouteWebhooks, middlewares.RequirePermission(utils.PermWebhooksManage, controllers.CreateWebhookHandler)).Methods("POST")
+	protected.Handle(utils.RouteWebhookID, middlewares.RequirePermission(utils.PermWebhooksManage, controllers.UpdateWebhookHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteWebhookID, middlewares.RequirePermission(utils.PermWebhooksManage, controllers.DeleteWebhookHandler)).Methods("DELETE")
+	protected.Handle(utils.RouteWebhookDeliveries, middlewares.RequirePermission(utils.PermWebhooksManage, controllers.GetWebhookDeliveriesHandler)).Methods("GET")
+	protected.Handle(utils.RouteWebhookDeliveryReplay, middlewares.RequirePermission(utils.PermWebhooksManage, controllers.ReplayWebhookDeliveryHandler)).Methods("POST")
+
+	// Shipment Routes
+	protected.Handle(utils.RouteCarriers, middlewares.RequirePermission(utils.PermShipmentsRead, controllers.GetCarriersHandler)).Methods("GET")
+	protected.Handle(utils.RouteOrderShipments, middlewares.RequirePermission(utils.PermShipmentsRead, controllers.GetOrderShipmentsHandler)).Methods("GET")
+	protected.Handle(utils.RouteOrderShipments, middlewares.RequirePermission(utils.PermShipmentsWrite, controllers.CreateShipmentHandler)).Methods("POST")
+	protected.Handle(utils.RouteShipmentID, middlewares.RequirePermission(utils.PermShipmentsWrite, controllers.UpdateShipmentHandler)).Methods("PATCH")
+	protected.Handle(utils.RouteOrderReturnLabels, middlewares.RequirePermission(utils.PermShipmentsRead, controllers.GetReturnLabelsHandler)).Methods("GET")
+	protected.Handle(utils.RouteOrderReturnLabels, middlewares.RequirePermission(utils.PermShipmentsWrite, controllers.CreateReturnLabelsHandler)).Methods("POST")
+	protected.Handle(utils.RouteOrderReturnLabelsPDF, middlewares.RequirePermission(utils.PermShipmentsRead, controllers.DownloadReturnLabelsHandler)).Methods("GET")
 
-	// Handle 404
-	router.NotFoundHandler = http.HandlerFunc(controllers.NotFoundHandler)
+	// Handle 404. Router middlewares only run for matched routes, so they are applied here too and
+	// unknown paths are counted under the "unmatched" route
+	router.NotFoundHandler = middlewares.LoggingMiddleware(middlewares.MetricsMiddleware(http.HandlerFunc(controllers.NotFoundHandler)))
 
 	return router
 }
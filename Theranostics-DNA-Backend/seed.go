@@ -0,0 +1,33 @@
+// seed.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/seeds"
+)
+
+// runSeed seeds the database of the selected environment and returns the process exit code.
+func runSeed(args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	env := flags.String("env", "", "environment to seed, overriding ENVIRONMENT")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Reload the configuration so the database of the chosen environment is used
+	if *env != "" {
+		os.Setenv("ENVIRONMENT", *env)
+		if err := config.LoadEnv(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+			return 1
+		}
+	}
+
+	config.InitDB()
+	seeds.SeedAll()
+	return 0
+}
@@ -0,0 +1,56 @@
+// seeds/permissions_seeder.go
+package seeds
+
+import (
+	"log/slog"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+)
+
+// SeedPermissions inserts every permission key the API checks. A newly created permission is
+// granted to the built-in roles that have it by default; existing grants are never touched, so
+// permissions removed by a super-admin stay removed.
+func SeedPermissions() {
+	for _, definition := range utils.PermissionDefinitions {
+		var permission models.Permission
+		result := config.DB.Where("key = ?", definition.Key).First(&permission)
+		if result.Error == nil {
+			// Keep the description in sync with the code
+			if permission.Description != definition.Description {
+				config.DB.Model(&permission).Update("description", definition.Description)
+			}
+			continue
+		}
+		if result.Error != gorm.ErrRecordNotFound {
+			slog.Error(utils.MsgFailedToCheckPermission, "permission", definition.Key, "error", result.Error)
+			continue
+		}
+
+		permission = models.Permission{Key: definition.Key, Description: definition.Description}
+		if err := config.DB.Create(&permission).Error; err != nil {
+			slog.Error(utils.MsgFailedToCreatePermission, "permission", definition.Key, "error", err)
+			continue
+		}
+		slog.Info(utils.MsgPermissionCreated, "permission", definition.Key)
+
+		for roleName, keys := range utils.DefaultRolePermissions {
+			if !utils.StringInSlice(definition.Key, keys) {
+				continue
+			}
+			var role models.Role
+			if err := config.DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+				continue
+			}
+			if err := config.DB.Model(&role).Association("Permissions").Append(&permission); err != nil {
+				slog.Error(utils.MsgFailedToCreatePermission, "permission", definition.Key, "error", err)
+			}
+		}
+	}
+
+	utils.InvalidatePermissionCache()
+	slog.Info(utils.MsgPermissionsSeededSuccessfully)
+}
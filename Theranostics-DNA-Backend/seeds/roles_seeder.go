@@ -2,7 +2,7 @@
 package seeds
 
 import (
-	"log"
+	"log/slog"
 
 	"theransticslabs/m/config"
 	"theransticslabs/m/models"
@@ -16,9 +16,9 @@ import (
 func SeedRoles() {
 	// Define the roles to be seeded
 	roles := []models.Role{
-		{Name: "super-admin"},
-		{Name: "admin"},
-		{Name: "user"},
+		{Name: utils.RoleSuperAdmin, IsSystem: true},
+		{Name: utils.RoleAdmin, IsSystem: true},
+		{Name: utils.RoleUser, IsSystem: true},
 	}
 
 	for _, role := range roles {
@@ -29,19 +29,22 @@ func SeedRoles() {
 			if result.Error == gorm.ErrRecordNotFound {
 				// Role does not exist, create it
 				if err := config.DB.Create(&role).Error; err != nil {
-					log.Printf(utils.MsgFailedToCreateRole, role.Name, err)
+					slog.Error(utils.MsgFailedToCreateRole, "role", role.Name, "error", err)
 				} else {
-					log.Printf(utils.MsgRoleCreated, role.Name)
+					slog.Info(utils.MsgRoleCreated, "role", role.Name)
 				}
 			} else {
 				// An unexpected error occurred
-				log.Printf(utils.MsgFailedToCheckRole, role.Name, result.Error)
+				slog.Error(utils.MsgFailedToCheckRole, "role", role.Name, "error", result.Error)
 			}
 		} else {
-			// Role already exists
-			log.Printf(utils.MsgRoleAlreadyExists, role.Name)
+			// Role already exists; make sure built-in roles stay protected
+			if !existingRole.IsSystem {
+				config.DB.Model(&existingRole).Update("is_system", true)
+			}
+			slog.Debug(utils.MsgRoleAlreadyExists, "role", role.Name)
 		}
 	}
 
-	log.Println(utils.MsgRolesSeededSuccessfully)
+	slog.Info(utils.MsgRolesSeededSuccessfully)
 }
@@ -2,15 +2,22 @@
 package seeds
 
 import (
-    "log"
+    "log/slog"
 
     "theransticslabs/m/utils"
 )
 
-// SeedAll runs all seeding functions.
-func SeedAll() {
+// SeedReferenceData seeds the roles and permissions the API depends on. It is idempotent and
+// runs on every server start so new permission keys exist as soon as the code checks them.
+func SeedReferenceData() {
     SeedRoles()
+    SeedPermissions()
+}
+
+// SeedAll runs all seeding functions. Demo users are only created outside production.
+func SeedAll() {
+    SeedReferenceData()
     SeedUsers()
 
-    log.Println(utils.MsgSeedingCompleted)
+    slog.Info(utils.MsgSeedingCompleted)
 }
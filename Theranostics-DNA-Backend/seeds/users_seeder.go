@@ -2,7 +2,8 @@
 package seeds
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 
 	"theransticslabs/m/config"
 	"theransticslabs/m/models"
@@ -11,9 +12,15 @@ import (
 	"gorm.io/gorm"
 )
 
-// SeedUsers inserts predefined users into the users table.
-// It ensures that users are not duplicated by checking for existing entries.
+// SeedUsers inserts predefined demo users into the users table outside production.
+// It ensures that users are not duplicated by checking for existing entries. New users get an
+// invite link, printed to stdout so the developer running the seed can choose their password.
 func SeedUsers() {
+	if config.IsProduction() {
+		slog.Info(utils.MsgDemoUsersSkipped)
+		return
+	}
+
 	// Define the users to be seeded
 	users := []struct {
 		FirstName string
@@ -88,7 +95,7 @@ func SeedUsers() {
 				var role models.Role
 				roleResult := config.DB.Where("name = ?", u.RoleName).First(&role)
 				if roleResult.Error != nil {
-					log.Printf("Error fetching role '%s' for user '%s': %v", u.RoleName, u.Email, roleResult.Error)
+					slog.Error(utils.MsgFailedToCheckRole, "role", u.RoleName, "email", u.Email, "error", roleResult.Error)
 					continue
 				}
 
@@ -101,19 +108,27 @@ func SeedUsers() {
 				}
 
 				if err := config.DB.Create(&user).Error; err != nil {
-					log.Printf(utils.MsgFailedToCreateUser, u.Email, err)
-				} else {
-					log.Printf(utils.MsgUserCreated, u.Email)
+					slog.Error(utils.MsgFailedToCreateUser, "email", u.Email, "error", err)
+					continue
+				}
+				token, _, err := utils.IssuePasswordToken(config.DB, user.ID, utils.PasswordTokenPurposeInvite, nil)
+				if err != nil {
+					slog.Error(utils.MsgFailedToCreateUser, "user_id", user.ID, "error", err)
+					continue
 				}
+				slog.Info(utils.MsgUserCreated, "user_id", user.ID, "role", u.RoleName)
+				// The link works as a password until it is used, so it goes to the operator running
+				// the seed command and never into the logs
+				fmt.Printf("Set a password for %s at %s\n", u.Email, utils.SetPasswordURL(token))
 			} else {
 				// An unexpected error occurred
-				log.Printf(utils.MsgFailedToCheckUser, u.Email, result.Error)
+				slog.Error(utils.MsgFailedToCheckUser, "email", u.Email, "error", result.Error)
 			}
 		} else {
 			// User already exists
-			log.Printf(utils.MsgUserAlreadyExists, u.Email)
+			slog.Debug(utils.MsgUserAlreadyExists, "user_id", existingUser.ID)
 		}
 	}
 
-	log.Println(utils.MsgUsersSeededSuccessfully)
+	slog.Info(utils.MsgUsersSeededSuccessfully)
 }
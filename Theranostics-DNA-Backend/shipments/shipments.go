@@ -0,0 +1,387 @@
+// shipments/shipments.go
+package shipments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"theransticslabs/m/carriers"
+	"theransticslabs/m/emails"
+	"theransticslabs/m/models"
+	"theransticslabs/m/notifications"
+	"theransticslabs/m/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Shipment directions: the kit going out to the customer, or their sample coming back to the lab.
+const (
+	DirectionOutbound = "outbound"
+	DirectionReturn   = "return"
+)
+
+// Shipment statuses, in the order a shipment goes through them.
+const (
+	StatusPending   = "pending"
+	StatusInTransit = "in_transit"
+	StatusDelivered = "delivered"
+)
+
+// trackTimeout bounds a single call to a carrier's tracking API.
+const trackTimeout = 30 * time.Second
+
+var (
+	// ErrUnknownStatus is returned for statuses other than pending, in_transit and delivered.
+	ErrUnknownStatus = errors.New("unknown shipment status")
+	// ErrStatusBackwards is returned when a shipment would move back to an earlier status.
+	ErrStatusBackwards = errors.New("shipment status can't move backwards")
+)
+
+var statusRank = map[string]int{
+	StatusPending:   0,
+	StatusInTransit: 1,
+	StatusDelivered: 2,
+}
+
+// orderRank orders the statuses shipping moves an order through. Other statuses aren't moved.
+var orderRank = map[string]int{
+	utils.OrderStatusProcessing: 0,
+	utils.OrderStatusShipped:    1,
+	utils.OrderStatusDelivered:  2,
+}
+
+// IsDirection reports whether direction is outbound or return.
+func IsDirection(direction string) bool {
+	return direction == DirectionOutbound || direction == DirectionReturn
+}
+
+// IsStatus reports whether status is a shipment status.
+func IsStatus(status string) bool {
+	_, ok := statusRank[status]
+	return ok
+}
+
+// TrackingURL returns the carrier's tracking page of the shipment, or "" when there is none.
+func TrackingURL(shipment *models.Shipment) string {
+	carrier, ok := carriers.Get(shipment.Carrier)
+	if !ok {
+		return ""
+	}
+	return carrier.TrackingURL(shipment.TrackingNumber)
+}
+
+// Update saves the shipment and, when status is ahead of its current one, moves it there as of at.
+// Moving an outbound shipment moves its order to Shipped and then Delivered, and emails the
+// customer the tracking details when it ships. A delivered return shipment tells the customer
+// their sample arrived. Partners are sent the matching webhooks. Call it with the transaction
+// that makes the change.
+func Update(tx *gorm.DB, shipment *models.Shipment, status string, at time.Time) error {
+	rank, ok := statusRank[status]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownStatus, status)
+	}
+	current := statusRank[shipment.Status]
+	if rank < current {
+		return ErrStatusBackwards
+	}
+	if rank == current {
+		return tx.Save(shipment).Error
+	}
+
+	shipment.Status = status
+	if shipment.ShippedAt == nil {
+		shipment.ShippedAt = &at
+	}
+	if status == StatusDelivered {
+		shipment.DeliveredAt = &at
+	}
+	if err := tx.Save(shipment).Error; err != nil {
+		return err
+	}
+
+	var (
+		order    models.Order
+		customer models.Customer
+	)
+	if err := tx.First(&order, shipment.OrderID).Error; err != nil {
+		return err
+	}
+	if err := tx.First(&customer, order.CustomerID).Error; err != nil {
+		return err
+	}
+
+	event := utils.WebhookShipmentShipped
+	if status == StatusDelivered {
+		event = utils.WebhookShipmentDelivered
+	}
+	if err := utils.PublishWebhookEvent(tx, event, utils.WebhookShipmentData{
+		ShipmentID:     shipment.ID,
+		OrderID:        shipment.OrderID,
+		Direction:      shipment.Direction,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		Status:         shipment.Status,
+		ShippedAt:      shipment.ShippedAt,
+		DeliveredAt:    shipment.DeliveredAt,
+	}); err != nil {
+		return err
+	}
+
+	if shipment.Direction == DirectionReturn {
+		if status != StatusDelivered {
+			return nil
+		}
+		return notifications.Dispatch(tx, notifications.Notification{
+			Event:     notifications.EventSampleReceived,
+			Recipient: notifications.Customer(&customer),
+			Data: emails.SampleReceivedData{
+				FirstName:   customer.FirstName,
+				LastName:    customer.LastName,
+				ProductName: order.ProductName,
+			},
+		})
+	}
+
+	// A kit delivered without ever being marked in transit still gets its tracking email
+	if current == statusRank[StatusPending] {
+		if err := notifyKitShipped(tx, shipment, &order, &customer); err != nil {
+			return err
+		}
+	}
+	orderStatus := utils.OrderStatusShipped
+	if status == StatusDelivered {
+		orderStatus = utils.OrderStatusDelivered
+	}
+	return advanceOrder(tx, &order, orderStatus)
+}
+
+// notifyKitShipped sends the customer the kit's tracking details, with the return labels for
+// their samples attached.
+func notifyKitShipped(tx *gorm.DB, shipment *models.Shipment, order *models.Order, customer *models.Customer) error {
+	carrierName := shipment.Carrier
+	if carrier, ok := carriers.Get(shipment.Carrier); ok {
+		carrierName = carrier.Name()
+	}
+
+	attachments, err := returnLabelAttachments(tx, order, customer)
+	if err != nil {
+		return err
+	}
+	return notifications.Dispatch(tx, notifications.Notification{
+		Event:     notifications.EventKitShipped,
+		Recipient: notifications.Customer(customer),
+		Data: emails.KitShippedData{
+			FirstName:           customer.FirstName,
+			LastName:            customer.LastName,
+			ProductName:         order.ProductName,
+			Carrier:             carrierName,
+			TrackingNumber:      shipment.TrackingNumber,
+			TrackingURL:         TrackingURL(shipment),
+			ReturnLabelAttached: len(attachments) > 0,
+		},
+		Attachments: attachments,
+	})
+}
+
+// returnLabelAttachments creates the order's return labels and renders them for the shipping email.
+// The rendered file is deleted once the email has been sent.
+// Without a lab address configured the email goes out without them; staff can download the labels
+// once it is set.
+func returnLabelAttachments(tx *gorm.DB, order *models.Order, customer *models.Customer) ([]models.OutboxAttachment, error) {
+	if customer.ErasedAt != nil {
+		return nil, nil
+	}
+	labels, err := utils.EnsureReturnLabels(tx, order)
+	if err != nil {
+		return nil, err
+	}
+	path, err := utils.SaveReturnLabelsPDF(labels, order, customer)
+	if err != nil {
+		if errors.Is(err, utils.ErrLabAddressMissing) {
+			slog.Warn("Sending the kit shipped email without return labels", "order_id", order.ID, "error", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []models.OutboxAttachment{{
+		Filename:    fmt.Sprintf("return_labels_order_%d.pdf", order.ID),
+		Path:        path,
+		ContentType: "application/pdf",
+		Temporary:   true,
+	}}, nil
+}
+
+// advanceOrder moves the order forward to status. Orders already past it, or outside the shipping
+// flow such as cancelled ones, are left alone.
+func advanceOrder(tx *gorm.DB, order *models.Order, status string) error {
+	current, ok := orderRank[order.OrderStatus]
+	if !ok || current >= orderRank[status] {
+		return nil
+	}
+
+	previousStatus := order.OrderStatus
+	order.OrderStatus = status
+	if err := tx.Model(order).Select("OrderStatus").Updates(order).Error; err != nil {
+		return err
+	}
+	return utils.PublishWebhookEvent(tx, utils.WebhookOrderStatusChanged, utils.WebhookOrderStatusData{
+		OrderID:        order.ID,
+		PreviousStatus: previousStatus,
+		OrderStatus:    status,
+	})
+}
+
+// AuditState is the audited snapshot of a shipment.
+func AuditState(shipment *models.Shipment) map[string]interface{} {
+	return map[string]interface{}{
+		"direction":       shipment.Direction,
+		"carrier":         shipment.Carrier,
+		"tracking_number": shipment.TrackingNumber,
+		"status":          shipment.Status,
+	}
+}
+
+// Poll asks carriers with a tracking API about up to batchSize shipments that have a tracking
+// number and aren't delivered yet, skipping ones checked within minAge, and applies what they
+// report. It returns how many shipments were checked. A carrier error only skips that shipment
+// until its next turn.
+func Poll(db *gorm.DB, batchSize int, minAge time.Duration) (int, error) {
+	trackable := carriers.Trackable()
+	if len(trackable) == 0 {
+		return 0, nil
+	}
+
+	// Stamp the batch as checked while claiming it, so other instances pick different shipments
+	var claimed []models.Shipment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND tracking_number <> '' AND carrier IN ?", []string{StatusPending, StatusInTransit}, trackable).
+			Where("last_checked_at IS NULL OR last_checked_at <= ?", now.Add(-minAge)).
+			Order("last_checked_at NULLS FIRST").Limit(batchSize).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(claimed))
+		for i := range claimed {
+			ids[i] = claimed[i].ID
+		}
+		return tx.Model(&models.Shipment{}).Where("id IN ?", ids).Update("last_checked_at", now).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range claimed {
+		ctx := db.Statement.Context
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+
+		shipment := &claimed[i]
+		carrier, _ := carriers.Get(shipment.Carrier)
+		tracker, ok := carrier.(carriers.Tracker)
+		if !ok {
+			continue
+		}
+
+		trackCtx, cancel := context.WithTimeout(ctx, trackTimeout)
+		status, err := tracker.Track(trackCtx, shipment.TrackingNumber)
+		cancel()
+		if err != nil {
+			slog.Warn("Failed to track shipment", "shipment_id", shipment.ID, "carrier", shipment.Carrier, "error", err)
+			continue
+		}
+
+		if err := applyCarrierStatus(db.WithContext(context.WithoutCancel(ctx)), shipment.ID, status); err != nil {
+			slog.Error("Failed to apply the carrier's shipment status", "shipment_id", shipment.ID, "error", err)
+		}
+	}
+	return len(claimed), nil
+}
+
+// applyCarrierStatus records a carrier's status on the shipment, moving it forward when the
+// carrier reports progress staff haven't recorded yet.
+func applyCarrierStatus(db *gorm.DB, shipmentID uint, status carriers.Status) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var shipment models.Shipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shipment, shipmentID).Error; err != nil {
+			return err
+		}
+		before := AuditState(&shipment)
+
+		shipment.CarrierStatus = status.Description
+		if description := []rune(status.Description); len(description) > 255 {
+			shipment.CarrierStatus = string(description[:255])
+		}
+
+		next, at := shipment.Status, time.Now()
+		switch status.State {
+		case carriers.StateInTransit:
+			if shipment.Status == StatusPending {
+				next = StatusInTransit
+			}
+		case carriers.StateDelivered:
+			next = StatusDelivered
+			if status.DeliveredAt != nil {
+				at = *status.DeliveredAt
+			}
+		}
+		if next == shipment.Status {
+			return tx.Model(&shipment).Select("CarrierStatus").Updates(&shipment).Error
+		}
+
+		if err := Update(tx, &shipment, next, at); err != nil {
+			return err
+		}
+		return utils.RecordAudit(tx, nil, nil, utils.AuditEntry{
+			Action:     utils.AuditShipmentUpdate,
+			EntityType: utils.AuditEntityShipment,
+			EntityID:   shipment.ID,
+			Before:     before,
+			After:      AuditState(&shipment),
+		})
+	})
+}
+
+// RunTracker polls carriers for shipment updates every interval until ctx is cancelled. It
+// returns straight away when no registered carrier has a tracking API.
+func RunTracker(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	if len(carriers.Trackable()) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	const batchSize = 50
+	for {
+		// Keep going while full batches come back, so a backlog drains without waiting
+		for {
+			checked, err := Poll(db.WithContext(ctx), batchSize, interval)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					slog.Error("Failed to poll carriers for shipment updates", "error", err)
+				}
+				break
+			}
+			if checked < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// sms/sms.go
+package sms
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Provider delivers text messages. To is the recipient's phone number as stored, digits only, and
+// providers are expected to normalise it to the format their API needs.
+type Provider interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+// LogProvider writes messages to the log instead of sending them, for development.
+type LogProvider struct{}
+
+// Send logs that a message would have been sent. Only the last digits of the number and the
+// length of the message are logged, since both are personal data.
+func (LogProvider) Send(ctx context.Context, to, body string) error {
+	slog.InfoContext(ctx, "sms not sent: log provider", "to", maskPhone(to), "body_length", len(body))
+	return nil
+}
+
+// maskPhone hides all but the last two digits of a phone number.
+func maskPhone(number string) string {
+	if len(number) <= 2 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-2) + number[len(number)-2:]
+}
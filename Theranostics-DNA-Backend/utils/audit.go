@@ -0,0 +1,132 @@
+// utils/audit.go
+package utils
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+)
+
+// HeaderRequestID carries the request ID on incoming requests and every response.
+const HeaderRequestID = "X-Request-ID"
+
+// Audit actions, named "<entity>.<verb>".
+const (
+	AuditStaffCreate          = "staff.create"
+	AuditStaffUpdate          = "staff.update"
+	AuditStaffStatus          = "staff.status"
+	AuditStaffPasswordReset   = "staff.password_reset"
+	AuditStaffRoleChange      = "staff.role_change"
+	AuditStaffDelete          = "staff.delete"
+	AuditStaffUnlock          = "staff.unlock"
+	AuditStaffTwoFactorReset  = "staff.two_factor_reset"
+	AuditAccountUpdate        = "account.update"
+	AuditAccountPasswordSet   = "account.password_set"
+	AuditAccountPasswordReset = "account.password_change"
+	AuditAccountTwoFactorOn   = "account.two_factor_enable"
+	AuditAccountTwoFactorOff  = "account.two_factor_disable"
+	AuditAccountRecoveryCodes = "account.recovery_codes"
+	AuditAccountSessionRevoke = "account.session_revoke"
+	AuditRoleCreate           = "role.create"
+	AuditRoleUpdate           = "role.update"
+	AuditRolePermissions      = "role.permissions"
+	AuditRoleDelete           = "role.delete"
+	AuditKitCreate            = "kit.create"
+	AuditKitUpdate            = "kit.update"
+	AuditKitDelete            = "kit.delete"
+	AuditProductOfferCreate   = "product_offer.create"
+	AuditCustomerExport       = "customer.export"
+	AuditCustomerErase        = "customer.erase"
+	AuditPaymentReconcile     = "payment.reconcile"
+	AuditEmailResend          = "email.resend"
+	AuditNotificationPrefs    = "notification_preferences.update"
+	AuditWebhookCreate        = "webhook.create"
+	AuditWebhookUpdate        = "webhook.update"
+	AuditWebhookDelete        = "webhook.delete"
+	AuditWebhookReplay        = "webhook.replay"
+	AuditShipmentCreate       = "shipment.create"
+	AuditShipmentUpdate       = "shipment.update"
+	AuditReturnLabelCreate    = "return_label.create"
+	AuditReturnLabelDownload  = "return_label.download"
+)
+
+// Audit entity types.
+const (
+	AuditEntityUser         = "user"
+	AuditEntityRole         = "role"
+	AuditEntityKit          = "kit"
+	AuditEntityProductOffer = "product_offer"
+	AuditEntityCustomer     = "customer"
+	AuditEntitySession      = "session"
+	AuditEntityPayment      = "payment"
+	AuditEntityEmail        = "email"
+	AuditEntityWebhook      = "webhook"
+	AuditEntityShipment     = "shipment"
+	AuditEntityOrder        = "order"
+)
+
+// AuditEntry describes an action to record. Before and After are snapshots of the fields that
+// matter for the entity; leave Before nil for creations and After nil for deletions. Snapshots
+// must never contain secrets or customer PII.
+type AuditEntry struct {
+	Action     string
+	EntityType string
+	EntityID   uint
+	Before     map[string]interface{}
+	After      map[string]interface{}
+}
+
+// RecordAudit stores an audit entry for the request. Call it with the transaction that makes the
+// change so the entry is only kept if the change is. Commands run from the CLI pass a nil request
+// and actor.
+func RecordAudit(tx *gorm.DB, r *http.Request, actor *models.User, entry AuditEntry) error {
+	record := models.AuditLog{
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		Changes:    AuditDiff(entry.Before, entry.After),
+	}
+	if r != nil {
+		record.IPAddress = truncate(ClientIP(r), 45)
+		record.RequestID = truncate(RequestID(r), 64)
+	}
+	if entry.EntityID != 0 {
+		record.EntityID = strconv.FormatUint(uint64(entry.EntityID), 10)
+	}
+	if actor != nil {
+		record.ActorID = &actor.ID
+		record.ActorEmail = actor.Email
+	}
+	return tx.Create(&record).Error
+}
+
+// AuditDiff returns the fields whose values differ between two snapshots. A nil after records
+// every before field as removed; otherwise fields missing from after are treated as unchanged.
+func AuditDiff(before, after map[string]interface{}) models.AuditChanges {
+	changes := models.AuditChanges{}
+	for field, old := range before {
+		if after == nil {
+			changes[field] = models.AuditChange{Before: old}
+			continue
+		}
+		if value, ok := after[field]; ok && !reflect.DeepEqual(old, value) {
+			changes[field] = models.AuditChange{Before: old, After: value}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = models.AuditChange{After: value}
+		}
+	}
+	return changes
+}
+
+// RequestID returns the ID that correlates a request across logs and audit entries. It is
+// assigned by the logging middleware.
+func RequestID(r *http.Request) string {
+	return config.RequestIDFromContext(r.Context())
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// decodeCode128 reads the symbol values back out of bar and space widths.
+func decodeCode128(t *testing.T, widths []int) []int {
+	t.Helper()
+	patterns := map[string]int{}
+	for value, pattern := range code128Patterns {
+		patterns[pattern] = value
+	}
+
+	var symbols []int
+	for len(widths) > 0 {
+		size := 6
+		if len(widths) == 7 {
+			size = 7 // The stop pattern ends with a terminating bar
+		}
+		if len(widths) < size {
+			t.Fatalf("%d widths left over", len(widths))
+		}
+		var pattern strings.Builder
+		modules := 0
+		for _, w := range widths[:size] {
+			pattern.WriteString(strconv.Itoa(w))
+			modules += w
+		}
+		if modules != 11 && !(size == 7 && modules == 13) {
+			t.Fatalf("symbol %s is %d modules wide", pattern.String(), modules)
+		}
+		value, ok := patterns[pattern.String()]
+		if !ok {
+			t.Fatalf("unknown pattern %s", pattern.String())
+		}
+		symbols = append(symbols, value)
+		widths = widths[size:]
+	}
+	return symbols
+}
+
+func TestCode128Modules(t *testing.T) {
+	tests := []struct {
+		value    string
+		checksum int
+	}{
+		{"PJJ123C", 55},
+		{"TK7QX2M9HDKP", -1},
+		{"A", (code128StartB + 33) % 103},
+		{"", code128StartB % 103},
+		{" ~", (code128StartB + 0 + 2*94) % 103},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			widths, err := code128Modules(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			symbols := decodeCode128(t, widths)
+			if len(symbols) != len(tt.value)+3 {
+				t.Fatalf("got %d symbols, want %d", len(symbols), len(tt.value)+3)
+			}
+			if symbols[0] != code128StartB || symbols[len(symbols)-1] != code128Stop {
+				t.Errorf("symbols %v don't start with start B and end with stop", symbols)
+			}
+
+			var decoded strings.Builder
+			checksum := code128StartB
+			for i, symbol := range symbols[1 : len(symbols)-2] {
+				decoded.WriteByte(byte(symbol + 32))
+				checksum += (i + 1) * symbol
+			}
+			if decoded.String() != tt.value {
+				t.Errorf("decoded %q, want %q", decoded.String(), tt.value)
+			}
+			if got := symbols[len(symbols)-2]; got != checksum%103 || (tt.checksum >= 0 && got != tt.checksum) {
+				t.Errorf("check symbol = %d, want %d", got, checksum%103)
+			}
+		})
+	}
+}
+
+func TestCode128ModulesRejectsUnencodable(t *testing.T) {
+	for _, value := range []string{"TK\n1", "Zoë", "\x7f", "tab\there"} {
+		if _, err := code128Modules(value); err == nil {
+			t.Errorf("code128Modules(%q) succeeded", value)
+		}
+	}
+}
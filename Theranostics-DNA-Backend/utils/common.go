@@ -2,6 +2,9 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+
 	"theransticslabs/m/models"
 
 	"gorm.io/gorm"
@@ -28,3 +31,12 @@ func StringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+// GenerateRandomToken returns a hex-encoded string built from n cryptographically random bytes.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,6 +1,19 @@
 // utils/constantsgo
 package utils
 
+// Payment and order statuses stored on orders and payments.
+const (
+	PaymentStatusPending   = "Pending"
+	PaymentStatusCompleted = "Completed"
+	PaymentStatusFailed    = "Failed"
+
+	OrderStatusPending    = "Pending"
+	OrderStatusProcessing = "Processing"
+	OrderStatusShipped    = "Shipped"
+	OrderStatusDelivered  = "Delivered"
+	OrderStatusCancelled  = "Cancelled"
+)
+
 const (
 	// Route Names
 
@@ -8,30 +21,75 @@ const (
 	RouteWelcome               = "/"
 	RouteLogin                 = "/login"
 	RouteForgetPassword        = "/user/forgot-password"
-	RouteEncryptProductDetails = "/encrypt-product"
+	RouteSetPassword           = "/user/set-password"
 	RouteVerifyProductDetails  = "/verify-product"
 	RouteProductPaymentDetails = "/order-payment"
 	RoutePaymentSuccessPaypal  = "/payment/status"
+	RouteRefreshToken          = "/auth/refresh"
+	RouteTwoFactorLogin        = "/auth/2fa/verify"
+	RouteMetrics               = "/metrics"
+	RouteHealthz               = "/healthz"
+	RouteReadyz                = "/readyz"
 
 	// Private
 	RouteLogout                  = "/logout"
+	RouteEncryptProductDetails   = "/encrypt-product"
 	RouteResetPassword           = "/user/reset-password"
 	RouteUpdateUser              = "/user"
 	RouteGetUserProfile          = "/user/profile"
+	RouteUserSessions            = "/user/sessions"
+	RouteUserSessionID           = "/user/sessions/{id}"
+	RouteUserNotifications       = "/user/notification-preferences"
+	RouteTwoFactor               = "/user/2fa"
+	RouteTwoFactorSetup          = "/user/2fa/setup"
+	RouteTwoFactorEnable         = "/user/2fa/enable"
+	RouteTwoFactorRecoveryCodes  = "/user/2fa/recovery-codes"
+	RouteResetStaffTwoFactor     = "/staff/{id}/2fa"
+	RouteUnlockAdminUser         = "/staff/{id}/unlock"
+	RouteRoleTwoFactor           = "/roles/{id}/2fa"
+	RouteRoles                   = "/roles"
+	RouteRoleID                  = "/roles/{id}"
+	RouteRolePermissions         = "/roles/{id}/permissions"
+	RoutePermissions             = "/permissions"
 	RouteGetAdminUserList        = "/staff"
 	RouteCreateAdminUser         = "/staff"
 	RouteUpdateAdminUserProfile  = "/staff/{id}/details"
 	RouteUpdateAdminUserPassword = "/staff/{id}/password"
 	RouteUpdateAdminUserStatus   = "/staff/{id}/status"
+	RouteUpdateAdminUserRole     = "/staff/{id}/role"
 	RouteDeleteAdminUser         = "/staff/{id}"
 	RouteKitInfo                 = "/kits"
 	RouteKitInfoID               = "/kits/{id}"
+	RouteCustomerDataExport      = "/customers/{id}/export"
+	RouteCustomerErase           = "/customers/{id}/erase"
+	RouteCustomerNotifications   = "/customers/{id}/notification-preferences"
+	RouteAuditLogs               = "/audit-logs"
+	RouteAuditLogsExport         = "/audit-logs/export"
+	RouteEmailOutbox             = "/emails/outbox"
+	RouteEmailOutboxResend       = "/emails/outbox/{id}/resend"
+	RouteEmailTemplates          = "/emails/templates"
+	RouteEmailTemplatePreview    = "/emails/templates/{name}/preview"
+	RouteCapturedEmails          = "/emails/captured"
+	RouteWebhooks                = "/webhooks"
+	RouteWebhookID               = "/webhooks/{id}"
+	RouteWebhookDeliveries       = "/webhooks/{id}/deliveries"
+	RouteWebhookDeliveryReplay   = "/webhooks/deliveries/{id}/replay"
+	RouteOrderShipments          = "/orders/{id}/shipments"
+	RouteShipmentID              = "/shipments/{id}"
+	RouteCarriers                = "/carriers"
+	RouteOrderReturnLabels       = "/orders/{id}/return-labels"
+	RouteOrderReturnLabelsPDF    = "/orders/{id}/return-labels/pdf"
 
 	// API Messages
 	MsgWelcome                                 = "Welcome to the project!"
 	MsgDatabaseConnected                       = "Database connection established successfully."
 	MsgDatabaseMigrated                        = "Database migration completed successfully."
-	MsgServerStarted                           = "Server is running on port %s."
+	MsgMigrationsPending                       = "Database migrations are pending. Run the migrate up command first."
+	MsgServerStarted                           = "Server is running."
+	MsgServerStopped                           = "Server stopped."
+	MsgServiceAlive                            = "Service is alive."
+	MsgServiceReady                            = "Service is ready."
+	MsgServiceNotReady                         = "Service is not ready."
 	MsgInternalServerError                     = "An internal server error has occurred."
 	MsgEndpointNotFound                        = "The requested endpoint could not be found."
 	MsgUserNotFound                            = "User not found in the current context."
@@ -43,21 +101,29 @@ const (
 	MsgOnlyEmailPasswordAllowed                = "Only email and password fields are permitted."
 	MsgOnlyAllowedFieldsAllowed                = "Only specific fields are permitted."
 	MsgAccessDeniedForOtherThanAdminSuperAdmin = "You are not authorized to view this page."
+	MsgSuperAdminOnly                          = "Only super-admins can access this resource."
 
 	// Seeding Messages
 	MsgRolesSeededSuccessfully = "Roles seeded successfully."
-	MsgRoleCreated             = "Role '%s' created successfully."
-	MsgRoleAlreadyExists       = "Role '%s' already exists."
-	MsgFailedToCreateRole      = "Failed to create role '%s': %v."
-	MsgFailedToCheckRole       = "Error checking role '%s': %v."
+	MsgRoleCreated             = "Role created."
+	MsgRoleAlreadyExists       = "Role already exists."
+	MsgFailedToCreateRole      = "Failed to create role."
+	MsgFailedToCheckRole       = "Error checking role."
 	MsgSeedingCompleted        = "Seeding process completed successfully."
 
+	// Permission Seeding Messages
+	MsgPermissionsSeededSuccessfully = "Permissions seeded successfully."
+	MsgPermissionCreated             = "Permission created."
+	MsgFailedToCreatePermission      = "Failed to create permission."
+	MsgFailedToCheckPermission       = "Error checking permission."
+
 	// User Seeding Messages
 	MsgUsersSeededSuccessfully = "Users seeded successfully."
-	MsgUserCreated             = "User '%s' created successfully."
-	MsgUserAlreadyExists       = "User '%s' already exists."
-	MsgFailedToCreateUser      = "Failed to create user '%s': %v."
-	MsgFailedToCheckUser       = "Error checking user '%s': %v."
+	MsgDemoUsersSkipped        = "Demo users are not seeded in production."
+	MsgUserCreated             = "User created."
+	MsgUserAlreadyExists       = "User already exists."
+	MsgFailedToCreateUser      = "Failed to create user."
+	MsgFailedToCheckUser       = "Error checking user."
 
 	// Middlewares Messages
 	MsgAuthHeaderMissing            = "Authorization header missing"
@@ -78,14 +144,157 @@ const (
 	MsgInvalidAuthorizationHeader = "The format of the authorization header is invalid."
 	MsgInvalidOrExpiredToken      = "The token is either invalid or expired."
 	MsgUserInactive               = "The account is currently inactive."
-	MsgTokenSaveFailed            = "Failed to save the token. Please try again."
 	MsgLogoutSuccess              = "Successfully logged out."
+	MsgTooManyLoginAttempts       = "Too many failed login attempts. Please wait before trying again."
+	MsgTemporarilyLocked          = "Too many failed attempts. Access is temporarily locked, please try again later."
+
+	// Session Messages
+	MsgSessionRevokedOrExpired          = "The session has been revoked or has expired."
+	MsgMissingRefreshToken              = "Refresh token is required."
+	MsgInvalidRefreshToken              = "The refresh token is invalid or expired."
+	MsgRefreshTokenReused               = "The refresh token has already been used. The session has been revoked for your security."
+	MsgTokenRefreshedSuccessfully       = "Token refreshed successfully."
+	MsgSessionsFetchedSuccessfully      = "Active sessions fetched successfully."
+	MsgInvalidSessionID                 = "Invalid session ID."
+	MsgSessionNotFound                  = "Session not found or already revoked."
+	MsgSessionRevokedSuccessfully       = "Session revoked successfully."
+	MsgOtherSessionsRevokedSuccessfully = "All other sessions have been revoked."
+
+	// Two-Factor Authentication Messages
+	MsgTwoFactorChallengeIssued         = "Enter the code from your authenticator app to complete the login."
+	MsgMissingTwoFactorChallenge        = "The two-factor challenge token is required."
+	MsgInvalidTwoFactorChallenge        = "The two-factor challenge is invalid or has expired. Please log in again."
+	MsgMissingTwoFactorCode             = "A verification code or recovery code is required."
+	MsgInvalidTwoFactorCode             = "The verification code is invalid."
+	MsgTwoFactorAlreadyEnabled          = "Two-factor authentication is already enabled."
+	MsgTwoFactorNotEnabled              = "Two-factor authentication is not enabled."
+	MsgTwoFactorSetupNotStarted         = "Start two-factor setup before confirming a code."
+	MsgTwoFactorSetupStarted            = "Scan the QR code with your authenticator app and confirm a code to finish setup."
+	MsgTwoFactorEnabledSuccessfully     = "Two-factor authentication enabled. Store the recovery codes somewhere safe."
+	MsgTwoFactorDisabledSuccessfully    = "Two-factor authentication disabled."
+	MsgTwoFactorRequiredByRole          = "Two-factor authentication is required for your role and cannot be disabled."
+	MsgTwoFactorEnrolmentRequired       = "Your role requires two-factor authentication. Please set it up to continue."
+	MsgRecoveryCodesRegenerated         = "New recovery codes generated. Previous codes no longer work."
+	MsgTwoFactorResetSuccessfully       = "Two-factor authentication has been reset for the user."
+	MsgRoleTwoFactorUpdatedSuccessfully = "Role two-factor requirement updated successfully."
+	MsgRoleNotFound                     = "Role not found."
+	MsgInvalidUserID                    = "Invalid user ID."
+	MsgUserUnlockedSuccessfully         = "The user's login lockout has been lifted."
+
+	// Role Management Messages
+	MsgRolesFetchedSuccessfully           = "Roles fetched successfully."
+	MsgPermissionsFetchedSuccessfully     = "Permissions fetched successfully."
+	MsgRoleCreatedSuccessfully            = "Role created successfully."
+	MsgRoleUpdatedSuccessfully            = "Role updated successfully."
+	MsgRolePermissionsUpdatedSuccessfully = "Role permissions updated successfully."
+	MsgRoleDeletedSuccessfully            = "Role deleted successfully."
+	MsgInvalidRoleName                    = "Role name must be 2-20 characters of lowercase letters, numbers and hyphens, starting with a letter."
+	MsgRoleNameInUse                      = "A role with this name already exists."
+	MsgSystemRoleProtected                = "Built-in roles cannot be renamed, deleted or have their super-admin access changed."
+	MsgRoleInUse                          = "The role is assigned to users and cannot be deleted."
+	MsgUnknownPermission                  = "One or more permissions do not exist."
+	MsgFailedToCreateRoleRecord           = "Failed to create the role."
+
+	// Staff Role Messages
+	MsgUserRoleUpdatedSuccessfully = "The user's role has been changed and their sessions were signed out."
+	MsgRoleAboveOwn                = "You cannot assign or manage a role with access beyond your own."
+	MsgRoleNotAssignable           = "The role is disabled and cannot be assigned."
+	MsgRoleUnchanged               = "The user already has this role."
+	MsgLastSuperAdmin              = "The last active super-admin cannot be demoted, deactivated or deleted."
+	MsgUserStatusUnchanged         = "The user's status is already set to the requested value."
+
+	// Audit Log Messages
+	MsgAuditLogsFetchedSuccessfully = "Audit logs fetched successfully."
+	MsgInvalidActorID               = "The actor ID is invalid."
+	MsgInvalidEntityID              = "The entity ID is invalid."
+	MsgInvalidDateFilter            = "Dates must be formatted as YYYY-MM-DD or RFC 3339."
+	MsgFailedToExportAuditLogs      = "Failed to export the audit logs."
+
+	// Email Outbox Messages
+	MsgEmailOutboxFetchedSuccessfully = "Email outbox fetched successfully."
+	MsgInvalidEmailStatus             = "Status must be pending, sent or dead."
+	MsgInvalidEmailID                 = "Invalid email ID."
+	MsgEmailNotFound                  = "Email not found."
+	MsgEmailNotDeadLettered           = "Only emails that have exhausted their retries can be resent."
+	MsgFailedToResendEmail            = "Failed to queue the email for resending."
+	MsgEmailQueuedForResend           = "The email has been queued for resending."
+
+	// Notification Preference Messages
+	MsgNotificationPreferencesFetched        = "Notification preferences fetched successfully."
+	MsgNotificationPreferencesUpdated        = "Notification preferences updated successfully."
+	MsgNoNotificationPreferences             = "At least one preference must be provided."
+	MsgInvalidNotificationPreference         = "Each preference needs an event and a channel that apply to the recipient."
+	MsgFailedToUpdateNotificationPreferences = "Failed to update the notification preferences."
+
+	// Webhook Messages
+	MsgWebhooksFetched          = "Webhook subscriptions fetched successfully."
+	MsgWebhookCreated           = "Webhook subscription created successfully. Store the secret now; it is not shown again."
+	MsgWebhookUpdated           = "Webhook subscription updated successfully."
+	MsgWebhookDeleted           = "Webhook subscription deleted successfully."
+	MsgInvalidWebhookID         = "Invalid webhook subscription ID."
+	MsgWebhookNotFound          = "Webhook subscription not found."
+	MsgInvalidWebhookURL        = "URL must be an absolute https URL, or http outside production."
+	MsgInvalidWebhookEvents     = "Events must list at least one supported event type."
+	MsgInvalidWebhookSecret     = "Secret must be between 16 and 255 characters."
+	MsgInvalidWebhookDesc       = "Description must not exceed 255 characters."
+	MsgNoWebhookChanges         = "At least one field must be provided."
+	MsgFailedToSaveWebhook      = "Failed to save the webhook subscription."
+	MsgWebhookDeliveriesFetched = "Webhook deliveries fetched successfully."
+	MsgInvalidDeliveryStatus    = "Status must be pending, sent or dead."
+	MsgInvalidDeliveryID        = "Invalid delivery ID."
+	MsgDeliveryNotFound         = "Delivery not found."
+	MsgWebhookInactive          = "The webhook subscription is disabled; enable it before replaying deliveries."
+	MsgFailedToReplayDelivery   = "Failed to queue the delivery for replay."
+	MsgDeliveryQueuedForReplay  = "The delivery has been queued for replay."
+
+	// Shipment Messages
+	MsgShipmentsFetched         = "Shipments fetched successfully."
+	MsgCarriersFetched          = "Carriers fetched successfully."
+	MsgShipmentCreated          = "Shipment created successfully."
+	MsgShipmentUpdated          = "Shipment updated successfully."
+	MsgInvalidShipmentID        = "Invalid shipment ID."
+	MsgShipmentNotFound         = "Shipment not found."
+	MsgInvalidOrderID           = "Invalid order ID."
+	MsgOrderNotShippable        = "Only paid orders that are not cancelled can be shipped."
+	MsgInvalidShipmentDirection = "Direction must be outbound or return."
+	MsgUnknownCarrier           = "Carrier must be one of: %s."
+	MsgInvalidTrackingNumber    = "Tracking number must not exceed 100 characters."
+	MsgTrackingNumberRequired   = "An outbound kit needs a tracking number before it can be marked as shipped."
+	MsgInvalidShipmentStatus    = "Status must be pending, in_transit or delivered."
+	MsgShipmentStatusBackwards  = "A shipment's status can't move backwards."
+	MsgShipmentAlreadyDelivered = "Delivered shipments can't be changed."
+	MsgNoShipmentChanges        = "At least one field must be provided."
+	MsgFailedToSaveShipment     = "Failed to save the shipment."
+
+	// Return Label Messages
+	MsgReturnLabelsFetched        = "Return labels fetched successfully."
+	MsgReturnLabelsCreated        = "Return labels created successfully."
+	MsgReturnLabelsNotFound       = "The order has no return labels yet."
+	MsgReturnLabelUnitNotFound    = "The order has no return label for that kit."
+	MsgInvalidReturnLabelUnit     = "Unit must be a positive kit number."
+	MsgReturnLabelsErased         = "Return labels can't be printed for a customer whose data has been erased."
+	MsgLabAddressNotConfigured    = "The lab address for return labels is not configured."
+	MsgFailedToSaveReturnLabels   = "Failed to create the return labels."
+	MsgFailedToRenderReturnLabels = "Failed to generate the return labels."
+
+	// Email Template Messages
+	MsgEmailTemplatesFetchedSuccessfully = "Email templates fetched successfully."
+	MsgEmailTemplateNotFound             = "Email template not found."
+	MsgCapturedEmailsFetched             = "Captured emails fetched successfully."
+	MsgCapturedEmailsCleared             = "Captured emails cleared successfully."
+	MsgMailCaptureDisabled               = "Emails are only captured when the memory mail transport is in use."
+	MsgUnsupportedLanguage               = "The language is not supported."
 
 	// Forget Password Messages
-	MsgMissingEmail       = "Email is required."
-	MsgEmailNotExist      = "The provided email does not exist."
-	MsgFailedSentEmail    = "Failed to send email. Please try again later."
-	MsgForgetSuccessfully = "A new password has been sent successfully. Please check your email."
+	MsgMissingEmail                 = "Email is required."
+	MsgEmailNotExist                = "The provided email does not exist."
+	MsgFailedSentEmail              = "Failed to send email. Please try again later."
+	MsgForgetSuccessfully           = "A password reset link has been sent. Please check your email."
+	MsgTooManyPasswordResetRequests = "Too many password reset requests. Please wait before trying again."
+	MsgPasswordResetLinkSent        = "A password reset link has been emailed to the user."
+	MsgMissingPasswordToken         = "The password token is required."
+	MsgInvalidPasswordToken         = "The password link is invalid, has expired or has already been used."
+	MsgPasswordSetSuccessfully      = "Your password has been set. You can now log in."
 
 	// Reset Password Messages
 	MsgPasswordSame              = "The new password must differ from the old password."
@@ -187,13 +396,22 @@ const (
 	MsgOrderNotFound                  = "Order not found"
 	MsgInvalidImageFormat             = "Invalid image format."
 
-	MsgErrorEncodingProductDetails          = "Error encoding product details"
-	MsgErrorEncryptingProductDetails        = "Error encrypting product details"
-	MsgProductDetailsEncryptedSuccessfully  = "Product details encrypted successfully"
-	MsgMissingOrEmptyEncryptedDataParameter = "Missing or empty encrypted data parameter"
-	MsgFailedToDecryptData                  = "Failed to decrypt data"
-	MsgInvalidProductDataFormat             = "Invalid product data format"
-	MsgProductNameIsRequired                = "Product name is required"
-	MsgProductPriceMustBeGreaterThanZero    = "Product price must be greater than zero"
-	MsgProductVerifiedSuccessfully          = "Product verified successfully"
+	MsgErrorSigningProductOffer        = "Error signing product offer"
+	MsgProductOfferCreatedSuccessfully = "Product offer created successfully"
+	MsgMissingProductOfferToken        = "Missing or empty product offer token"
+	MsgInvalidProductOfferToken        = "The product offer is invalid or has expired"
+	MsgProductOfferNotFound            = "Product offer not found or has been revoked"
+	MsgProductOfferExhausted           = "The product offer has reached its maximum number of uses"
+	MsgProductOfferEmailMismatch       = "The product offer is reserved for a different email address"
+	MsgInvalidProductOfferExpiry       = "Invalid expires_in_hours: must be between 1 and 720"
+	MsgInvalidProductOfferMaxUses      = "Invalid max_uses: must be zero or a positive number"
+	MsgProductVerifiedSuccessfully     = "Product verified successfully"
+
+	// Customer Data Privacy Messages
+	MsgInvalidCustomerID          = "Invalid customer ID."
+	MsgCustomerNotFound           = "Customer not found."
+	MsgCustomerAlreadyErased      = "Customer data has already been erased."
+	MsgFailedToExportCustomerData = "Failed to export customer data."
+	MsgFailedToEraseCustomerData  = "Failed to erase customer data."
+	MsgCustomerErasedSuccessfully = "Customer personal data erased successfully."
 )
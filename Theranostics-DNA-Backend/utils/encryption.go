@@ -7,10 +7,47 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"theransticslabs/m/config"
 )
 
+const (
+	// LegacyKeyID identifies the EncryptionKey1/EncryptionKey2 pair. Ciphertexts written
+	// before key IDs were introduced carry no prefix and are decrypted with this pair.
+	LegacyKeyID = config.LegacyKeyID
+
+	// keyIDSeparator separates the key ID from the ciphertext. It is not part of the
+	// base64 alphabet, so it can never appear in a legacy ciphertext.
+	keyIDSeparator = "$"
+)
+
+// encryptionKeyPair holds the two base64-encoded keys used for double encryption.
+type encryptionKeyPair = config.EncryptionKeyPair
+
+// loadKeyring returns every configured key pair indexed by key ID.
+func loadKeyring() (map[string]encryptionKeyPair, error) {
+	return config.AppConfig.EncryptionKeys()
+}
+
+// ActiveKeyID returns the ID of the key pair used for new ciphertexts.
+func ActiveKeyID() string {
+	if id := strings.TrimSpace(config.AppConfig.EncryptionActiveKeyID); id != "" {
+		return id
+	}
+	return LegacyKeyID
+}
+
+// CiphertextKeyID returns the key ID a ciphertext was written with and whether the
+// ciphertext carries an explicit key ID.
+func CiphertextKeyID(ciphertext string) (string, bool) {
+	if i := strings.Index(ciphertext, keyIDSeparator); i > 0 && config.KeyIDPattern.MatchString(ciphertext[:i]) {
+		return ciphertext[:i], true
+	}
+	return LegacyKeyID, false
+}
+
 // getCipher creates an AEAD cipher using the provided base64-encoded key.
 func getCipher(key string) (cipher.AEAD, error) {
 	keyBytes, err := base64.StdEncoding.DecodeString(key)
@@ -31,10 +68,53 @@ func getCipher(key string) (cipher.AEAD, error) {
 	return aead, nil
 }
 
-// Encrypt performs double encryption on the plaintext using two keys.
+// Encrypt performs double encryption on the plaintext using the active key pair and
+// prefixes the result with the key ID.
 func Encrypt(plaintext string) (string, error) {
-	// First encryption with EncryptionKey1
-	aead1, err := getCipher(config.AppConfig.EncryptionKey1)
+	keyring, err := loadKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	keyID := ActiveKeyID()
+	keyPair, ok := keyring[keyID]
+	if !ok {
+		return "", fmt.Errorf("active encryption key id %q is not in the keyring", keyID)
+	}
+
+	ciphertext, err := encryptWithKeyPair(plaintext, keyPair)
+	if err != nil {
+		return "", err
+	}
+
+	return keyID + keyIDSeparator + ciphertext, nil
+}
+
+// Decrypt reverses the double encryption process using the key pair named in the
+// ciphertext, falling back to the legacy pair for unprefixed ciphertexts.
+func Decrypt(ciphertext string) (string, error) {
+	keyring, err := loadKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	keyID, versioned := CiphertextKeyID(ciphertext)
+	if versioned {
+		ciphertext = ciphertext[len(keyID)+len(keyIDSeparator):]
+	}
+
+	keyPair, ok := keyring[keyID]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key id %q", keyID)
+	}
+
+	return decryptWithKeyPair(ciphertext, keyPair)
+}
+
+// encryptWithKeyPair encrypts with Key1, then encrypts the result again with Key2.
+func encryptWithKeyPair(plaintext string, keyPair encryptionKeyPair) (string, error) {
+	// First encryption with Key1
+	aead1, err := getCipher(keyPair.Key1)
 	if err != nil {
 		return "", err
 	}
@@ -48,8 +128,8 @@ func Encrypt(plaintext string) (string, error) {
 	encrypted1 := append(nonce1, ciphertext1...)
 	encrypted1B64 := base64.StdEncoding.EncodeToString(encrypted1)
 
-	// Second encryption with EncryptionKey2
-	aead2, err := getCipher(config.AppConfig.EncryptionKey2)
+	// Second encryption with Key2
+	aead2, err := getCipher(keyPair.Key2)
 	if err != nil {
 		return "", err
 	}
@@ -66,16 +146,16 @@ func Encrypt(plaintext string) (string, error) {
 	return encrypted2B64, nil
 }
 
-// Decrypt reverses the double encryption process to retrieve the plaintext.
-func Decrypt(ciphertext string) (string, error) {
+// decryptWithKeyPair decrypts with Key2, then decrypts the inner ciphertext with Key1.
+func decryptWithKeyPair(ciphertext string, keyPair encryptionKeyPair) (string, error) {
 	// Base64 decode
 	encrypted2, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", err
 	}
 
-	// First decryption with EncryptionKey2
-	aead2, err := getCipher(config.AppConfig.EncryptionKey2)
+	// First decryption with Key2
+	aead2, err := getCipher(keyPair.Key2)
 	if err != nil {
 		return "", err
 	}
@@ -96,8 +176,8 @@ func Decrypt(ciphertext string) (string, error) {
 		return "", err
 	}
 
-	// Second decryption with EncryptionKey1
-	aead1, err := getCipher(config.AppConfig.EncryptionKey1)
+	// Second decryption with Key1
+	aead1, err := getCipher(keyPair.Key1)
 	if err != nil {
 		return "", err
 	}
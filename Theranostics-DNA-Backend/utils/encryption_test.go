@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"theransticslabs/m/config"
+)
+
+func TestCiphertextKeyID(t *testing.T) {
+	tests := []struct {
+		ciphertext string
+		keyID      string
+		versioned  bool
+	}{
+		{"1$YWJj", "1", true},
+		{"2026-q3$YWJj", "2026-q3", true},
+		{"0$YWJj", "0", true},
+		{"YWJjZGVm", LegacyKeyID, false},
+		{"", LegacyKeyID, false},
+		{"$YWJj", LegacyKeyID, false},
+		{"bad id$YWJj", LegacyKeyID, false},
+		{"seventeen-chars-x$YWJj", LegacyKeyID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ciphertext, func(t *testing.T) {
+			keyID, versioned := CiphertextKeyID(tt.ciphertext)
+			if keyID != tt.keyID || versioned != tt.versioned {
+				t.Errorf("CiphertextKeyID = %q, %v, want %q, %v", keyID, versioned, tt.keyID, tt.versioned)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	tests := []struct {
+		name        string
+		activeKeyID string
+		wantPrefix  string
+		plaintext   string
+	}{
+		{"legacy pair by default", "", "0$", "jane@example.com"},
+		{"keyring pair", "1", "1$", "jane@example.com"},
+		{"another keyring pair", "2", "2$", "12 High Street\nLeeds"},
+		{"empty plaintext", "1", "1$", ""},
+		{"unicode", "2", "2$", "Zoë Ångström"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestKeys(t, tt.activeKeyID)
+			ciphertext, err := Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(ciphertext, tt.wantPrefix) {
+				t.Errorf("ciphertext %q doesn't start with %q", ciphertext, tt.wantPrefix)
+			}
+			plaintext, err := Decrypt(ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plaintext != tt.plaintext {
+				t.Errorf("Decrypt = %q, want %q", plaintext, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptAfterRotation(t *testing.T) {
+	useTestKeys(t, "1")
+	onKey1, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := encryptWithKeyPair("secret", config.EncryptionKeyPair{
+		Key1: config.AppConfig.EncryptionKey1,
+		Key2: config.AppConfig.EncryptionKey2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.AppConfig.EncryptionActiveKeyID = "2"
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		wantErr    bool
+	}{
+		{"previous key still in the keyring", onKey1, false},
+		{"unprefixed legacy ciphertext", legacy, false},
+		{"unknown key", "9$" + strings.TrimPrefix(onKey1, "1$"), true},
+		{"wrong key", "2$" + strings.TrimPrefix(onKey1, "1$"), true},
+		{"not base64", "1$not base64!", true},
+		{"truncated", onKey1[:10], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := Decrypt(tt.ciphertext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && plaintext != "secret" {
+				t.Errorf("Decrypt = %q, want %q", plaintext, "secret")
+			}
+		})
+	}
+}
+
+func TestEncryptWithActiveKeyMissing(t *testing.T) {
+	useTestKeys(t, "7")
+	if _, err := Encrypt("secret"); err == nil {
+		t.Error("Encrypt succeeded with an active key ID that isn't in the keyring")
+	}
+}
@@ -0,0 +1,278 @@
+// utils/invoice.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// Currency is the currency orders are charged and invoiced in.
+const Currency = "USD"
+
+// invoiceDir is where invoice PDFs are written, relative to the public directory they are
+// served from.
+const invoiceDir = "invoices"
+
+// IssueInvoice numbers the invoice for a completed payment, stores its snapshot of the sale and
+// writes its PDF. Numbers run from 1 in each series and year without gaps: the counter is taken
+// in tx, so call it with the transaction that records the payment and the number is only used
+// if that commits. Concurrent payments wait for each other here until then. The PDF is written
+// before the commit so the confirmation email can attach it; if tx doesn't commit, the caller
+// must delete it with RemoveInvoicePDF.
+func IssueInvoice(tx *gorm.DB, payment *models.Payment, order *models.Order, customer *models.Customer, issuedAt time.Time) (*models.Invoice, error) {
+	series, year := config.AppConfig.InvoiceSeries, issuedAt.UTC().Year()
+
+	var sequence int
+	if err := tx.Raw(`INSERT INTO "invoice_sequences" ("series", "year", "last_number") VALUES (?, ?, 1)
+		ON CONFLICT ("series", "year") DO UPDATE SET "last_number" = "invoice_sequences"."last_number" + 1
+		RETURNING "last_number"`, series, year).Scan(&sequence).Error; err != nil {
+		return nil, err
+	}
+	number := invoiceNumber(series, year, sequence)
+
+	// The PDF is served publicly, so its name must not be guessable from the number
+	token, err := GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prices include tax, so the tax is worked out of the total
+	taxRate := config.AppConfig.InvoiceTaxRate
+	taxAmount := roundCents(payment.Amount * taxRate / (100 + taxRate))
+
+	cfg := config.AppConfig
+	invoice := models.Invoice{
+		PaymentID:     payment.ID,
+		InvoiceLink:   invoiceDir + "/" + fmt.Sprintf("%s_%s.pdf", number, token),
+		Price:         payment.Amount,
+		InvoiceID:     number,
+		Series:        series,
+		Year:          year,
+		Sequence:      sequence,
+		IssuedAt:      issuedAt,
+		Currency:      Currency,
+		SellerName:    cfg.SellerName,
+		SellerAddress: strings.Join(addressLines(cfg.SellerStreetAddress, cfg.SellerTownCity, cfg.SellerRegion, cfg.SellerPostcode, cfg.SellerCountry), "\n"),
+		SellerTaxID:   cfg.SellerTaxID,
+		BillingName:   strings.TrimSpace(customer.FirstName + " " + customer.LastName),
+		BillingAddress: strings.Join(addressLines(customer.StreetAddress, customer.TownCity, customer.Region, customer.Postcode,
+			customer.Country), "\n"),
+		BillingEmail: customer.Email,
+		LineItems: models.InvoiceLineItems{{
+			Description: order.ProductName,
+			Quantity:    order.Quantity,
+			UnitPrice:   order.ProductPrice,
+			Amount:      payment.Amount,
+		}},
+		Subtotal:         roundCents(payment.Amount - taxAmount),
+		TaxRate:          taxRate,
+		TaxAmount:        taxAmount,
+		PaymentReference: payment.TransactionID,
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, err
+	}
+
+	if _, err := SaveInvoicePDF(&invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// SaveInvoicePDF renders the invoice from its stored snapshot to its InvoiceLink under the public
+// directory and returns the file path. Regenerating an invoice overwrites the same file with the
+// same content, so a lost PDF can be rebuilt at any time.
+func SaveInvoicePDF(invoice *models.Invoice) (string, error) {
+	path := filepath.Join("public", filepath.FromSlash(invoice.InvoiceLink))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	if err := WriteInvoicePDF(file, invoice); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// RemoveInvoicePDF deletes the PDF of an invoice whose transaction was rolled back, so no file is
+// left for a number that was never issued.
+func RemoveInvoicePDF(invoice *models.Invoice) {
+	path := filepath.Join("public", filepath.FromSlash(invoice.InvoiceLink))
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("Failed to delete the PDF of an invoice that was not issued", "path", path, "error", err)
+	}
+}
+
+// invoiceNumber formats an invoice number such as "INV-2026-000042". Sequences past a million
+// keep all their digits, so numbers stay unique.
+func invoiceNumber(series string, year, sequence int) string {
+	return fmt.Sprintf("%s-%d-%06d", series, year, sequence)
+}
+
+// WriteInvoicePDF renders the invoice as an A4 PDF. Invoices issued before numbering have no
+// seller or billing details, and are rendered without them.
+func WriteInvoicePDF(w io.Writer, invoice *models.Invoice) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	// Fixed dates and a sorted catalog make the same invoice render to the same bytes
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(invoice.IssuedAt)
+	pdf.SetModificationDate(invoice.IssuedAt)
+	pdf.SetTitle("Invoice "+invoice.InvoiceID, false)
+	pdf.AddPage()
+
+	// Core fonts are Windows-1252, which covers the accented letters of our customers' languages
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width, _ := pdf.GetPageSize()
+	content := width - 40
+
+	// Seller on the left, invoice details on the right
+	top := pdf.GetY()
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(content/2, 7, tr(invoice.SellerName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	for _, line := range splitLines(invoice.SellerAddress) {
+		pdf.CellFormat(content/2, 5, tr(line), "", 1, "L", false, 0, "")
+	}
+	if invoice.SellerTaxID != "" {
+		pdf.CellFormat(content/2, 5, tr("Tax ID: "+invoice.SellerTaxID), "", 1, "L", false, 0, "")
+	}
+	sellerBottom := pdf.GetY()
+
+	pdf.SetXY(20+content/2, top)
+	pdf.SetFont("Arial", "B", 22)
+	pdf.CellFormat(content/2, 10, "INVOICE", "", 2, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	for _, line := range []string{
+		"Invoice number: " + invoice.InvoiceID,
+		"Issue date: " + invoice.IssuedAt.Format("2 January 2006"),
+		"Currency: " + invoice.Currency,
+	} {
+		pdf.CellFormat(content/2, 5, tr(line), "", 2, "R", false, 0, "")
+	}
+	pdf.SetXY(20, math.Max(sellerBottom, pdf.GetY())+10)
+
+	if invoice.BillingName != "" {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(content, 5, "Bill to", "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		lines := append([]string{invoice.BillingName}, splitLines(invoice.BillingAddress)...)
+		if invoice.BillingEmail != "" {
+			lines = append(lines, invoice.BillingEmail)
+		}
+		for _, line := range lines {
+			pdf.CellFormat(content, 5, tr(line), "", 1, "L", false, 0, "")
+		}
+		pdf.Ln(10)
+	}
+
+	// Line items
+	columns := []struct {
+		title string
+		width float64
+		align string
+	}{
+		{"Description", content - 90, "L"},
+		{"Quantity", 20, "R"},
+		{"Unit price", 35, "R"},
+		{"Amount", 35, "R"},
+	}
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for _, column := range columns {
+		pdf.CellFormat(column.width, 8, column.title, "B", 0, column.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Arial", "", 10)
+	for _, item := range invoice.LineItems {
+		values := []string{
+			item.Description,
+			fmt.Sprintf("%d", item.Quantity),
+			formatMoney(item.UnitPrice),
+			formatMoney(item.Amount),
+		}
+		for i, column := range columns {
+			pdf.CellFormat(column.width, 8, tr(values[i]), "B", 0, column.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// Totals, under the amount column
+	totals := []struct {
+		label  string
+		amount float64
+		bold   bool
+	}{
+		{"Subtotal excluding tax", invoice.Subtotal, false},
+		{fmt.Sprintf("Tax (%g%%)", invoice.TaxRate), invoice.TaxAmount, false},
+		{"Total " + invoice.Currency, invoice.Price, true},
+	}
+	for _, total := range totals {
+		style := ""
+		if total.bold {
+			style = "B"
+		}
+		pdf.SetFont("Arial", style, 10)
+		pdf.CellFormat(content-35, 7, total.label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 7, formatMoney(total.amount), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(content, 5, "Prices include tax. Paid in full.", "", 1, "L", false, 0, "")
+	if invoice.PaymentReference != "" {
+		pdf.CellFormat(content, 5, tr("Payment reference: "+invoice.PaymentReference), "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// roundCents rounds an amount to whole cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// formatMoney formats an amount with two decimals and thousands separators, e.g. 1,234.50.
+func formatMoney(amount float64) string {
+	s := fmt.Sprintf("%.2f", math.Abs(amount))
+	whole, cents := s[:len(s)-3], s[len(s)-3:]
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if amount < 0 {
+		whole = "-" + whole
+	}
+	return whole + cents
+}
+
+// splitLines splits a stored multi-line value, dropping empty lines.
+func splitLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"theransticslabs/m/models"
+)
+
+func TestRoundCents(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   float64
+	}{
+		{0, 0},
+		{1.234, 1.23},
+		{1.235, 1.24},
+		{1.2449, 1.24},
+		{99.999, 100},
+		{-1.236, -1.24},
+		{149.99 * 20 / 120, 25},
+		{59.97 * 7.5 / 107.5, 4.18},
+	}
+	for _, tt := range tests {
+		if got := roundCents(tt.amount); got != tt.want {
+			t.Errorf("roundCents(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "5.00"},
+		{12.5, "12.50"},
+		{999.99, "999.99"},
+		{1000, "1,000.00"},
+		{1234.5, "1,234.50"},
+		{123456.78, "123,456.78"},
+		{1234567.891, "1,234,567.89"},
+		{-1234.5, "-1,234.50"},
+		{-12, "-12.00"},
+	}
+	for _, tt := range tests {
+		if got := formatMoney(tt.amount); got != tt.want {
+			t.Errorf("formatMoney(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceNumber(t *testing.T) {
+	tests := []struct {
+		series   string
+		year     int
+		sequence int
+		want     string
+	}{
+		{"INV", 2026, 1, "INV-2026-000001"},
+		{"INV", 2026, 42, "INV-2026-000042"},
+		{"INV", 2027, 1, "INV-2027-000001"},
+		{"CN", 2026, 999999, "CN-2026-999999"},
+		{"CN", 2026, 1000000, "CN-2026-1000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := invoiceNumber(tt.series, tt.year, tt.sequence); got != tt.want {
+				t.Errorf("invoiceNumber(%q, %d, %d) = %q, want %q", tt.series, tt.year, tt.sequence, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"12 High Street", []string{"12 High Street"}},
+		{"12 High Street\nLeeds\nLS1 1AA", []string{"12 High Street", "Leeds", "LS1 1AA"}},
+		{" 12 High Street \n\n  \nLeeds\n", []string{"12 High Street", "Leeds"}},
+	}
+	for _, tt := range tests {
+		if got := splitLines(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLines(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteInvoicePDF(t *testing.T) {
+	issuedAt := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		invoice models.Invoice
+	}{
+		{"numbered", models.Invoice{
+			InvoiceID:      "INV-2026-000042",
+			Series:         "INV",
+			Year:           2026,
+			Sequence:       42,
+			IssuedAt:       issuedAt,
+			Currency:       Currency,
+			SellerName:     "Theranostics Labs",
+			SellerAddress:  "1 Lab Way\nCambridge",
+			SellerTaxID:    "GB123456789",
+			BillingName:    "Zoë Ångström",
+			BillingAddress: "12 High Street\nLeeds",
+			BillingEmail:   "zoe@example.com",
+			LineItems:      models.InvoiceLineItems{{Description: "DNA kit", Quantity: 2, UnitPrice: 74.995, Amount: 149.99}},
+			Price:          149.99,
+			Subtotal:       124.99,
+			TaxRate:        20,
+			TaxAmount:      25,
+		}},
+		{"issued before numbering", models.Invoice{
+			InvoiceID: "17",
+			IssuedAt:  issuedAt,
+			Price:     59.97,
+			Subtotal:  59.97,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteInvoicePDF(&buf, &tt.invoice); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+				t.Error("output is not a PDF")
+			}
+
+			// The PDF is rebuilt from the row, so the same invoice must render identically
+			var again bytes.Buffer
+			if err := WriteInvoicePDF(&again, &tt.invoice); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+				t.Error("rendering the same invoice twice gave different PDFs")
+			}
+		})
+	}
+}
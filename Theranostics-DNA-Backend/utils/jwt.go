@@ -11,9 +11,8 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// GenerateJWT generates a JWT token for the given user and encrypts the user ID.
-func GenerateJWT(user models.User) (string, error) {
-	config.LoadEnv() // Ensure environment variables are loaded
+// GenerateJWT generates a short-lived access token for the given user and session and encrypts the user ID.
+func GenerateJWT(user models.User, sessionID uint) (string, error) {
 	cfg := config.AppConfig
 	jwtSecret := []byte(cfg.JWTSecret) // Access AppConfig directly
 
@@ -29,7 +28,8 @@ func GenerateJWT(user models.User) (string, error) {
 	// Define token claims, using the encrypted user ID
 	claims := jwt.MapClaims{
 		"id":  encryptedUserID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(), // Token expires after 24 hours
+		"sid": strconv.FormatUint(uint64(sessionID), 10),
+		"exp": time.Now().Add(AccessTokenTTL).Unix(),
 		"iat": time.Now().Unix(),
 	}
 
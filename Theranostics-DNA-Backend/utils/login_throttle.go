@@ -0,0 +1,160 @@
+// utils/login_throttle.go
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ThrottleScopeLoginAccount = "login:account"
+	ThrottleScopeLoginIP      = "login:ip"
+	ThrottleScopeResetAccount = "reset:account"
+	ThrottleScopeResetIP      = "reset:ip"
+
+	LockEventLocked   = "locked"
+	LockEventUnlocked = "unlocked"
+)
+
+// ThrottlePolicy controls how failed attempts for a key are slowed down and locked out.
+type ThrottlePolicy struct {
+	Scope        string
+	FreeAttempts int           // Failures allowed before delays start
+	MaxAttempts  int           // Failures that trigger a lockout
+	BaseDelay    time.Duration // Delay after the first failure past FreeAttempts, doubled for each further failure
+	MaxDelay     time.Duration
+	LockDuration time.Duration
+	Window       time.Duration // Failures older than this are forgotten
+}
+
+var (
+	LoginAccountPolicy = ThrottlePolicy{
+		Scope: ThrottleScopeLoginAccount, FreeAttempts: 3, MaxAttempts: 10,
+		BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockDuration: 30 * time.Minute, Window: time.Hour,
+	}
+	LoginIPPolicy = ThrottlePolicy{
+		Scope: ThrottleScopeLoginIP, FreeAttempts: 10, MaxAttempts: 50,
+		BaseDelay: time.Second, MaxDelay: time.Minute, LockDuration: time.Hour, Window: time.Hour,
+	}
+	// Every password reset request counts as an attempt, so these limit reset emails per account and IP.
+	PasswordResetAccountPolicy = ThrottlePolicy{
+		Scope: ThrottleScopeResetAccount, FreeAttempts: 2, MaxAttempts: 5,
+		BaseDelay: time.Minute, MaxDelay: 15 * time.Minute, LockDuration: time.Hour, Window: time.Hour,
+	}
+	PasswordResetIPPolicy = ThrottlePolicy{
+		Scope: ThrottleScopeResetIP, FreeAttempts: 5, MaxAttempts: 20,
+		BaseDelay: 10 * time.Second, MaxDelay: 5 * time.Minute, LockDuration: time.Hour, Window: time.Hour,
+	}
+)
+
+// ThrottleStatus reports whether a key may attempt again and, if not, for how long it must wait.
+type ThrottleStatus struct {
+	Blocked    bool
+	Locked     bool // Blocked by a lockout rather than a progressive delay
+	RetryAfter time.Duration
+}
+
+// ThrottleKey builds the storage key for a subject (email or IP). Subjects are hashed so
+// arbitrary submitted emails are never stored.
+func (p ThrottlePolicy) ThrottleKey(subject string) string {
+	return p.Scope + ":" + HashToken(subject)
+}
+
+// CheckThrottle returns whether the subject is currently delayed or locked out.
+func CheckThrottle(db *gorm.DB, policy ThrottlePolicy, subject string) (ThrottleStatus, error) {
+	var throttle models.LoginThrottle
+	if err := db.Where("key = ?", policy.ThrottleKey(subject)).First(&throttle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ThrottleStatus{}, nil
+		}
+		return ThrottleStatus{}, err
+	}
+	return throttleStatus(throttle, time.Now().UTC()), nil
+}
+
+// RecordFailedAttempt counts a failed attempt for the subject and applies the policy's delay
+// or lockout. It reports whether this attempt caused a new lockout.
+func RecordFailedAttempt(db *gorm.DB, policy ThrottlePolicy, subject string) (ThrottleStatus, bool, error) {
+	key := policy.ThrottleKey(subject)
+	now := time.Now().UTC()
+	var status ThrottleStatus
+	var newlyLocked bool
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it so concurrent failures are all counted
+		seed := models.LoginThrottle{Key: key, FirstFailureAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+			return err
+		}
+
+		var throttle models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		newlyLocked = policy.recordFailure(&throttle, now)
+		if err := tx.Save(&throttle).Error; err != nil {
+			return err
+		}
+		status = throttleStatus(throttle, now)
+		return nil
+	})
+
+	return status, newlyLocked, err
+}
+
+// ResetThrottle clears the failed attempts recorded for the subject.
+func ResetThrottle(db *gorm.DB, policy ThrottlePolicy, subject string) error {
+	return db.Where("key = ?", policy.ThrottleKey(subject)).Delete(&models.LoginThrottle{}).Error
+}
+
+// RecordLockEvent stores a lockout or unlock event.
+func RecordLockEvent(db *gorm.DB, event models.AccountLockEvent) error {
+	return db.Create(&event).Error
+}
+
+// recordFailure counts a failed attempt made at now against the throttle, setting its delay or
+// lockout, and reports whether the attempt caused a new lockout.
+func (p ThrottlePolicy) recordFailure(throttle *models.LoginThrottle, now time.Time) bool {
+	// Start a new window once the previous failures and any lockout have aged out
+	lockExpired := throttle.LockedUntil == nil || now.After(*throttle.LockedUntil)
+	if lockExpired && now.Sub(throttle.FirstFailureAt) > p.Window {
+		throttle.Failures = 0
+		throttle.FirstFailureAt = now
+		throttle.LockedUntil = nil
+	}
+
+	newlyLocked := false
+	throttle.Failures++
+	throttle.NextAttemptAt = nil
+	if throttle.Failures >= p.MaxAttempts {
+		if throttle.LockedUntil == nil || now.After(*throttle.LockedUntil) {
+			newlyLocked = true
+		}
+		lockedUntil := now.Add(p.LockDuration)
+		throttle.LockedUntil = &lockedUntil
+	} else if throttle.Failures > p.FreeAttempts {
+		delay := p.BaseDelay << uint(throttle.Failures-p.FreeAttempts-1)
+		if delay > p.MaxDelay || delay <= 0 {
+			delay = p.MaxDelay
+		}
+		nextAttemptAt := now.Add(delay)
+		throttle.NextAttemptAt = &nextAttemptAt
+	}
+	return newlyLocked
+}
+
+func throttleStatus(throttle models.LoginThrottle, now time.Time) ThrottleStatus {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return ThrottleStatus{Blocked: true, Locked: true, RetryAfter: throttle.LockedUntil.Sub(now)}
+	}
+	if throttle.NextAttemptAt != nil && now.Before(*throttle.NextAttemptAt) {
+		return ThrottleStatus{Blocked: true, RetryAfter: throttle.NextAttemptAt.Sub(now)}
+	}
+	return ThrottleStatus{}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"theransticslabs/m/models"
+)
+
+var testThrottlePolicy = ThrottlePolicy{
+	Scope: "test", FreeAttempts: 2, MaxAttempts: 6,
+	BaseDelay: time.Second, MaxDelay: 3 * time.Second, LockDuration: 10 * time.Minute, Window: time.Hour,
+}
+
+func TestRecordFailure(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var throttle models.LoginThrottle
+	throttle.FirstFailureAt = now
+
+	// Each step is one more failure on the same throttle, at the given offset from now
+	tests := []struct {
+		name      string
+		at        time.Duration
+		failures  int
+		status    ThrottleStatus
+		newlyLock bool
+	}{
+		{"first failure is free", 0, 1, ThrottleStatus{}, false},
+		{"last free failure", 0, 2, ThrottleStatus{}, false},
+		{"first delay", 0, 3, ThrottleStatus{Blocked: true, RetryAfter: time.Second}, false},
+		{"delay doubles", 0, 4, ThrottleStatus{Blocked: true, RetryAfter: 2 * time.Second}, false},
+		{"delay is capped", 0, 5, ThrottleStatus{Blocked: true, RetryAfter: 3 * time.Second}, false},
+		{"lockout", 0, 6, ThrottleStatus{Blocked: true, Locked: true, RetryAfter: 10 * time.Minute}, true},
+		{"failure while locked extends it", time.Minute, 7, ThrottleStatus{Blocked: true, Locked: true, RetryAfter: 10 * time.Minute}, false},
+		{"failure after the lock expires relocks within the window", 20 * time.Minute, 8, ThrottleStatus{Blocked: true, Locked: true, RetryAfter: 10 * time.Minute}, true},
+		{"window and lock aged out start over", 2 * time.Hour, 1, ThrottleStatus{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := now.Add(tt.at)
+			newlyLocked := testThrottlePolicy.recordFailure(&throttle, at)
+			if throttle.Failures != tt.failures {
+				t.Errorf("Failures = %d, want %d", throttle.Failures, tt.failures)
+			}
+			if newlyLocked != tt.newlyLock {
+				t.Errorf("newly locked = %v, want %v", newlyLocked, tt.newlyLock)
+			}
+			if got := throttleStatus(throttle, at); got != tt.status {
+				t.Errorf("status = %+v, want %+v", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestThrottleStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(d)
+		return &ts
+	}
+
+	tests := []struct {
+		name     string
+		throttle models.LoginThrottle
+		want     ThrottleStatus
+	}{
+		{"no limits", models.LoginThrottle{Failures: 1}, ThrottleStatus{}},
+		{"delayed", models.LoginThrottle{NextAttemptAt: at(5 * time.Second)}, ThrottleStatus{Blocked: true, RetryAfter: 5 * time.Second}},
+		{"delay passed", models.LoginThrottle{NextAttemptAt: at(-time.Second)}, ThrottleStatus{}},
+		{"locked", models.LoginThrottle{LockedUntil: at(time.Minute)}, ThrottleStatus{Blocked: true, Locked: true, RetryAfter: time.Minute}},
+		{"lock outranks delay", models.LoginThrottle{LockedUntil: at(time.Minute), NextAttemptAt: at(time.Hour)}, ThrottleStatus{Blocked: true, Locked: true, RetryAfter: time.Minute}},
+		{"lock expired", models.LoginThrottle{LockedUntil: at(-time.Minute)}, ThrottleStatus{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := throttleStatus(tt.throttle, now); got != tt.want {
+				t.Errorf("throttleStatus = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleKey(t *testing.T) {
+	key := LoginAccountPolicy.ThrottleKey("jane@example.com")
+	if !strings.HasPrefix(key, ThrottleScopeLoginAccount+":") {
+		t.Errorf("key %q is not scoped to %q", key, ThrottleScopeLoginAccount)
+	}
+	if strings.Contains(key, "jane") {
+		t.Errorf("key %q stores the submitted email", key)
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"same subject and scope", LoginAccountPolicy.ThrottleKey("jane@example.com"), key, true},
+		{"other subject", LoginAccountPolicy.ThrottleKey("john@example.com"), key, false},
+		{"other scope", PasswordResetAccountPolicy.ThrottleKey("jane@example.com"), key, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a == tt.b; same != tt.same {
+				t.Errorf("%q == %q is %v, want %v", tt.a, tt.b, same, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// utils/outbox.go
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/emails"
+	"theransticslabs/m/mailer"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Email outbox statuses.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+const (
+	// OutboxMaxAttempts is how many times an email is tried before it is dead-lettered.
+	OutboxMaxAttempts = 8
+
+	outboxBaseDelay = 30 * time.Second
+	outboxMaxDelay  = time.Hour
+	outboxBatchSize = 50
+
+	// outboxClaimLease hides a claimed email from other workers while it is sent. If the worker
+	// dies part way through, the email becomes due again once the lease runs out.
+	outboxClaimLease = 5 * time.Minute
+)
+
+// QueueEmail adds an email with an HTML body to the outbox. Call it with the transaction that makes
+// the change the email reports, so the email is only sent if the change is committed.
+func QueueEmail(tx *gorm.DB, recipients []string, subject, body string, attachments ...models.OutboxAttachment) error {
+	return tx.Create(&models.EmailOutbox{
+		Recipients:    strings.Join(recipients, ","),
+		Subject:       subject,
+		Body:          body,
+		Attachments:   attachments,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// OutboxBackoff returns how long to wait before retrying an email that has failed attempts times:
+// 30s, 1m, 2m and so on, capped at an hour.
+func OutboxBackoff(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		delay = outboxMaxDelay
+	}
+	return delay
+}
+
+// outboxEntry points at the delivery state every outbox table shares.
+type outboxEntry struct {
+	ID            uint
+	Status        *string
+	Attempts      *int
+	NextAttemptAt *time.Time
+	LastError     *string
+	SentAt        **time.Time
+}
+
+// outboxQueue describes an outbox table to processOutboxQueue.
+type outboxQueue[T any] struct {
+	name  string // Names a row in log messages, e.g. "Email"
+	idKey string // Log attribute holding the row ID
+	entry func(row *T) outboxEntry
+	// prepare, when set, runs once on the claimed rows before any is sent.
+	prepare func(db *gorm.DB, claimed []T) error
+	send    func(ctx context.Context, row *T) error
+	// columns are the row's own columns send may change, saved along with its delivery state.
+	columns []string
+	// finished, when set, runs once a row has been sent or dead-lettered and saved.
+	finished func(row *T)
+}
+
+// errOutboxPermanent marks a send failure that retrying can't fix, so the row is dead-lettered at once.
+type errOutboxPermanent struct{ err error }
+
+func (e errOutboxPermanent) Error() string { return e.err.Error() }
+func (e errOutboxPermanent) Unwrap() error { return e.err }
+
+// processOutboxQueue sends up to batchSize due rows of the queue and returns how many were sent.
+// Rows are claimed with SKIP LOCKED, so several instances can run the worker at once. A failed
+// row is retried with OutboxBackoff until it has been tried OutboxMaxAttempts times.
+func processOutboxQueue[T any](db *gorm.DB, batchSize int, queue outboxQueue[T]) (int, error) {
+	var claimed []T
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, now).
+			Order("next_attempt_at").Limit(batchSize).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(claimed))
+		for i := range claimed {
+			ids[i] = queue.entry(&claimed[i]).ID
+		}
+		return tx.Model(new(T)).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(outboxClaimLease),
+		}).Error
+	})
+	if err != nil || len(claimed) == 0 {
+		return 0, err
+	}
+	if queue.prepare != nil {
+		if err := queue.prepare(db, claimed); err != nil {
+			return 0, err
+		}
+	}
+
+	columns := append([]string{"Status", "NextAttemptAt", "LastError", "SentAt"}, queue.columns...)
+	sent := 0
+	for i := range claimed {
+		// Stop on shutdown; the remaining claims are retried once their lease runs out
+		if err := db.Statement.Context.Err(); err != nil {
+			return sent, err
+		}
+
+		row := &claimed[i]
+		entry := queue.entry(row)
+		*entry.Attempts++
+		sendErr := queue.send(context.WithoutCancel(db.Statement.Context), row)
+		var permanent errOutboxPermanent
+		switch {
+		case sendErr == nil:
+			now := time.Now()
+			*entry.Status = OutboxStatusSent
+			*entry.SentAt = &now
+			*entry.LastError = ""
+			sent++
+		case errors.As(sendErr, &permanent) || *entry.Attempts >= OutboxMaxAttempts:
+			*entry.Status = OutboxStatusDead
+			*entry.LastError = sendErr.Error()
+			slog.Error(queue.name+" moved to the dead letter state", queue.idKey, entry.ID, "attempts", *entry.Attempts, "error", sendErr)
+		default:
+			*entry.NextAttemptAt = time.Now().Add(OutboxBackoff(*entry.Attempts))
+			*entry.LastError = sendErr.Error()
+		}
+
+		// Detached from the worker's context so a send that went through is always recorded
+		result := db.WithContext(context.WithoutCancel(db.Statement.Context)).Model(row).
+			Select(columns).Updates(row)
+		if result.Error != nil {
+			return sent, result.Error
+		}
+		if *entry.Status != OutboxStatusPending && queue.finished != nil {
+			queue.finished(row)
+		}
+	}
+	return sent, nil
+}
+
+// ProcessOutbox sends up to batchSize due emails and returns how many were sent.
+func ProcessOutbox(db *gorm.DB, batchSize int) (int, error) {
+	return processOutboxQueue(db, batchSize, outboxQueue[models.EmailOutbox]{
+		name:  "Email",
+		idKey: "email_id",
+		entry: func(email *models.EmailOutbox) outboxEntry {
+			return outboxEntry{email.ID, &email.Status, &email.Attempts, &email.NextAttemptAt, &email.LastError, &email.SentAt}
+		},
+		send:     sendOutboxEmail,
+		finished: removeTemporaryAttachments,
+	})
+}
+
+// removeTemporaryAttachments deletes the files rendered just for this email once it no longer
+// needs them. Dead emails resent later go out without them.
+func removeTemporaryAttachments(email *models.EmailOutbox) {
+	for _, attachment := range email.Attachments {
+		if !attachment.Temporary {
+			continue
+		}
+		if err := os.Remove(attachment.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to delete a temporary email attachment", "email_id", email.ID, "path", attachment.Path, "error", err)
+		}
+	}
+}
+
+// sendOutboxEmail sends a queued email, reading its attachments from disk and embedding the
+// template images its body references. An attachment whose file is gone is left out rather than
+// holding the email back, since retrying won't bring it back. The mailer bounds the send, so it
+// is not cut short by shutdown.
+func sendOutboxEmail(ctx context.Context, email *models.EmailOutbox) error {
+	msg := &mailer.Message{
+		To:      strings.Split(email.Recipients, ","),
+		Subject: email.Subject,
+		HTML:    email.Body,
+	}
+	for _, attachment := range email.Attachments {
+		data, err := os.ReadFile(attachment.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.WarnContext(ctx, "Sending an email without its missing attachment", "email_id", email.ID, "filename", attachment.Filename)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read attachment %s: %w", attachment.Filename, err)
+		}
+		msg.Attachments = append(msg.Attachments, mailer.Attachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Data:        data,
+		})
+	}
+	msg.Attachments = append(msg.Attachments, emails.InlineImages(email.Body)...)
+	return config.SendEmail(ctx, msg)
+}
+
+// RunOutboxWorker sends due emails, text messages and webhook deliveries every interval until ctx is cancelled.
+func RunOutboxWorker(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	outboxes := []struct {
+		name    string
+		process func(db *gorm.DB, batchSize int) (int, error)
+	}{
+		{"email", ProcessOutbox},
+		{"sms", ProcessSMSOutbox},
+		{"webhook", ProcessWebhookDeliveries},
+	}
+
+	for {
+		// Keep going while full batches come back, so a backlog drains without waiting
+		for _, outbox := range outboxes {
+			for {
+				sent, err := outbox.process(db.WithContext(ctx), outboxBatchSize)
+				if err != nil {
+					if !errors.Is(err, context.Canceled) {
+						slog.Error("Failed to process the outbox", "outbox", outbox.name, "error", err)
+					}
+					break
+				}
+				if sent < outboxBatchSize {
+					break
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
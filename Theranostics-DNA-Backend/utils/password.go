@@ -2,8 +2,8 @@
 package utils
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"math/big"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -23,29 +23,46 @@ const (
 )
 
 // GenerateSecurePassword generates a random password with specified constraints
-func GenerateSecurePassword() string {
-	rand.Seed(time.Now().UnixNano()) // Seed the random number generator
-
+// using a cryptographically secure random source.
+func GenerateSecurePassword() (string, error) {
+	allChars := letters + numbers + specialChars
 	password := make([]byte, passwordLength)
 
-	// Ensure at least one lowercase letter
-	password[0] = letters[rand.Intn(26)] // Lowercase
-	// Ensure at least one uppercase letter
-	password[1] = letters[26+rand.Intn(26)] // Uppercase
-	// Ensure at least one number
-	password[2] = numbers[rand.Intn(len(numbers))]
-	// Ensure at least one special character
-	password[3] = specialChars[0] // Only "@" is allowed
-
-	for i := 4; i < passwordLength; i++ {
-		allChars := letters + numbers + specialChars
-		password[i] = allChars[rand.Intn(len(allChars))]
+	// Ensure at least one lowercase letter, one uppercase letter, one number and one special character
+	required := []string{letters[:26], letters[26:], numbers, specialChars}
+	for i, charset := range required {
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	for i := len(required); i < passwordLength; i++ {
+		c, err := randomChar(allChars)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
 	}
 
-	// Shuffle the password to ensure randomness
-	rand.Shuffle(len(password), func(i, j int) {
-		password[i], password[j] = password[j], password[i]
-	})
+	// Shuffle the password so the required characters are not always first (Fisher-Yates)
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
 
-	return string(password)
+	return string(password), nil
+}
+
+// randomChar picks a uniformly random character from charset.
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
 }
@@ -0,0 +1,125 @@
+// utils/password_token.go
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PasswordTokenPurposeReset  = "reset"
+	PasswordTokenPurposeInvite = "invite"
+
+	// PasswordResetTokenTTL is how long a password reset link stays valid.
+	PasswordResetTokenTTL = time.Hour
+	// PasswordInviteTokenTTL is how long a new staff member has to set their first password.
+	PasswordInviteTokenTTL = 72 * time.Hour
+
+	// setPasswordPath is the frontend page that reads the token and asks for a new password.
+	setPasswordPath = "/set-password"
+)
+
+// ErrInvalidPasswordToken is returned for unknown, expired or already used password tokens.
+var ErrInvalidPasswordToken = errors.New("invalid password token")
+
+// IssuePasswordToken creates a new password token for the user and supersedes any earlier
+// unused ones, so only the most recent email link works. It returns the plaintext token.
+func IssuePasswordToken(tx *gorm.DB, userID uint, purpose string, createdBy *uint) (string, time.Time, error) {
+	ttl := passwordTokenTTL(purpose)
+
+	now := time.Now().UTC()
+	if err := tx.Model(&models.PasswordToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error; err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	record := models.PasswordToken{
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedBy: createdBy,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, record.ExpiresAt, nil
+}
+
+// ConsumePasswordToken locks and marks a valid token as used and returns it with its user.
+// It must be called inside a transaction together with the password update.
+func ConsumePasswordToken(tx *gorm.DB, token string) (*models.PasswordToken, error) {
+	var record models.PasswordToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", HashToken(strings.TrimSpace(token))).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPasswordToken
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if record.UsedAt != nil || now.After(record.ExpiresAt) {
+		return nil, ErrInvalidPasswordToken
+	}
+
+	if err := tx.Preload("Role").First(&record.User, record.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPasswordToken
+		}
+		return nil, err
+	}
+	if !record.User.ActiveStatus || record.User.IsDeleted {
+		return nil, ErrInvalidPasswordToken
+	}
+
+	record.UsedAt = &now
+	if err := tx.Model(&models.PasswordToken{}).Where("id = ?", record.ID).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// PasswordTokenExpiryHours returns a token's lifetime in whole hours, for use in emails.
+func PasswordTokenExpiryHours(purpose string) int {
+	return int(passwordTokenTTL(purpose).Hours())
+}
+
+// PasswordTokenExpiryText describes a token's lifetime, e.g. "1 hour".
+func PasswordTokenExpiryText(purpose string) string {
+	hours := PasswordTokenExpiryHours(purpose)
+	if hours == 1 {
+		return "1 hour"
+	}
+	return strconv.Itoa(hours) + " hours"
+}
+
+func passwordTokenTTL(purpose string) time.Duration {
+	if purpose == PasswordTokenPurposeInvite {
+		return PasswordInviteTokenTTL
+	}
+	return PasswordResetTokenTTL
+}
+
+// SetPasswordURL builds the frontend link included in reset and invite emails.
+func SetPasswordURL(token string) string {
+	return strings.TrimRight(config.AppConfig.AppUrl, "/") + setPasswordPath + "?token=" + url.QueryEscape(token)
+}
@@ -10,10 +10,17 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"time"
+
 	"theransticslabs/m/config"
+	"theransticslabs/m/metrics"
 	"theransticslabs/m/models"
 )
 
+// ErrPayPalOrderNotFound is returned when PayPal no longer knows an order, e.g. because it
+// expired before the buyer approved it.
+var ErrPayPalOrderNotFound = errors.New("PayPal order not found")
+
 // Define the response structure for PayPal access token
 type PayPalAccessTokenResponse struct {
 	Scope       string `json:"scope"`
@@ -47,7 +54,10 @@ type PayPalCaptureResponse struct {
 	} `json:"payment_source"`
 }
 
-func GetPayPalAccessToken() (string, error) {
+func GetPayPalAccessToken() (_ string, err error) {
+	start := time.Now()
+	defer func() { metrics.ObservePayPal(metrics.PayPalAccessToken, start, err) }()
+
 	url := config.AppConfig.PaypalAPIUrl + "/v1/oauth2/token"
 	clientID := config.AppConfig.PaypalClientID
 	secret := config.AppConfig.PaypalClientSecret
@@ -76,7 +86,7 @@ func GetPayPalAccessToken() (string, error) {
 	return res.AccessToken, nil
 }
 
-func CreatePayPalOrder(paymentID uint, amount float64, accessToken string, orderDetails *models.Order, customer *models.Customer) (PayPalOrderResponse, error) {
+func CreatePayPalOrder(paymentID uint, amount float64, accessToken string, orderDetails *models.Order, customer *models.Customer) (_ PayPalOrderResponse, err error) {
 	url := config.AppConfig.PaypalAPIUrl + "/v2/checkout/orders"
 
 	returnURL := fmt.Sprintf("%s/payment/status?payment_id=%d", config.AppConfig.ApiUrl, paymentID)
@@ -108,11 +118,11 @@ func CreatePayPalOrder(paymentID uint, amount float64, accessToken string, order
 				"description":  fmt.Sprintf("Order #%d", orderDetails.ID),
 				"custom_id":    fmt.Sprintf("ORDER_%d", orderDetails.ID),
 				"amount": map[string]interface{}{
-					"currency_code": "USD",
+					"currency_code": Currency,
 					"value":         fmt.Sprintf("%.2f", amount),
 					"breakdown": map[string]interface{}{
 						"item_total": map[string]string{
-							"currency_code": "USD",
+							"currency_code": Currency,
 							"value":         fmt.Sprintf("%.2f", subtotal),
 						},
 					},
@@ -128,7 +138,7 @@ func CreatePayPalOrder(paymentID uint, amount float64, accessToken string, order
 						),
 						"quantity": strconv.Itoa(orderDetails.Quantity),
 						"unit_amount": map[string]string{
-							"currency_code": "USD",
+							"currency_code": Currency,
 							"value":         fmt.Sprintf("%.2f", unitPrice),
 						},
 					},
@@ -146,6 +156,9 @@ func CreatePayPalOrder(paymentID uint, amount float64, accessToken string, order
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
+	start := time.Now()
+	defer func() { metrics.ObservePayPal(metrics.PayPalCreateOrder, start, err) }()
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -153,13 +166,17 @@ func CreatePayPalOrder(paymentID uint, amount float64, accessToken string, order
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return PayPalOrderResponse{}, fmt.Errorf("failed to create PayPal order. Status: %d", resp.StatusCode)
+	}
+
 	var res PayPalOrderResponse
 	json.NewDecoder(resp.Body).Decode(&res)
 	return res, nil
 }
 
 // CapturePayPalPayment captures a previously authorized PayPal payment
-func CapturePayPalPayment(orderID string, accessToken string) error {
+func CapturePayPalPayment(orderID string, accessToken string) (err error) {
 	url := fmt.Sprintf("%s/v2/checkout/orders/%s/capture", config.AppConfig.PaypalAPIUrl, orderID)
 
 	// Create request
@@ -173,6 +190,9 @@ func CapturePayPalPayment(orderID string, accessToken string) error {
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 	req.Header.Set("Prefer", "return=representation")
 
+	start := time.Now()
+	defer func() { metrics.ObservePayPal(metrics.PayPalCapture, start, err) }()
+
 	// Send request
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -205,3 +225,38 @@ func CapturePayPalPayment(orderID string, accessToken string) error {
 
 	return nil
 }
+
+// GetPayPalOrder fetches the current state of a PayPal order, e.g. "APPROVED" once the buyer
+// has approved it or "COMPLETED" once it has been captured.
+func GetPayPalOrder(orderID string, accessToken string) (_ PayPalOrderResponse, err error) {
+	url := fmt.Sprintf("%s/v2/checkout/orders/%s", config.AppConfig.PaypalAPIUrl, orderID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return PayPalOrderResponse{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	start := time.Now()
+	defer func() { metrics.ObservePayPal(metrics.PayPalGetOrder, start, err) }()
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return PayPalOrderResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return PayPalOrderResponse{}, ErrPayPalOrderNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return PayPalOrderResponse{}, fmt.Errorf("failed to get PayPal order. Status: %d", resp.StatusCode)
+	}
+
+	var res PayPalOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return PayPalOrderResponse{}, fmt.Errorf("failed to parse PayPal order: %w", err)
+	}
+	return res, nil
+}
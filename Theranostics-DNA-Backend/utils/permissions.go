@@ -0,0 +1,193 @@
+// utils/permissions.go
+package utils
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+)
+
+// Built-in role names. The super-admin role implicitly holds every permission so it can never
+// lock itself out of role management.
+const (
+	RoleSuperAdmin = "super-admin"
+	RoleAdmin      = "admin"
+	RoleUser       = "user"
+)
+
+// Permission keys checked by the API routes.
+const (
+	PermStaffRead          = "staff.read"
+	PermStaffCreate        = "staff.create"
+	PermStaffUpdate        = "staff.update"
+	PermStaffDelete        = "staff.delete"
+	PermStaffSecurity      = "staff.security"
+	PermStaffAssignRole    = "staff.assign_role"
+	PermRolesRead          = "roles.read"
+	PermRolesManage        = "roles.manage"
+	PermKitsRead           = "kits.read"
+	PermKitsWrite          = "kits.write"
+	PermProductOfferCreate = "product_offers.create"
+	PermCustomersExport    = "customers.export"
+	PermCustomersErase     = "customers.erase"
+	PermCustomersNotify    = "customers.notifications"
+	PermAuditRead          = "audit.read"
+	PermEmailsManage       = "emails.manage"
+	PermWebhooksManage     = "webhooks.manage"
+	PermShipmentsRead      = "shipments.read"
+	PermShipmentsWrite     = "shipments.write"
+)
+
+// PermissionDefinition describes a permission key stored in the permissions table.
+type PermissionDefinition struct {
+	Key         string
+	Description string
+}
+
+// PermissionDefinitions lists every permission the API knows about. They are seeded at start-up.
+var PermissionDefinitions = []PermissionDefinition{
+	{PermStaffRead, "View staff accounts"},
+	{PermStaffCreate, "Invite new staff accounts"},
+	{PermStaffUpdate, "Edit staff details, status and passwords"},
+	{PermStaffDelete, "Delete staff accounts"},
+	{PermStaffSecurity, "Unlock staff accounts and reset their two-factor authentication"},
+	{PermStaffAssignRole, "Change the role of staff accounts"},
+	{PermRolesRead, "View roles and permissions"},
+	{PermRolesManage, "Create, edit and delete roles and assign permissions"},
+	{PermKitsRead, "View kit inventory"},
+	{PermKitsWrite, "Create, edit and delete kits"},
+	{PermProductOfferCreate, "Create signed product offers"},
+	{PermCustomersExport, "Export a customer's personal data"},
+	{PermCustomersErase, "Erase a customer's personal data"},
+	{PermCustomersNotify, "View and change a customer's notification preferences"},
+	{PermAuditRead, "View and export the audit log"},
+	{PermEmailsManage, "View the email outbox and resend failed emails"},
+	{PermWebhooksManage, "Manage webhook subscriptions and replay their deliveries"},
+	{PermShipmentsRead, "View order shipments and their tracking"},
+	{PermShipmentsWrite, "Create shipments and record their tracking"},
+}
+
+// DefaultRolePermissions are granted to the built-in roles when they are first seeded.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {PermKitsRead, PermKitsWrite, PermProductOfferCreate, PermCustomersExport, PermCustomersNotify, PermShipmentsRead, PermShipmentsWrite},
+	RoleUser:  {},
+}
+
+// permissionCacheTTL bounds how long another instance's role changes can take to be seen.
+const permissionCacheTTL = time.Minute
+
+// rolePermissionCache holds the role ID to permission key map used by the permission middleware.
+var rolePermissionCache struct {
+	sync.RWMutex
+	loadedAt time.Time
+	roles    map[uint]map[string]bool
+}
+
+// RoleHasPermission reports whether the role grants the permission. Disabled roles grant nothing.
+func RoleHasPermission(db *gorm.DB, role models.Role, permission string) (bool, error) {
+	if !role.Status || role.IsDeleted {
+		return false, nil
+	}
+	if role.Name == RoleSuperAdmin {
+		return true, nil
+	}
+
+	roles, err := cachedRolePermissions(db)
+	if err != nil {
+		return false, err
+	}
+	return roles[role.ID][permission], nil
+}
+
+// RolePermissionKeys returns the sorted permission keys the role grants.
+func RolePermissionKeys(db *gorm.DB, role models.Role) ([]string, error) {
+	if role.Name == RoleSuperAdmin {
+		keys := make([]string, len(PermissionDefinitions))
+		for i, definition := range PermissionDefinitions {
+			keys[i] = definition.Key
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+
+	roles, err := cachedRolePermissions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(roles[role.ID]))
+	for key := range roles[role.ID] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// RoleIncludesRole reports whether role grants every permission that other grants, i.e. whether
+// a holder of role may assign other or manage users who hold it.
+func RoleIncludesRole(db *gorm.DB, role, other models.Role) (bool, error) {
+	if role.Name == RoleSuperAdmin {
+		return true, nil
+	}
+	if other.Name == RoleSuperAdmin {
+		return false, nil
+	}
+
+	roles, err := cachedRolePermissions(db)
+	if err != nil {
+		return false, err
+	}
+	for key := range roles[other.ID] {
+		if !roles[role.ID][key] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// InvalidatePermissionCache forces the next permission check to reload from the database.
+// Call it after changing a role's permissions.
+func InvalidatePermissionCache() {
+	rolePermissionCache.Lock()
+	rolePermissionCache.roles = nil
+	rolePermissionCache.Unlock()
+}
+
+func cachedRolePermissions(db *gorm.DB) (map[uint]map[string]bool, error) {
+	rolePermissionCache.RLock()
+	roles, loadedAt := rolePermissionCache.roles, rolePermissionCache.loadedAt
+	rolePermissionCache.RUnlock()
+	if roles != nil && time.Since(loadedAt) < permissionCacheTTL {
+		return roles, nil
+	}
+
+	var rows []struct {
+		RoleID uint
+		Key    string
+	}
+	if err := db.Table("role_permissions").
+		Select("role_permissions.role_id, permissions.key").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	roles = make(map[uint]map[string]bool)
+	for _, row := range rows {
+		if roles[row.RoleID] == nil {
+			roles[row.RoleID] = make(map[string]bool)
+		}
+		roles[row.RoleID][row.Key] = true
+	}
+
+	rolePermissionCache.Lock()
+	rolePermissionCache.roles = roles
+	rolePermissionCache.loadedAt = time.Now()
+	rolePermissionCache.Unlock()
+
+	return roles, nil
+}
@@ -0,0 +1,278 @@
+// utils/pii.go
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	// Model fields tagged with `serializer:encrypted` are transparently encrypted on write
+	// and decrypted on read.
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// EncryptedSerializer is a GORM serializer that stores string fields encrypted with Encrypt.
+type EncryptedSerializer struct{}
+
+// Scan decrypts the stored value into the destination string field.
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = string(v)
+	case string:
+		stored = v
+	default:
+		return errors.New("encrypted field must be stored as text")
+	}
+
+	// A value carrying a key ID was written by Encrypt, so failing to decrypt it means a missing
+	// key or a corrupted row, never something to hand back as it is
+	value := stored
+	if keyID, versioned := CiphertextKeyID(stored); versioned {
+		plaintext, err := Decrypt(stored)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s.%s with key %q: %w", field.Schema.Table, field.DBName, keyID, err)
+		}
+		value = plaintext
+	} else if stored != "" {
+		// Unprefixed values are either legacy ciphertexts from before key IDs, or plaintext from
+		// before encryption, which is returned unchanged until EncryptLegacyCustomers rewrites it
+		if plaintext, err := Decrypt(stored); err == nil {
+			value = plaintext
+		} else {
+			slog.WarnContext(ctx, "Read an unencrypted value from an encrypted column", "table", field.Schema.Table, "column", field.DBName)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(value)
+	return nil
+}
+
+// Value encrypts the string field value before it is written to the database.
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, _ := fieldValue.(string)
+	if plaintext == "" {
+		return "", nil
+	}
+	return Encrypt(plaintext)
+}
+
+// BlindIndex returns a keyed HMAC-SHA256 of the normalised value so encrypted columns
+// can still be looked up by equality.
+func BlindIndex(value string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(config.AppConfig.BlindIndexKey)
+	if err != nil {
+		return "", err
+	}
+	if len(key) < 32 {
+		return "", errors.New("blind index key must be at least 32 bytes")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// customerEncryptedColumns are the customer columns stored with the encrypted serializer.
+var customerEncryptedColumns = []string{
+	"first_name", "last_name", "email", "phone_number", "street_address", "town_city", "region", "postcode",
+}
+
+// EncryptLegacyCustomers rewrites customers that have no email blind index yet, which encrypts
+// their PII columns and fills in the index. Erased customers are left alone.
+func EncryptLegacyCustomers(db *gorm.DB) (int, error) {
+	var customers []models.Customer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the rows so an edit or erasure made meanwhile waits instead of being overwritten
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("(email_hash IS NULL OR email_hash = '') AND erased_at IS NULL").
+			Find(&customers).Error; err != nil {
+			return err
+		}
+
+		for i := range customers {
+			emailHash, err := BlindIndex(customers[i].Email)
+			if err != nil {
+				return err
+			}
+			customers[i].EmailHash = emailHash
+			if err := tx.Unscoped().Model(&customers[i]).
+				Select(append([]string{"email_hash"}, customerEncryptedColumns...)).
+				Updates(&customers[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(customers), nil
+}
+
+// EncryptedTable is a table with columns stored with the encrypted serializer.
+type EncryptedTable struct {
+	Name    string
+	Columns []string
+}
+
+// EncryptedTables walks the schema of every model and returns the tables that have encrypted
+// columns, so a column added to any model is rotated without further changes. It fails on a table
+// whose rows can't be walked by an "id" primary key, rather than leaving its columns behind.
+func EncryptedTables(db *gorm.DB) ([]EncryptedTable, error) {
+	var tables []EncryptedTable
+	cache := &sync.Map{}
+	for _, model := range models.All() {
+		s, err := schema.Parse(model, cache, db.NamingStrategy)
+		if err != nil {
+			return nil, err
+		}
+
+		var columns []string
+		for _, field := range s.Fields {
+			if _, encrypted := field.Serializer.(EncryptedSerializer); encrypted && field.DBName != "" {
+				columns = append(columns, field.DBName)
+			}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		if len(s.PrimaryFields) != 1 || s.PrimaryFields[0].DBName != "id" {
+			return nil, fmt.Errorf("table %s has encrypted columns but no id primary key to re-encrypt them by", s.Table)
+		}
+		tables = append(tables, EncryptedTable{Name: s.Table, Columns: columns})
+	}
+	return tables, nil
+}
+
+// ReencryptAll moves every encrypted column of every table onto the active key, and returns the
+// number of rows rewritten per table.
+func ReencryptAll(db *gorm.DB, batchSize int) (map[string]int, error) {
+	counts := map[string]int{}
+	tables, err := EncryptedTables(db)
+	if err != nil {
+		return counts, err
+	}
+	for _, table := range tables {
+		count, err := ReencryptTable(db, table, batchSize)
+		counts[table.Name] = count
+		if err != nil {
+			return counts, fmt.Errorf("re-encrypting %s: %w", table.Name, err)
+		}
+	}
+	return counts, nil
+}
+
+// ReencryptTable rewrites, in batches, every row of the table holding a value that isn't
+// encrypted with the active key. Only the stale ciphertexts are written, straight to the columns,
+// so nothing else about the row changes, updated_at included. Each batch is locked while it is
+// rewritten, so concurrent edits and erasures are never overwritten. Erased customers are
+// rewritten too: their placeholders are encrypted, and would become unreadable once the key they
+// are on is retired.
+func ReencryptTable(db *gorm.DB, table EncryptedTable, batchSize int) (int, error) {
+	activePattern := ActiveKeyID() + keyIDSeparator + "%"
+	var stale []string
+	var staleArgs []interface{}
+	for _, column := range table.Columns {
+		stale = append(stale, fmt.Sprintf("(%[1]s <> '' AND %[1]s NOT LIKE ?)", db.Statement.Quote(column)))
+		staleArgs = append(staleArgs, activePattern)
+	}
+
+	total := 0
+	var lastID int64
+	for {
+		var rows []map[string]interface{}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table(table.Name).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select(append([]string{"id"}, table.Columns...)).
+				Where("id > ?", lastID).
+				Where(strings.Join(stale, " OR "), staleArgs...).
+				Order("id").
+				Limit(batchSize).
+				Find(&rows).Error; err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				id, ok := row["id"].(int64)
+				if !ok {
+					return fmt.Errorf("unexpected id type %T", row["id"])
+				}
+				updates := map[string]interface{}{}
+				for _, column := range table.Columns {
+					var stored string
+					switch v := row[column].(type) {
+					case string:
+						stored = v
+					case []byte:
+						stored = string(v)
+					}
+					ciphertext, changed, err := reencryptValue(stored)
+					if err != nil {
+						return fmt.Errorf("%s.%s of row %d: %w", table.Name, column, id, err)
+					}
+					if changed {
+						updates[column] = ciphertext
+					}
+				}
+				if err := tx.Table(table.Name).Where("id = ?", id).Updates(updates).Error; err != nil {
+					return err
+				}
+				lastID = id
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		total += len(rows)
+	}
+}
+
+// reencryptValue returns the stored value encrypted with the active key, and whether that differs
+// from what is stored. Unprefixed values are legacy ciphertexts, or plaintext written before the
+// column was encrypted, which gets encrypted now.
+func reencryptValue(stored string) (string, bool, error) {
+	if stored == "" {
+		return stored, false, nil
+	}
+	keyID, versioned := CiphertextKeyID(stored)
+	if versioned && keyID == ActiveKeyID() {
+		return stored, false, nil
+	}
+
+	plaintext, err := Decrypt(stored)
+	if err != nil {
+		if versioned {
+			return "", false, fmt.Errorf("failed to decrypt with key %q: %w", keyID, err)
+		}
+		plaintext = stored
+	}
+	ciphertext, err := Encrypt(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return ciphertext, true, nil
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// useTestKeys configures a keyring of the legacy pair plus pairs "1" and "2", with activeKeyID
+// used for new ciphertexts, and restores the configuration when the test ends.
+func useTestKeys(t *testing.T, activeKeyID string) {
+	t.Helper()
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+
+	config.AppConfig.EncryptionKey1 = testKey(t)
+	config.AppConfig.EncryptionKey2 = testKey(t)
+	config.AppConfig.EncryptionKeyring = "1:" + testKey(t) + ":" + testKey(t) + ",2:" + testKey(t) + ":" + testKey(t)
+	config.AppConfig.EncryptionActiveKeyID = activeKeyID
+	config.AppConfig.BlindIndexKey = testKey(t)
+}
+
+// testKey returns a random base64-encoded 32 byte key.
+func testKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestEncryptedTables(t *testing.T) {
+	db := &gorm.DB{Config: &gorm.Config{NamingStrategy: schema.NamingStrategy{}}}
+	tables, err := EncryptedTables(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string][]string{}
+	for _, table := range tables {
+		found[table.Name] = table.Columns
+	}
+
+	tests := []struct {
+		table   string
+		columns []string
+	}{
+		{"customers", []string{"first_name", "last_name", "email", "phone_number", "street_address", "town_city", "region", "postcode"}},
+		{"users", []string{"two_factor_secret"}},
+		{"email_outbox", []string{"recipients", "body", "last_error"}},
+		{"sms_outbox", []string{"phone_number", "body", "last_error"}},
+		{"webhook_subscriptions", []string{"secret"}},
+		{"invoices", []string{"billing_name", "billing_address", "billing_email"}},
+		{"product_offers", []string{"customer_email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			if !reflect.DeepEqual(found[tt.table], tt.columns) {
+				t.Errorf("encrypted columns = %v, want %v", found[tt.table], tt.columns)
+			}
+		})
+	}
+}
+
+func TestReencryptValue(t *testing.T) {
+	useTestKeys(t, "1")
+	onKey1, err := Encrypt("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := encryptWithKeyPair("jane@example.com", config.EncryptionKeyPair{
+		Key1: config.AppConfig.EncryptionKey1,
+		Key2: config.AppConfig.EncryptionKey2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.AppConfig.EncryptionActiveKeyID = "2"
+	onKey2, err := Encrypt("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		stored  string
+		changed bool
+		wantErr bool
+	}{
+		{"empty", "", false, false},
+		{"active key", onKey2, false, false},
+		{"previous key", onKey1, true, false},
+		{"legacy ciphertext", legacy, true, false},
+		{"plaintext", "jane@example.com", true, false},
+		{"unknown key", "9$" + strings.TrimPrefix(onKey1, "1$"), false, true},
+		{"corrupted", "1$" + strings.TrimPrefix(onKey2, "2$"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := reencryptValue(tt.stored)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if !changed {
+				if got != tt.stored {
+					t.Errorf("unchanged value rewritten to %q", got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, "2$") {
+				t.Errorf("re-encrypted value %q is not on the active key", got)
+			}
+			if plaintext, err := Decrypt(got); err != nil || plaintext != "jane@example.com" {
+				t.Errorf("Decrypt = %q, %v", plaintext, err)
+			}
+		})
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	useTestKeys(t, "")
+	jane, err := BlindIndex("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jane) != 64 {
+		t.Errorf("blind index %q is not a hex SHA-256", jane)
+	}
+
+	tests := []struct {
+		value string
+		same  bool
+	}{
+		{"jane@example.com", true},
+		{"Jane@Example.COM", true},
+		{"  jane@example.com\n", true},
+		{"jane@example.co", false},
+		{"john@example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := BlindIndex(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := got == jane; same != tt.same {
+				t.Errorf("matches = %v, want %v", same, tt.same)
+			}
+		})
+	}
+
+	// The index is keyed, so it can't be recomputed without the key
+	config.AppConfig.BlindIndexKey = testKey(t)
+	if other, err := BlindIndex("jane@example.com"); err != nil || other == jane {
+		t.Errorf("BlindIndex with another key = %q, %v", other, err)
+	}
+}
+
+func TestBlindIndexKeyChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"missing", ""},
+		{"not base64", "not base64!"},
+		{"too short", base64.StdEncoding.EncodeToString(make([]byte, 16))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestKeys(t, "")
+			config.AppConfig.BlindIndexKey = tt.key
+			if _, err := BlindIndex("jane@example.com"); err == nil {
+				t.Error("BlindIndex succeeded")
+			}
+		})
+	}
+}
+
+func TestEncryptedSerializer(t *testing.T) {
+	useTestKeys(t, "1")
+	s, err := schema.Parse(&models.Customer{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := s.LookUpField("email")
+	ctx := context.Background()
+
+	stored, err := EncryptedSerializer{}.Value(ctx, field, reflect.Value{}, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext, _ := stored.(string); !strings.HasPrefix(ciphertext, "1$") {
+		t.Fatalf("Value = %v, want a ciphertext on key 1", stored)
+	}
+	if empty, err := (EncryptedSerializer{}).Value(ctx, field, reflect.Value{}, ""); err != nil || empty != "" {
+		t.Errorf("Value of an empty string = %v, %v, want it stored empty", empty, err)
+	}
+
+	legacy, err := encryptWithKeyPair("jane@example.com", config.EncryptionKeyPair{
+		Key1: config.AppConfig.EncryptionKey1,
+		Key2: config.AppConfig.EncryptionKey2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dbValue interface{}
+		want    string
+		wantErr bool
+	}{
+		{"ciphertext", stored, "jane@example.com", false},
+		{"ciphertext as bytes", []byte(stored.(string)), "jane@example.com", false},
+		{"legacy ciphertext", legacy, "jane@example.com", false},
+		{"plaintext from before encryption", "jane@example.com", "jane@example.com", false},
+		{"NULL", nil, "", false},
+		{"empty", "", "", false},
+		{"retired key", "9$" + strings.TrimPrefix(stored.(string), "1$"), "", true},
+		{"corrupted ciphertext", stored.(string)[:len(stored.(string))-4], "", true},
+		{"not text", 42, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var customer models.Customer
+			err := EncryptedSerializer{}.Scan(ctx, field, reflect.ValueOf(&customer).Elem(), tt.dbValue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if customer.Email != tt.want {
+				t.Errorf("Email = %q, want %q", customer.Email, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// utils/product_offer.go
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ProductOfferAudience distinguishes product offer tokens from login tokens signed with the same secret.
+const ProductOfferAudience = "product-offer"
+
+// ProductOfferClaims are the claims carried by a signed product offer token.
+type ProductOfferClaims struct {
+	ProductName  string  `json:"product_name"`
+	ProductPrice float64 `json:"product_price"`
+	MaxUses      int     `json:"max_uses,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateProductOfferToken signs a token for the given offer. The offer nonce is used as the token ID.
+func GenerateProductOfferToken(offer models.ProductOffer) (string, error) {
+	claims := ProductOfferClaims{
+		ProductName:  offer.ProductName,
+		ProductPrice: offer.ProductPrice,
+		MaxUses:      offer.MaxUses,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        offer.Nonce,
+			Subject:   strconv.FormatUint(uint64(offer.ID), 10),
+			Audience:  jwt.ClaimStrings{ProductOfferAudience},
+			IssuedAt:  jwt.NewNumericDate(offer.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(offer.ExpiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JWTSecret))
+}
+
+// ValidateProductOfferToken verifies the signature, expiry and audience of a product offer token.
+func ValidateProductOfferToken(tokenString string) (*ProductOfferClaims, error) {
+	claims := &ProductOfferClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Validate the signing method
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(config.AppConfig.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	if !claims.VerifyAudience(ProductOfferAudience, true) {
+		return nil, errors.New("token is not a product offer")
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return nil, errors.New("product offer token is missing required claims")
+	}
+	if claims.IssuedAt.After(time.Now().Add(time.Minute)) {
+		return nil, errors.New("product offer token is not valid yet")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,223 @@
+// utils/return_label.go
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// ErrLabAddressMissing is returned when rendering return labels without a lab address configured.
+var ErrLabAddressMissing = errors.New("the lab address for return labels is not configured")
+
+const (
+	// Kit serials are "TK" followed by characters that can't be misread for one another by hand.
+	kitSerialPrefix   = "TK"
+	kitSerialAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	kitSerialLength   = 10
+
+	// Labels are printed on standard 4x6 inch label stock.
+	returnLabelWidth  = 100.0
+	returnLabelHeight = 150.0
+	returnLabelMargin = 6.0
+)
+
+// NewKitSerial returns a random kit serial such as "TK7QX2M9HDKP".
+func NewKitSerial() (string, error) {
+	b := make([]byte, kitSerialLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// The alphabet has 32 characters, so every byte maps onto it evenly
+	for i := range b {
+		b[i] = kitSerialAlphabet[int(b[i])%len(kitSerialAlphabet)]
+	}
+	return kitSerialPrefix + string(b), nil
+}
+
+// EnsureReturnLabels returns a return label for every kit in the order, sorted by unit, creating
+// the ones that don't exist yet. Existing labels keep their kit serials.
+func EnsureReturnLabels(tx *gorm.DB, order *models.Order) ([]models.ReturnLabel, error) {
+	var labels []models.ReturnLabel
+	if err := tx.Where("order_id = ?", order.ID).Find(&labels).Error; err != nil {
+		return nil, err
+	}
+	existing := map[int]bool{}
+	for _, label := range labels {
+		existing[label.Unit] = true
+	}
+
+	var missing []models.ReturnLabel
+	for unit := 1; unit <= order.Quantity; unit++ {
+		if existing[unit] {
+			continue
+		}
+		serial, err := NewKitSerial()
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, models.ReturnLabel{OrderID: order.ID, Unit: unit, KitSerial: serial})
+	}
+	if len(missing) > 0 {
+		if err := tx.Create(&missing).Error; err != nil {
+			return nil, err
+		}
+		labels = append(labels, missing...)
+	}
+
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Unit < labels[j].Unit })
+	return labels, nil
+}
+
+// WriteReturnLabelsPDF renders one label page per return label, addressed from the customer to
+// the lab.
+func WriteReturnLabelsPDF(w io.Writer, labels []models.ReturnLabel, order *models.Order, customer *models.Customer) error {
+	lab := labAddress()
+	if lab == nil {
+		return ErrLabAddressMissing
+	}
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: returnLabelWidth, Ht: returnLabelHeight},
+	})
+	pdf.SetMargins(returnLabelMargin, returnLabelMargin, returnLabelMargin)
+	pdf.SetAutoPageBreak(false, 0)
+
+	// Core fonts are Windows-1252, which covers the accented letters of our customers' languages
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	content := returnLabelWidth - 2*returnLabelMargin
+
+	from := []string{strings.TrimSpace(customer.FirstName + " " + customer.LastName)}
+	from = append(from, addressLines(customer.StreetAddress, customer.TownCity, customer.Region, customer.Postcode, customer.Country)...)
+
+	for _, label := range labels {
+		pdf.AddPage()
+
+		pdf.SetFont("Arial", "B", 16)
+		pdf.CellFormat(content/2, 8, "RETURN SAMPLE", "", 0, "L", false, 0, "")
+		pdf.SetFont("Arial", "B", 11)
+		pdf.CellFormat(content/2, 8, fmt.Sprintf("Kit %d of %d", label.Unit, order.Quantity), "", 1, "R", false, 0, "")
+		pdf.Line(returnLabelMargin, pdf.GetY()+1, returnLabelWidth-returnLabelMargin, pdf.GetY()+1)
+		pdf.Ln(4)
+
+		pdf.SetFont("Arial", "B", 8)
+		pdf.CellFormat(content, 4, "FROM", "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 9)
+		for _, line := range from {
+			pdf.CellFormat(content, 4.5, tr(line), "", 1, "L", false, 0, "")
+		}
+		pdf.Ln(6)
+
+		pdf.SetFont("Arial", "B", 8)
+		pdf.CellFormat(content, 4, "TO", "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "B", 15)
+		pdf.CellFormat(content, 7, tr(lab[0]), "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 13)
+		for _, line := range lab[1:] {
+			pdf.CellFormat(content, 6, tr(line), "", 1, "L", false, 0, "")
+		}
+
+		pdf.Line(returnLabelMargin, 92, returnLabelWidth-returnLabelMargin, 92)
+		if err := DrawCode128(pdf, label.KitSerial, returnLabelMargin, 97, content, 22); err != nil {
+			return err
+		}
+		pdf.SetXY(returnLabelMargin, 121)
+		pdf.SetFont("Courier", "B", 12)
+		pdf.CellFormat(content, 6, label.KitSerial, "", 1, "C", false, 0, "")
+		pdf.SetFont("Arial", "B", 11)
+		pdf.CellFormat(content, 6, fmt.Sprintf("Order #%d", order.ID), "", 1, "C", false, 0, "")
+
+		pdf.SetXY(returnLabelMargin, 136)
+		pdf.SetFont("Arial", "", 7)
+		pdf.MultiCell(content, 3.5, "Seal the sample in the bag provided and pack it in the kit box before attaching this label. "+
+			"The lab matches your sample to your order by the barcode.", "", "C", false)
+	}
+
+	return pdf.Output(w)
+}
+
+// SaveReturnLabelsPDF writes the order's return labels to a new temporary file under
+// ReturnLabelDir and returns its path, for attaching to an email. The file carries the customer's
+// address, so the caller owns it and must make sure it is deleted; the outbox does so once the
+// email is sent when the attachment is marked Temporary.
+func SaveReturnLabelsPDF(labels []models.ReturnLabel, order *models.Order, customer *models.Customer) (string, error) {
+	dir := config.AppConfig.ReturnLabelDir
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+
+	// Every email gets its own file, so sending one never deletes another's attachment
+	file, err := os.CreateTemp(dir, returnLabelFilePrefix(order.ID)+"*.pdf")
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	if err := WriteReturnLabelsPDF(file, labels, order, customer); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// RemoveReturnLabelFiles deletes the order's return label PDFs that are still waiting under
+// ReturnLabelDir for their emails to be sent.
+func RemoveReturnLabelFiles(orderID uint) error {
+	paths, err := filepath.Glob(filepath.Join(config.AppConfig.ReturnLabelDir, returnLabelFilePrefix(orderID)+"*.pdf"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// returnLabelFilePrefix is the start of the name of every return label file saved for the order.
+func returnLabelFilePrefix(orderID uint) string {
+	return fmt.Sprintf("return_labels_order_%d_", orderID)
+}
+
+// labAddress returns the lab's name followed by its address lines, or nil when it isn't configured.
+func labAddress() []string {
+	cfg := config.AppConfig
+	if cfg.LabName == "" || cfg.LabStreetAddress == "" || cfg.LabTownCity == "" {
+		return nil
+	}
+	return append([]string{cfg.LabName}, addressLines(cfg.LabStreetAddress, cfg.LabTownCity, cfg.LabRegion, cfg.LabPostcode, cfg.LabCountry)...)
+}
+
+// addressLines lays out a postal address, skipping empty parts.
+func addressLines(street, townCity, region, postcode, country string) []string {
+	var lines []string
+	for _, line := range []string{
+		street,
+		townCity,
+		strings.TrimSpace(region + " " + postcode),
+		country,
+	} {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,260 @@
+// utils/session.go
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// AccessTokenTTL is the lifetime of the JWT sent with every API request.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is the lifetime of a single refresh token and of an idle session.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	SessionRevokedLogout        = "logout"
+	SessionRevokedByUser        = "revoked_by_user"
+	SessionRevokedPasswordReset = "password_changed"
+	SessionRevokedTokenReuse    = "refresh_token_reuse"
+	SessionRevokedTwoFactor     = "two_factor_reset"
+	SessionRevokedRoleChange    = "role_changed"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// SessionTokens is the token pair handed to a client after login or refresh.
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      models.Session
+}
+
+// HashToken returns the hex-encoded SHA-256 of an opaque token for storage.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientIP returns the originating client IP. X-Forwarded-For is only honoured when the request
+// comes from a trusted proxy, and then only up to the right-most hop that isn't one: everything to
+// its left was written by the client and can't be believed.
+func ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	proxies, err := config.AppConfig.TrustedProxyNetworks()
+	if err != nil || !isTrustedProxy(proxies, net.ParseIP(remote)) {
+		return remote
+	}
+
+	// Proxies append to the header, or add another one, so the last entry is the nearest hop
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !isTrustedProxy(proxies, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// isTrustedProxy reports whether ip belongs to one of the trusted proxy networks.
+func isTrustedProxy(proxies []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSession starts a new device session for the user and issues its first token pair.
+func CreateSession(db *gorm.DB, user models.User, r *http.Request, deviceName string) (*SessionTokens, error) {
+	now := time.Now().UTC()
+	session := models.Session{
+		UserID:     user.ID,
+		DeviceName: truncate(deviceName, 100),
+		UserAgent:  truncate(r.UserAgent(), 255),
+		IPAddress:  truncate(ClientIP(r), 45),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+
+	tokens := &SessionTokens{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		return issueSessionTokens(tx, user, session, tokens)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair. Presenting a token that
+// was already rotated revokes the session, since it means the token was copied.
+func RotateRefreshToken(db *gorm.DB, refreshToken string, r *http.Request) (*SessionTokens, error) {
+	tokens := &SessionTokens{}
+	var reused bool
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(refreshToken)).
+			First(&stored).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		var session models.Session
+		if err := tx.Preload("User").First(&session, stored.SessionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		now := time.Now().UTC()
+		if err := checkRefreshToken(stored, session, now); err != nil {
+			if errors.Is(err, ErrRefreshTokenReused) {
+				// Commit the revocation even though the request fails
+				reused = true
+				return revokeSession(tx, &session, SessionRevokedTokenReuse)
+			}
+			return err
+		}
+
+		stored.UsedAt = &now
+		if err := tx.Save(&stored).Error; err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(RefreshTokenTTL)
+		session.IPAddress = truncate(ClientIP(r), 45)
+		if err := tx.Omit("User").Save(&session).Error; err != nil {
+			return err
+		}
+
+		return issueSessionTokens(tx, session.User, session, tokens)
+	})
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// checkRefreshToken reports whether the stored refresh token may be exchanged at now. It returns
+// ErrRefreshTokenReused for a token that was already rotated, whatever else is wrong with it.
+func checkRefreshToken(stored models.RefreshToken, session models.Session, now time.Time) error {
+	if stored.UsedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(stored.ExpiresAt) {
+		return ErrInvalidRefreshToken
+	}
+	if !session.User.ActiveStatus || session.User.IsDeleted {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// RevokeSession marks a single session as revoked.
+func RevokeSession(db *gorm.DB, session *models.Session, reason string) error {
+	return revokeSession(db, session, reason)
+}
+
+// RevokeUserSessions revokes every active session of a user, except the one given in keepSessionID (0 keeps none).
+func RevokeUserSessions(db *gorm.DB, userID uint, keepSessionID uint, reason string) error {
+	query := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepSessionID != 0 {
+		query = query.Where("id <> ?", keepSessionID)
+	}
+	return query.Updates(map[string]interface{}{
+		"revoked_at":     time.Now().UTC(),
+		"revoked_reason": reason,
+	}).Error
+}
+
+func revokeSession(db *gorm.DB, session *models.Session, reason string) error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	session.RevokedAt = &now
+	session.RevokedReason = reason
+	return db.Model(&models.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"revoked_at":     now,
+		"revoked_reason": reason,
+	}).Error
+}
+
+// issueSessionTokens signs a new access token and stores a new refresh token for the session.
+func issueSessionTokens(tx *gorm.DB, user models.User, session models.Session, tokens *SessionTokens) error {
+	accessToken, err := GenerateJWT(user, session.ID)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	stored := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: time.Now().UTC().Add(RefreshTokenTTL),
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return err
+	}
+
+	tokens.AccessToken = accessToken
+	tokens.RefreshToken = refreshToken
+	tokens.Session = session
+	return nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
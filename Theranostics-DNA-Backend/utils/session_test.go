@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+)
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	user := models.User{ActiveStatus: true}
+
+	valid := models.RefreshToken{ExpiresAt: now.Add(time.Hour)}
+	rotated := models.RefreshToken{ExpiresAt: now.Add(time.Hour), UsedAt: &earlier}
+	session := models.Session{ExpiresAt: now.Add(time.Hour), User: user}
+
+	tests := []struct {
+		name    string
+		stored  models.RefreshToken
+		session func(models.Session) models.Session
+		want    error
+	}{
+		{"valid", valid, nil, nil},
+		{"reused", rotated, nil, ErrRefreshTokenReused},
+		{"reused on a revoked session", rotated, func(s models.Session) models.Session {
+			s.RevokedAt = &earlier
+			return s
+		}, ErrRefreshTokenReused},
+		{"token expired", models.RefreshToken{ExpiresAt: earlier}, nil, ErrInvalidRefreshToken},
+		{"session expired", valid, func(s models.Session) models.Session {
+			s.ExpiresAt = earlier
+			return s
+		}, ErrInvalidRefreshToken},
+		{"session revoked", valid, func(s models.Session) models.Session {
+			s.RevokedAt = &earlier
+			return s
+		}, ErrInvalidRefreshToken},
+		{"user deactivated", valid, func(s models.Session) models.Session {
+			s.User.ActiveStatus = false
+			return s
+		}, ErrInvalidRefreshToken},
+		{"user deleted", valid, func(s models.Session) models.Session {
+			s.User.IsDeleted = true
+			return s
+		}, ErrInvalidRefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := session
+			if tt.session != nil {
+				s = tt.session(s)
+			}
+			if err := checkRefreshToken(tt.stored, s, now); !errors.Is(err, tt.want) {
+				t.Errorf("checkRefreshToken = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	config.AppConfig.TrustedProxies = "10.0.0.1, 192.168.0.0/16"
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct", "203.0.113.7:4000", nil, "203.0.113.7"},
+		{"untrusted peer's header is ignored", "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted proxy without a header", "10.0.0.1:4000", nil, "10.0.0.1"},
+		{"spoofed hops left of the client", "10.0.0.1:4000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:4000", []string{"198.51.100.1, 192.168.4.2"}, "198.51.100.1"},
+		{"repeated headers", "10.0.0.1:4000", []string{"1.2.3.4", "198.51.100.1", "192.168.4.2"}, "198.51.100.1"},
+		{"garbage hop stops the walk", "10.0.0.1:4000", []string{"198.51.100.1, not-an-ip"}, "10.0.0.1"},
+		{"IPv6 client", "10.0.0.1:4000", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"remote without a port", "203.0.113.7", nil, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			if got := HashToken(tt.token); got != tt.want {
+				t.Errorf("HashToken(%q) = %s, want %s", tt.token, got, tt.want)
+			}
+		})
+	}
+}
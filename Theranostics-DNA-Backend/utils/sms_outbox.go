@@ -0,0 +1,38 @@
+// utils/sms_outbox.go
+package utils
+
+import (
+	"context"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+)
+
+// QueueSMS adds a text message to the SMS outbox. Like QueueEmail, call it with the transaction
+// that makes the change the message reports.
+func QueueSMS(tx *gorm.DB, phoneNumber, body string) error {
+	return tx.Create(&models.SMSOutbox{
+		PhoneNumber:   phoneNumber,
+		Body:          body,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// ProcessSMSOutbox sends up to batchSize due text messages and returns how many were sent. It
+// claims, retries and dead-letters messages the same way ProcessOutbox does for emails.
+func ProcessSMSOutbox(db *gorm.DB, batchSize int) (int, error) {
+	return processOutboxQueue(db, batchSize, outboxQueue[models.SMSOutbox]{
+		name:  "SMS",
+		idKey: "sms_id",
+		entry: func(message *models.SMSOutbox) outboxEntry {
+			return outboxEntry{message.ID, &message.Status, &message.Attempts, &message.NextAttemptAt, &message.LastError, &message.SentAt}
+		},
+		send: func(ctx context.Context, message *models.SMSOutbox) error {
+			return config.SendSMS(ctx, message.PhoneNumber, message.Body)
+		},
+	})
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test secret of RFC 6238, appendix B.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Secret, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Secret)
+	current := time.Now().Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantOK   bool
+	}{
+		{"current step", secret, totpCode(rfc6238Secret, current), 0, true},
+		{"lower-case secret and padded code", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", " " + totpCode(rfc6238Secret, current) + " ", 0, true},
+		{"next step within the allowed drift", secret, totpCode(rfc6238Secret, current+1), 0, true},
+		{"too old", secret, totpCode(rfc6238Secret, current-2), 0, false},
+		{"replayed step", secret, totpCode(rfc6238Secret, current+1), current + 1, false},
+		{"wrong length", secret, "12345", 0, false},
+		{"not digits", secret, "abcdef", 0, false},
+		{"invalid secret", "not base32!", totpCode(rfc6238Secret, current), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTPCode(tt.secret, tt.code, tt.lastStep)
+			if ok != tt.wantOK {
+				t.Fatalf("ValidateTOTPCode ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && step <= tt.lastStep {
+				t.Errorf("matched step %d is not after the last step %d", step, tt.lastStep)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not unpadded base32: %v", secret, err)
+	}
+	if len(key) != totpSecretSize {
+		t.Errorf("secret is %d bytes, want %d", len(key), totpSecretSize)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("recovery code %q is not formatted as xxxx-xxxx-xxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q was issued twice", code)
+		}
+		seen[code] = true
+	}
+
+	tests := []struct {
+		name    string
+		entered string
+		same    bool
+	}{
+		{"as issued", "ab12-cd34-ef56", true},
+		{"upper case", "AB12-CD34-EF56", true},
+		{"without dashes", "ab12cd34ef56", true},
+		{"surrounding spaces", "  ab12-cd34-ef56\n", true},
+		{"different code", "ab12-cd34-ef57", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := HashRecoveryCode(tt.entered) == HashRecoveryCode("ab12-cd34-ef56"); same != tt.same {
+				t.Errorf("hash matches = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// utils/two_factor.go
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/models"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// TwoFactorChallengeAudience distinguishes login challenge tokens from access tokens signed with the same secret.
+	TwoFactorChallengeAudience = "two-factor-challenge"
+	// TwoFactorChallengeTTL is how long a user has to enter their code after the password step.
+	TwoFactorChallengeTTL = 5 * time.Minute
+)
+
+// ErrInvalidTwoFactorCode is returned when neither the TOTP code nor the recovery code is accepted.
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// TwoFactorChallengeClaims are the claims carried by the token returned from the password step of a 2FA login.
+type TwoFactorChallengeClaims struct {
+	DeviceName string `json:"device_name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateTwoFactorChallenge signs a short-lived token proving the user passed the password step.
+func GenerateTwoFactorChallenge(user models.User, deviceName string) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := TwoFactorChallengeClaims{
+		DeviceName: deviceName,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{TwoFactorChallengeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TwoFactorChallengeTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JWTSecret))
+}
+
+// ValidateTwoFactorChallenge verifies a challenge token and returns the ID of the user it was issued to.
+func ValidateTwoFactorChallenge(tokenString string) (uint, *TwoFactorChallengeClaims, error) {
+	claims := &TwoFactorChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Validate the signing method
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(config.AppConfig.JWTSecret), nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if !token.Valid {
+		return 0, nil, jwt.ErrSignatureInvalid
+	}
+
+	if !claims.VerifyAudience(TwoFactorChallengeAudience, true) {
+		return 0, nil, errors.New("token is not a two-factor challenge")
+	}
+	if claims.ExpiresAt == nil {
+		return 0, nil, errors.New("two-factor challenge is missing an expiry")
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, nil, errors.New("invalid two-factor challenge subject")
+	}
+
+	return uint(userID), claims, nil
+}
+
+// TwoFactorEnrolmentPending reports whether the user's role enforces 2FA but the user has not enrolled yet.
+func TwoFactorEnrolmentPending(user models.User) bool {
+	return user.Role.TwoFactorRequired && !user.TwoFactorEnabled
+}
+
+// VerifyTwoFactorCode checks a TOTP code, or failing that a recovery code, for the user. The user
+// row is locked so concurrent requests cannot redeem the same code twice.
+func VerifyTwoFactorCode(tx *gorm.DB, userID uint, code, recoveryCode string) error {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.TwoFactorSecret == "" {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if code != "" {
+		step, ok := ValidateTOTPCode(user.TwoFactorSecret, code, user.TwoFactorLastStep)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("two_factor_last_step", step).Error
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, HashRecoveryCode(recoveryCode)).
+			Update("used_at", time.Now().UTC())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+// ReplaceRecoveryCodes deletes the user's recovery codes and issues a fresh set, returned in plaintext once.
+func ReplaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: HashRecoveryCode(code)}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// ResetTwoFactor removes the user's TOTP secret and recovery codes.
+func ResetTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":      false,
+		"two_factor_secret":       "",
+		"two_factor_last_step":    0,
+		"two_factor_confirmed_at": nil,
+	}).Error
+}
@@ -0,0 +1,386 @@
+// utils/webhook.go
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"theransticslabs/m/config"
+	"theransticslabs/m/metrics"
+	"theransticslabs/m/models"
+
+	"gorm.io/gorm"
+)
+
+// Webhook event types, named "<entity>.<event>".
+const (
+	WebhookOrderCreated       = "order.created"
+	WebhookPaymentCompleted   = "payment.completed"
+	WebhookPaymentFailed      = "payment.failed"
+	WebhookOrderStatusChanged = "order.status_changed"
+	WebhookKitStockLow        = "kit.stock_low"
+	WebhookShipmentShipped    = "shipment.shipped"
+	WebhookShipmentDelivered  = "shipment.delivered"
+)
+
+// WebhookEvents lists every event a subscription can receive.
+var WebhookEvents = []string{
+	WebhookOrderCreated,
+	WebhookPaymentCompleted,
+	WebhookPaymentFailed,
+	WebhookOrderStatusChanged,
+	WebhookKitStockLow,
+	WebhookShipmentShipped,
+	WebhookShipmentDelivered,
+}
+
+// Request headers sent with every delivery. The signature header holds "t=<unix time>,v1=<hex>",
+// where the hex value is the HMAC-SHA256 of "<unix time>.<body>" keyed with the subscription
+// secret. Receivers should reject timestamps more than a few minutes old.
+const (
+	WebhookHeaderSignature = "X-Webhook-Signature"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderEventID   = "X-Webhook-ID"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// ErrWebhookAddressNotAllowed is returned when a webhook would reach an address that isn't
+// publicly routable, such as the loopback interface, a private network or a cloud metadata service.
+var ErrWebhookAddressNotAllowed = errors.New("webhook address is not a public address")
+
+// nonPublicNetworks lists the special-purpose ranges that net.IP's own checks don't cover.
+var nonPublicNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // "This" network
+		"100.64.0.0/10",   // Carrier-grade NAT
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // Documentation
+		"198.18.0.0/15",   // Benchmarking
+		"198.51.100.0/24", // Documentation
+		"203.0.113.0/24",  // Documentation
+		"240.0.0.0/4",     // Reserved
+		"64:ff9b::/96",    // NAT64, which can reach private IPv4 addresses
+		"100::/64",        // Discard
+		"2001:db8::/32",   // Documentation
+		"2002::/16",       // 6to4, which can reach private IPv4 addresses
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// webhookClient doesn't follow redirects or use a proxy, so a delivery only ever reaches the
+// subscribed URL, and its dialer refuses non-public addresses whatever the host name resolves to
+// at send time.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   webhookDialControl,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// IsPublicIP reports whether ip is a publicly routable unicast address.
+func IsPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookHost returns ErrWebhookAddressNotAllowed when host is, or resolves to, an address
+// that isn't public. Deliveries are checked again when they connect, since DNS can change.
+func CheckWebhookHost(ctx context.Context, host string) error {
+	if allowPrivateWebhookAddresses() {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return ErrWebhookAddressNotAllowed
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return ErrWebhookAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// webhookDialControl refuses connections to non-public addresses. It runs after DNS resolution,
+// on the address actually being dialled.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	if allowPrivateWebhookAddresses() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// allowPrivateWebhookAddresses lets webhooks reach receivers on the developer's own machine or
+// network when running locally.
+func allowPrivateWebhookAddresses() bool {
+	return config.AppConfig.Environment == "localhost"
+}
+
+// WebhookEnvelope is the JSON body of a delivery.
+type WebhookEnvelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookOrderData is the data of order.created.
+type WebhookOrderData struct {
+	OrderID       uint    `json:"order_id"`
+	CustomerID    uint    `json:"customer_id"`
+	PaymentID     uint    `json:"payment_id,omitempty"`
+	ProductName   string  `json:"product_name"`
+	Quantity      int     `json:"quantity"`
+	TotalPrice    float64 `json:"total_price"`
+	PaymentStatus string  `json:"payment_status"`
+	OrderStatus   string  `json:"order_status"`
+}
+
+// WebhookPaymentData is the data of payment.completed and payment.failed.
+type WebhookPaymentData struct {
+	PaymentID     uint    `json:"payment_id"`
+	OrderID       uint    `json:"order_id"`
+	Amount        float64 `json:"amount"`
+	PaymentStatus string  `json:"payment_status"`
+}
+
+// WebhookOrderStatusData is the data of order.status_changed.
+type WebhookOrderStatusData struct {
+	OrderID        uint   `json:"order_id"`
+	PreviousStatus string `json:"previous_status"`
+	OrderStatus    string `json:"order_status"`
+}
+
+// WebhookKitStockData is the data of kit.stock_low.
+type WebhookKitStockData struct {
+	KitID     uint   `json:"kit_id"`
+	Type      string `json:"type"`
+	Quantity  int    `json:"quantity"`
+	Threshold int    `json:"threshold"`
+}
+
+// WebhookShipmentData is the data of shipment.shipped and shipment.delivered.
+type WebhookShipmentData struct {
+	ShipmentID     uint       `json:"shipment_id"`
+	OrderID        uint       `json:"order_id"`
+	Direction      string     `json:"direction"`
+	Carrier        string     `json:"carrier"`
+	TrackingNumber string     `json:"tracking_number"`
+	Status         string     `json:"status"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// IsWebhookEvent reports whether event is a known webhook event type.
+func IsWebhookEvent(event string) bool {
+	for _, known := range WebhookEvents {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitWebhookEvents returns the event types stored in a subscription.
+func SplitWebhookEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
+
+// PublishWebhookEvent queues a delivery of the event to every active subscription that wants it.
+// Like QueueEmail, call it with the transaction that makes the change the event reports, so
+// partners only hear about committed changes. Data must not carry customer PII.
+func PublishWebhookEvent(tx *gorm.DB, event string, data interface{}) error {
+	if !IsWebhookEvent(event) {
+		return fmt.Errorf("unknown webhook event %q", event)
+	}
+
+	var subscriptions []models.WebhookSubscription
+	if err := tx.Where("active = ? AND is_deleted = ?", true, false).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	var subscribed []models.WebhookSubscription
+	for _, subscription := range subscriptions {
+		for _, wanted := range SplitWebhookEvents(subscription.Events) {
+			if wanted == event {
+				subscribed = append(subscribed, subscription)
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	token, err := GenerateRandomToken(16)
+	if err != nil {
+		return err
+	}
+	envelope := WebhookEnvelope{ID: "evt_" + token, Type: event, CreatedAt: time.Now().UTC(), Data: data}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(subscribed))
+	for i, subscription := range subscribed {
+		deliveries[i] = models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        envelope.ID,
+			Event:          event,
+			Payload:        string(payload),
+			Status:         OutboxStatusPending,
+			NextAttemptAt:  time.Now(),
+		}
+	}
+	return tx.Create(&deliveries).Error
+}
+
+// SignWebhookPayload returns the signature header value of a payload sent at timestamp.
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ProcessWebhookDeliveries sends up to batchSize due deliveries and returns how many were
+// accepted. It claims, retries and dead-letters deliveries the same way ProcessOutbox does for
+// emails. Deliveries of a subscription that has since been disabled or deleted are dead-lettered
+// straight away; they can be replayed once it is active again.
+func ProcessWebhookDeliveries(db *gorm.DB, batchSize int) (int, error) {
+	var byID map[uint]*models.WebhookSubscription
+	return processOutboxQueue(db, batchSize, outboxQueue[models.WebhookDelivery]{
+		name:  "Webhook delivery",
+		idKey: "delivery_id",
+		entry: func(delivery *models.WebhookDelivery) outboxEntry {
+			return outboxEntry{delivery.ID, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.SentAt}
+		},
+		prepare: func(db *gorm.DB, claimed []models.WebhookDelivery) error {
+			subscriptionIDs := make([]uint, len(claimed))
+			for i := range claimed {
+				subscriptionIDs[i] = claimed[i].SubscriptionID
+			}
+			var subscriptions []models.WebhookSubscription
+			if err := db.Where("id IN ?", subscriptionIDs).Find(&subscriptions).Error; err != nil {
+				return err
+			}
+			byID = make(map[uint]*models.WebhookSubscription, len(subscriptions))
+			for i := range subscriptions {
+				byID[subscriptions[i].ID] = &subscriptions[i]
+			}
+			return nil
+		},
+		send: func(ctx context.Context, delivery *models.WebhookDelivery) error {
+			subscription, ok := byID[delivery.SubscriptionID]
+			if !ok || !subscription.Active || subscription.IsDeleted {
+				return errOutboxPermanent{errors.New("subscription is disabled")}
+			}
+			status, err := sendWebhook(ctx, subscription, delivery)
+			delivery.ResponseStatus = status
+			return err
+		},
+		columns: []string{"ResponseStatus"},
+	})
+}
+
+// sendWebhook posts a delivery to its subscription and returns the response status, 0 when no
+// response arrived. Any status outside 2xx is an error.
+func sendWebhook(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (status int, err error) {
+	defer func() { metrics.ObserveWebhook(err) }()
+
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Theranostics-Webhooks/1.0")
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(subscription.Secret, time.Now(), payload))
+	req.Header.Set(WebhookHeaderEvent, delivery.Event)
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		// Don't keep the URL, which partners sometimes use to carry a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The body is drained so the connection can be reused, but never stored: it is whatever the
+	// endpoint chose to send back
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"theransticslabs/m/config"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"::ffff:93.184.216.34", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"192.0.0.8", false},
+		{"192.0.2.1", false},
+		{"198.18.0.1", false},
+		{"198.51.100.7", false},
+		{"203.0.113.9", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"100::1", false},
+		{"2001:db8::1", false},
+		{"2002:a00:1::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := net.ParseIP(tt.ip)
+			if ip == nil {
+				t.Fatalf("%s doesn't parse", tt.ip)
+			}
+			if got := IsPublicIP(ip); got != tt.public {
+				t.Errorf("IsPublicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+			}
+		})
+	}
+}
+
+// useEnvironment sets the environment the configuration reports and restores it when the test
+// ends.
+func useEnvironment(t *testing.T, environment string) {
+	t.Helper()
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	config.AppConfig.Environment = environment
+}
+
+func TestWebhookDialControl(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		address     string
+		wantErr     error
+	}{
+		{"public IPv4", "production", "93.184.216.34:443", nil},
+		{"public IPv6", "production", "[2606:2800:220:1:248:1893:25c8:1946]:443", nil},
+		{"loopback", "production", "127.0.0.1:8080", ErrWebhookAddressNotAllowed},
+		{"private", "production", "10.0.0.5:443", ErrWebhookAddressNotAllowed},
+		{"metadata service", "production", "169.254.169.254:80", ErrWebhookAddressNotAllowed},
+		{"IPv6 loopback", "production", "[::1]:443", ErrWebhookAddressNotAllowed},
+		{"host name", "production", "example.com:443", ErrWebhookAddressNotAllowed},
+		{"loopback when running locally", "localhost", "127.0.0.1:8080", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useEnvironment(t, tt.environment)
+			if err := webhookDialControl("tcp", tt.address, nil); !errors.Is(err, tt.wantErr) {
+				t.Errorf("webhookDialControl(%s) = %v, want %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+
+	useEnvironment(t, "production")
+	if err := webhookDialControl("tcp", "no-port", nil); err == nil {
+		t.Error("webhookDialControl accepted an address without a port")
+	}
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		host        string
+		wantErr     error
+	}{
+		{"public address", "production", "93.184.216.34", nil},
+		{"private address", "production", "192.168.0.10", ErrWebhookAddressNotAllowed},
+		{"IPv6 loopback", "production", "::1", ErrWebhookAddressNotAllowed},
+		{"private address when running locally", "localhost", "192.168.0.10", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useEnvironment(t, tt.environment)
+			if err := CheckWebhookHost(context.Background(), tt.host); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckWebhookHost(%s) = %v, want %v", tt.host, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":"evt_1"}`)
+	signature := SignWebhookPayload("whsec_test", timestamp, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		payload   []byte
+		same      bool
+	}{
+		{"same input", "whsec_test", timestamp, payload, true},
+		{"other secret", "whsec_other", timestamp, payload, false},
+		{"other time", "whsec_test", timestamp.Add(time.Second), payload, false},
+		{"other payload", "whsec_test", timestamp, []byte(`{"id":"evt_2"}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := SignWebhookPayload(tt.secret, tt.timestamp, tt.payload) == signature; same != tt.same {
+				t.Errorf("signature matches = %v, want %v", same, tt.same)
+			}
+		})
+	}
+
+	const want = "t=1700000000,v1="
+	if len(signature) != len(want)+64 || signature[:len(want)] != want {
+		t.Errorf("signature %q isn't t=<unix>,v1=<hex sha256>", signature)
+	}
+}